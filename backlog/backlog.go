@@ -0,0 +1,193 @@
+// Package backlog searches providers for wanted episodes that haven't turned
+// up when polling them, eg ones that aired before the show was added.
+package backlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/types"
+)
+
+// GrabFunc downloads the best of the accepted decisions, returning the one
+// that was downloaded or nil if none were.
+type GrabFunc func(decisions []decision.Decision) (*decision.Decision, error)
+
+// Searcher searches for wanted episodes, newest first, and grabs the best
+// release the decision engine accepts for each.
+type Searcher struct {
+	DBH                *db.Handle
+	Providers          providers.ProviderRegistry
+	Decisions          *decision.Engine
+	Grab               GrabFunc
+	RetryAfter         time.Duration // how long before an episode is searched for again
+	QueriesPerProvider int           // max requests sent to each provider per run, 0 for no limit
+	running            sync.Mutex    // only one search runs at a time
+}
+
+// Result sums up what a backlog search did.
+type Result struct {
+	Searched int // episodes searched for
+	Snatched int // episodes a release was grabbed for
+	Skipped  int // episodes searched for too recently
+	Left     int // episodes not searched because the providers' budgets ran out
+}
+
+// NewSearcher creates a Searcher with the settings from the config.
+func NewSearcher(cfg *config.Config, dbh *db.Handle, provs providers.ProviderRegistry, engine *decision.Engine, grab GrabFunc) *Searcher {
+	return &Searcher{
+		DBH:                dbh,
+		Providers:          provs,
+		Decisions:          engine,
+		Grab:               grab,
+		RetryAfter:         time.Duration(cfg.Backlog.RetryAfter) * time.Hour,
+		QueriesPerProvider: cfg.Backlog.QueriesPerProvider,
+	}
+}
+
+// Run searches for the wanted episodes of every show that isn't paused.
+// Episodes searched for within RetryAfter are skipped.
+func (s *Searcher) Run() Result {
+	eps, err := s.DBH.GetWantedEpisodes()
+	if err != nil {
+		glog.Errorf("Error getting wanted episodes: %s", err)
+		return Result{}
+	}
+	res := s.search(eps, false)
+	glog.Infof("Backlog search done: searched %d, snatched %d, skipped %d, %d left for the next run",
+		res.Searched, res.Snatched, res.Skipped, res.Left)
+	return res
+}
+
+// WantedEpisodes returns the show's WANTED episodes that have aired, newest
+// first.  If season is -1 every season's are returned.
+func (s *Searcher) WantedEpisodes(show *db.Show, season int64) ([]db.Episode, error) {
+	var all []db.Episode
+	var err error
+	if season < 0 {
+		all, err = s.DBH.GetShowEpisodes(show)
+	} else {
+		all, err = s.DBH.GetSeasonEpisodes(show.ID, season)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	eps := []db.Episode{}
+	for _, ep := range all {
+		if ep.Status != types.WANTED || ep.AirDate.After(now) {
+			continue
+		}
+		ep.Show = *show
+		eps = append(eps, ep)
+	}
+	sort.Sort(newestFirst(eps))
+	return eps, nil
+}
+
+// Search searches for the episodes straight away, even if they were searched
+// for recently.  The providers' budgets still apply.
+func (s *Searcher) Search(eps []db.Episode) Result {
+	res := s.search(eps, true)
+	glog.Infof("Backlog search done: searched %d, snatched %d, %d left", res.Searched, res.Snatched, res.Left)
+	return res
+}
+
+func (s *Searcher) search(eps []db.Episode, force bool) Result {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	res := Result{}
+	budget := map[string]int{}
+	for name := range s.Providers {
+		budget[name] = s.QueriesPerProvider
+	}
+	now := time.Now()
+	for i := range eps {
+		ep := &eps[i]
+		if ep.AirDate.After(now) {
+			continue
+		}
+		if !force && now.Sub(ep.LastSearched) < s.RetryAfter {
+			res.Skipped++
+			continue
+		}
+		provs := s.available(budget)
+		if len(provs) == 0 {
+			res.Left = len(eps) - i
+			glog.Infof("Providers' backlog budgets used up, %d episodes left", res.Left)
+			break
+		}
+		// A search can take more than one request to a provider, eg to get
+		// its capabilities or fall back to searching by name, so the budget
+		// is charged for what it actually sent.
+		before := requests(provs)
+		res.Searched++
+		if s.searchEpisode(ep, provs) {
+			res.Snatched++
+		}
+		for name, n := range requests(provs) {
+			budget[name] -= int(n - before[name])
+		}
+	}
+	return res
+}
+
+// searchEpisode searches the providers for the episode and grabs the best
+// accepted result.  It returns true if one was grabbed.
+func (s *Searcher) searchEpisode(ep *db.Episode, provs providers.ProviderRegistry) bool {
+	glog.Infof("Backlog searching for %s S%dE%d", ep.Show.Name, ep.Season, ep.Episode)
+	ep.LastSearched = time.Now()
+	err := s.DBH.SaveEpisode(ep)
+	if err != nil {
+		glog.Errorf("Error saving search time of %s S%dE%d: %s", ep.Show.Name, ep.Season, ep.Episode, err)
+	}
+	results := provs.Search(providers.NewSearchQuery(&ep.Show, ep))
+	if len(results) == 0 {
+		glog.Infof("No results found for %s S%dE%d", ep.Show.Name, ep.Season, ep.Episode)
+		return false
+	}
+	decisions := s.Decisions.Evaluate(&ep.Show, []*db.Episode{ep}, results)
+	dec, err := s.Grab(decisions)
+	if err != nil {
+		glog.Errorf("Couldn't snatch any of the results for %s S%dE%d: %s", ep.Show.Name, ep.Season, ep.Episode, err)
+	}
+	if dec == nil {
+		return false
+	}
+	glog.Infof("Snatched %s for %s S%dE%d", dec.Result.Name, ep.Show.Name, ep.Season, ep.Episode)
+	return true
+}
+
+// available returns the providers that have some of their budget left.
+func (s *Searcher) available(budget map[string]int) providers.ProviderRegistry {
+	provs := providers.ProviderRegistry{}
+	for name, p := range s.Providers {
+		if s.QueriesPerProvider <= 0 || budget[name] > 0 {
+			provs[name] = p
+		}
+	}
+	return provs
+}
+
+// requests returns how many requests have been sent to each of the
+// providers.
+func requests(provs providers.ProviderRegistry) map[string]int64 {
+	counts := make(map[string]int64, len(provs))
+	for name, p := range provs {
+		counts[name] = p.Status().Requests
+	}
+	return counts
+}
+
+type newestFirst []db.Episode
+
+func (e newestFirst) Len() int           { return len(e) }
+func (e newestFirst) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e newestFirst) Less(i, j int) bool { return e[i].AirDate.After(e[j].AirDate) }
@@ -0,0 +1,122 @@
+package backlog
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/providers"
+	. "github.com/onsi/gomega"
+)
+
+const searchFeed = `<?xml version="1.0" encoding="utf-8" ?>
+<rss version="2.0" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+<channel>
+<item>
+	<title>show1.S01E01.720p.HDTV.x264-GOOD</title>
+	<guid isPermaLink="false">good</guid>
+	<link>http://example.com/getnzb/good.nzb</link>
+</item>
+</channel>
+</rss>`
+
+func setupTest(t *testing.T) (*Searcher, *[]string, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("t") == "caps" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(searchFeed))
+	}))
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	provReg, err := providers.NewProviderRegistry(cfg.Providers)
+	Expect(err).ToNot(HaveOccurred())
+	dbh := db.NewMemoryDBHandle(false, true)
+	db.LoadFixtures(t, dbh)
+
+	grabbed := []string{}
+	grab := func(decisions []decision.Decision) (*decision.Decision, error) {
+		dec := decision.Best(decisions)
+		if dec != nil {
+			grabbed = append(grabbed, dec.Result.Name)
+		}
+		return dec, nil
+	}
+	return NewSearcher(cfg, dbh, provReg, decision.NewEngine(cfg, dbh, provReg), grab), &grabbed, server
+}
+
+func TestRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	s, grabbed, server := setupTest(t)
+	defer server.Close()
+	s.QueriesPerProvider = 1
+
+	res := s.Run()
+	Expect(res.Searched).To(Equal(1))
+	Expect(res.Snatched).To(Equal(1))
+	Expect(res.Left).To(Equal(3))
+	Expect(*grabbed).To(Equal([]string{"show1.S01E01.720p.HDTV.x264-GOOD"}))
+
+	ep, err := s.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(time.Since(ep.LastSearched)).To(BeNumerically("<", time.Minute))
+
+	// Episodes searched for recently are skipped until RetryAfter.
+	res = s.Run()
+	Expect(res.Skipped).To(Equal(1))
+	Expect(res.Searched).To(Equal(1))
+	Expect(res.Snatched).To(Equal(0))
+
+	s.QueriesPerProvider = 0
+	res = s.Run()
+	Expect(res.Skipped).To(Equal(2))
+	Expect(res.Searched).To(Equal(2))
+	Expect(res.Left).To(Equal(0))
+}
+
+func TestBudgetCountsRequests(t *testing.T) {
+	RegisterTestingT(t)
+
+	s, _, server := setupTest(t)
+	defer server.Close()
+	// Each search asks for the provider's capabilities before searching.
+	s.QueriesPerProvider = 2
+
+	res := s.Run()
+	Expect(res.Searched).To(Equal(1))
+	Expect(res.Left).To(Equal(3))
+}
+
+func TestSearchShow(t *testing.T) {
+	RegisterTestingT(t)
+
+	s, grabbed, server := setupTest(t)
+	defer server.Close()
+	show, err := s.DBH.GetShowByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	show.Paused = true
+	Expect(s.DBH.SaveShow(show)).ToNot(HaveOccurred())
+
+	eps, err := s.WantedEpisodes(show, 2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(BeEmpty())
+
+	eps, err = s.WantedEpisodes(show, -1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(HaveLen(2))
+	Expect(eps[0].Name).To(Equal("show1episode1"))
+
+	// Searching on request ignores the show being paused.
+	res := s.Search(eps)
+	Expect(res.Searched).To(Equal(2))
+	Expect(*grabbed).To(HaveLen(1))
+}
@@ -23,11 +23,93 @@ type Config struct {
 	Storage       storageConfig
 	MediaDefaults mediaDefaults
 	Providers     []ProviderConfig
+	// Download clients to send grabs to.  Providers types without a client
+	// use the blackhole directories in Storage.
+	DownloadClients []DownloadClientConfig
+	Downloads       downloadsConfig
+	Decisions       decisionConfig
+	Backlog         backlogConfig
 }
 
+// ProviderConfig describes a single provider (indexer) to search for
+// releases.
 type ProviderConfig struct {
-	Name string
-	API  string
+	Name       string   // unique name for this provider
+	Type       string   // newznab, torznab, nzbsorg or nyaatorrents
+	URL        string   // base API url, optional for presets
+	API        string   // API key
+	Categories []string // optional override of the categories to search
+	Enabled    bool     // defaults to true if not set
+
+	RequestsPerMinute int // max requests per minute, 0 for no limit
+	RequestsPerDay    int // max requests per day, 0 for no limit
+	Timeout           int // http timeout in seconds, 0 for the default
+
+	legacy bool // written before providers had a Type
+}
+
+// legacyProviderTypes are the types of the providers older versions
+// created by name, for configs written before providers had a Type.
+var legacyProviderTypes = map[string]string{
+	"nzbsOrg":      "nzbsorg",
+	"nyaaTorrents": "nyaatorrents",
+}
+
+// UnmarshalJSON decodes a ProviderConfig, defaulting Enabled to true and
+// working out the Type of providers from older configs that don't have one.
+func (p *ProviderConfig) UnmarshalJSON(b []byte) error {
+	type plain ProviderConfig
+	pc := plain{Enabled: true}
+	if err := json.Unmarshal(b, &pc); err != nil {
+		return err
+	}
+	if pc.Type == "" {
+		pc.Type = legacyProviderTypes[pc.Name]
+		pc.legacy = true
+	}
+	*p = ProviderConfig(pc)
+	return nil
+}
+
+// DownloadClientConfig describes a download client (SABnzbd, a blackhole
+// directory etc) that grabs from one type of provider are sent to.
+type DownloadClientConfig struct {
+	Name         string // unique name for this client
+	Type         string // blackhole, sabnzbd, nzbget, transmission, qbittorrent, deluge or rtorrent
+	ProviderType string // nzb or torrent, which provider's grabs to handle
+	URL          string // base url of the client's web interface/API
+	API          string // API key
+	Username     string
+	Password     string
+	Category     string // category or label to add downloads with
+	Priority     int    // priority to add downloads with, for clients that support it
+	Directory    string // blackhole directory, or where torrent clients save downloads
+	Enabled      bool
+}
+
+// downloadsConfig controls how grabs sent to download clients are followed up.
+type downloadsConfig struct {
+	Timeout int // hours a download can go unfinished before it's failed, 0 for never
+}
+
+// decisionConfig sets the rules releases have to pass to be grabbed.
+type decisionConfig struct {
+	MinSize         int64    // minimum size per episode in MB, 0 for no limit
+	MaxSize         int64    // maximum size per episode in MB, 0 for no limit
+	IgnoreWords     []string // reject releases with any of these in their name
+	RequireWords    []string // if set, releases need one of these in their name
+	MinSeeders      int64    // reject torrents with fewer seeders
+	MaxAge          int      // maximum age in days, eg usenet retention, 0 for no limit
+	PreferredGroups []string // release groups to rank above others, best first
+	ProperWindow    int      // days after airing a downloaded episode can be replaced by a PROPER or REPACK, 0 for never
+}
+
+// backlogConfig controls the background search for wanted episodes that
+// haven't turned up in provider polls.
+type backlogConfig struct {
+	Interval           int // minutes between backlog searches, 0 to turn them off
+	RetryAfter         int // hours before an episode is searched for again
+	QueriesPerProvider int // max requests sent to each provider per run, 0 for no limit
 }
 
 type webConfig struct {
@@ -78,6 +160,17 @@ func NewConfig() *Config {
 			ShowQuality:   quality.HDTV,
 			EpisodeStatus: types.SKIPPED,
 		},
+		Downloads: downloadsConfig{
+			Timeout: 48,
+		},
+		Decisions: decisionConfig{
+			ProperWindow: 7,
+		},
+		Backlog: backlogConfig{
+			Interval:           360,
+			RetryAfter:         24,
+			QueriesPerProvider: 20,
+		},
 	}
 }
 
@@ -129,10 +222,26 @@ func (c *Config) ReadConfig(configPath string) error {
 		c.Storage.Directories[i] = replaceTildeInPath(p)
 	}
 	c.Storage.NZBBlackhole = replaceTildeInPath(c.Storage.NZBBlackhole)
+	c.addLegacyProviders()
 
 	return nil
 }
 
+// addLegacyProviders adds the nyaaTorrents provider older versions always
+// polled to configs written for them.
+func (c *Config) addLegacyProviders() {
+	legacy := false
+	for _, p := range c.Providers {
+		if p.Type == "nyaatorrents" {
+			return
+		}
+		legacy = legacy || p.legacy
+	}
+	if legacy {
+		c.Providers = append(c.Providers, ProviderConfig{Name: "nyaaTorrents", Type: "nyaatorrents", Enabled: true})
+	}
+}
+
 // HighlightBytePosition takes a reader and the location in bytes of a parse
 // error (for instance, from json.SyntaxError.Offset) and returns the line, column,
 // and pretty-printed context around the error with an arrow indicating the exact
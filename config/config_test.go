@@ -40,3 +40,38 @@ func TestDefaultsGetOverridden(t *testing.T) {
 	}
 	spew.Dump(c)
 }
+
+func TestProvidersDefaultToEnabled(t *testing.T) {
+	c := NewConfig()
+	path := "testdata/test_config.json"
+	err := c.ReadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no errors when parsing: %s, got %s", path, err)
+	}
+	if len(c.Providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(c.Providers))
+	}
+	if !c.Providers[0].Enabled || c.Providers[1].Enabled {
+		t.Fatalf("Expected only the first provider to be enabled: %+v", c.Providers)
+	}
+}
+
+func TestLegacyProviders(t *testing.T) {
+	c := NewConfig()
+	path := "testdata/legacy_config.json"
+	err := c.ReadConfig(path)
+	if err != nil {
+		t.Fatalf("Expected no errors when parsing: %s, got %s", path, err)
+	}
+	if len(c.Providers) != 2 {
+		t.Fatalf("Expected 2 providers, got %d", len(c.Providers))
+	}
+	nzbs := c.Providers[0]
+	if nzbs.Type != "nzbsorg" || !nzbs.Enabled || nzbs.API != "123" {
+		t.Fatalf("Expected an enabled nzbsorg provider, got %+v", nzbs)
+	}
+	nyaa := c.Providers[1]
+	if nyaa.Name != "nyaaTorrents" || nyaa.Type != "nyaatorrents" || !nyaa.Enabled {
+		t.Fatalf("Expected an enabled nyaatorrents provider, got %+v", nyaa)
+	}
+}
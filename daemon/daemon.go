@@ -1,19 +1,25 @@
 package daemon
 
 import (
-	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/backlog"
 	"github.com/hobeone/tv2go/config"
 	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/downloaders"
 	"github.com/hobeone/tv2go/indexers"
 	"github.com/hobeone/tv2go/indexers/tvdb"
 	"github.com/hobeone/tv2go/indexers/tvrage"
 	"github.com/hobeone/tv2go/nameexception"
 	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/postprocessor"
 	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
 	"github.com/hobeone/tv2go/storage"
 	"github.com/hobeone/tv2go/types"
 	"github.com/hobeone/tv2go/web"
@@ -27,6 +33,10 @@ type Daemon struct {
 	Providers          providers.ProviderRegistry
 	ExceptionProviders map[string]nameexception.Provider
 	Storage            *storage.Broker
+	DownloadClients    downloaders.ClientRegistry
+	Decisions          *decision.Engine
+	Backlog            *backlog.Searcher
+	Postprocessor      *postprocessor.Postprocessor
 	shutdownChan       chan (int)
 }
 
@@ -47,20 +57,17 @@ func NewDaemon(cfg *config.Config) *Daemon {
 		"tvdb":   tvdb.NewTvdbIndexer("90D7DF3AE9E4841E"),
 		"tvrage": tvrage.NewTVRageIndexer(),
 	}
-	//Ghetto until real provider setup done
-	nzborgKey := ""
-	for _, p := range cfg.Providers {
-		if p.Name == "nzbsOrg" {
-			nzborgKey = p.API
-		}
-	}
-	if nzborgKey == "" {
-		panic("No Nzbs.org API key set in config")
+	provReg, err := providers.NewProviderRegistry(cfg.Providers)
+	if err != nil {
+		panic(fmt.Sprintf("Error configuring providers: %s", err))
 	}
-	d.Providers = providers.ProviderRegistry{
-		"nzbsOrg":      providers.NewNzbsOrg(nzborgKey),
-		"nyaaTorrents": providers.NewNyaaTorrents(),
+	if len(provReg) == 0 {
+		glog.Warning("No providers enabled in config, nothing will be downloaded.")
 	}
+	provReg.LoadState(dbh)
+	d.Providers = provReg
+	d.Decisions = decision.NewEngine(cfg, dbh, provReg)
+	d.Backlog = backlog.NewSearcher(cfg, dbh, provReg, d.Decisions, d.grab)
 
 	broker, err := storage.NewBroker(cfg.Storage.Directories...)
 	if err != nil {
@@ -68,6 +75,13 @@ func NewDaemon(cfg *config.Config) *Daemon {
 	}
 	d.Storage = broker
 
+	clients, err := downloaders.NewClientRegistry(cfg, broker)
+	if err != nil {
+		panic(fmt.Sprintf("Error configuring download clients: %s", err))
+	}
+	d.DownloadClients = clients
+	d.Postprocessor = postprocessor.NewPostprocessor(dbh, broker, d.Indexers)
+
 	d.ExceptionProviders = map[string]nameexception.Provider{
 		"tvdb":        nameexception.NewMidgetSpyTvdb(d.DBH),
 		"thexem_tvdb": nameexception.NewXEM(d.DBH, "tvdb"),
@@ -87,7 +101,14 @@ func (d *Daemon) Run() {
 
 	go d.ShowUpdater()
 	go d.PollProviders()
-	webserver := web.NewServer(d.Config, d.DBH, d.Storage, d.Providers, web.SetIndexers(d.Indexers))
+	go d.HandleCompletedDownloads()
+	go d.SearchBacklog()
+	webserver := web.NewServer(d.Config, d.DBH, d.Storage, d.Providers,
+		web.SetIndexers(d.Indexers),
+		web.SetDownloadClients(d.DownloadClients),
+		web.SetDecisionEngine(d.Decisions),
+		web.SetBacklog(d.Backlog),
+	)
 
 	webserver.StartServing()
 }
@@ -140,86 +161,558 @@ func (d *Daemon) ShowUpdater() {
 // PollProviders sets up goroutines that poll the configured providers on a set
 // interval.  It then listens for new results and sends them for processing.
 func (d *Daemon) PollProviders() {
-	respChan := make(chan (providers.ProviderResult))
+	respChan := make(chan ([]providers.ProviderResult))
 	for _, p := range d.Providers {
 		go providers.NewProviderPoller(p, time.Minute*15, d.DBH, respChan).Poll()
 	}
 	for {
 		select {
 		case resp := <-respChan:
-			err := d.ProcessProviderResult(resp)
-			if err != nil {
-				glog.Error(err.Error())
-			}
+			d.ProcessProviderResults(resp)
 		}
 	}
 }
 
-// ProcessProviderResult takes a ProviderResult parses the name and sees if
-// there it matches a show we are interested in.  If so it will try to download
-// the url for that episode and send it to the right handler for that file
-// type.
-func (d *Daemon) ProcessProviderResult(r providers.ProviderResult) error {
-	var np *naming.NameParser
-	if r.Anime {
-		np = naming.NewNameParser(naming.AnimeRegex)
-	} else {
-		np = naming.NewNameParser(naming.StandardRegexes)
+// SearchBacklog is meant to be run as a background goroutine that searches
+// for wanted episodes every backlog interval.
+func (d *Daemon) SearchBacklog() {
+	interval := time.Duration(d.Config.Backlog.Interval) * time.Minute
+	if interval <= 0 {
+		glog.Info("Backlog searching is turned off")
+		return
+	}
+	for {
+		d.Backlog.Run()
+		glog.Infof("Searched backlog, sleeping %s", interval)
+		time.Sleep(interval)
+	}
+}
+
+// ProcessProviderResults finds the shows and episodes the results are for and
+// grabs the best acceptable result for each set of wanted episodes.  Results
+// for episodes already grabbed from an earlier set are skipped.  Every result
+// is logged with the decision made about it and how it was parsed.
+func (d *Daemon) ProcessProviderResults(results []providers.ProviderResult) {
+	groups := map[string][]decision.Candidate{}
+	keys := []string{}
+	for _, r := range results {
+		c, err := d.candidate(r)
+		if err != nil {
+			glog.Info(decision.Decision{Candidate: c, Rejections: []string{err.Error()}}.String())
+			continue
+		}
+		key := episodeKey(c.Episodes)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], c)
+	}
+
+	grabbed := map[int64]bool{}
+	for _, key := range keys {
+		candidates := groups[key]
+		if anyGrabbed(candidates[0].Episodes, grabbed) {
+			glog.Infof("Already grabbed episodes for %s, skipping", candidates[0].Result.Name)
+			continue
+		}
+		dec, err := d.grab(d.Decisions.Decide(candidates))
+		if err != nil {
+			glog.Error(err.Error())
+		}
+		if dec == nil {
+			continue
+		}
+		for _, ep := range dec.Episodes {
+			grabbed[ep.ID] = true
+		}
 	}
-	pr := np.Parse(r.Name)
+}
 
-	//TODO: make this work with more kinds of episodes:
-	if len(pr.EpisodeNumbers) == 0 && len(pr.AbsoluteEpisodeNumbers) == 0 {
-		return fmt.Errorf("Provider result %s had no episodes, skipping", pr.OriginalName)
+// episodeKey identifies a set of episodes.
+func episodeKey(eps []*db.Episode) string {
+	ids := make([]string, len(eps))
+	for i, ep := range eps {
+		ids[i] = strconv.FormatInt(ep.ID, 10)
 	}
+	return strings.Join(ids, ",")
+}
 
-	dbshow, season, err := d.DBH.GetShowByAllNames(pr.SeriesName)
+func anyGrabbed(eps []*db.Episode, grabbed map[int64]bool) bool {
+	for _, ep := range eps {
+		if grabbed[ep.ID] {
+			return true
+		}
+	}
+	return false
+}
 
+// ProcessProviderResult takes a ProviderResult parses the name and sees if
+// there it matches a show we are interested in.  If so and the decision
+// engine accepts it, it will try to download the url for those episodes and
+// send it to the right handler for that file type.
+func (d *Daemon) ProcessProviderResult(r providers.ProviderResult) error {
+	c, err := d.candidate(r)
 	if err != nil {
-		return fmt.Errorf("Couldn't match '%s' to any known show name: %s", pr.SeriesName, err)
+		return err
 	}
-
-	if season > -1 {
-		pr.SeasonNumber = season
+	decisions := d.Decisions.Decide([]decision.Candidate{c})
+	if !decisions[0].Accepted() {
+		return fmt.Errorf("Rejected %s: %s", r.Name, strings.Join(decisions[0].Rejections, "; "))
 	}
+	_, err = d.grab(decisions)
+	return err
+}
 
-	ep, err := d.DBH.GetEpisodeByShowSeasonAndNumber(dbshow.ID, pr.SeasonNumber, pr.FirstEpisode())
+// candidate matches the result to the episodes it's of and picks out the
+// wanted ones, including downloaded episodes that could be upgraded.  Season
+// packs are only considered if enough of the season is wanted.
+func (d *Daemon) candidate(r providers.ProviderResult) (decision.Candidate, error) {
+	c, err := d.Decisions.Match(r)
 	if err != nil {
-		return fmt.Errorf("Can't find episode in DB Show: %s S%dE%d: %s", dbshow.Name, pr.SeasonNumber, pr.FirstEpisode(), err)
+		return c, err
 	}
+	c.Episodes = d.Decisions.Wanted(c.Show, c.Matched)
+	if c.Parse.IsSeasonPack() {
+		if len(c.Episodes) < decision.MinSeasonPackWanted {
+			return c, fmt.Errorf("Season pack %s has %d wanted episodes, need %d, skipping", r.Name, len(c.Episodes), decision.MinSeasonPackWanted)
+		}
+		glog.Infof("Found %d wanted episodes in season pack %s", len(c.Episodes), r.Name)
+		return c, nil
+	}
+	for _, ep := range c.Matched {
+		glog.Infof("Found matching episode in db: %s S%dE%d: %s", c.Show.Name, ep.Season, ep.Episode, ep.Name)
+	}
+	return c, nil
+}
 
-	glog.Infof("Found matching episode in db: %s S%dE%d: %s", dbshow.Name, pr.SeasonNumber, pr.FirstEpisode(), ep.Name)
+// grab tries to download the accepted decisions, best first, until one
+// works and marks its episodes as SNATCHED, SNATCHED_BEST if they're being
+// upgraded or SNATCHED_PROPER if they're getting a proper.  It returns the
+// decision that was downloaded, or nil and the last error if none were.
+func (d *Daemon) grab(decisions []decision.Decision) (*decision.Decision, error) {
+	var err error
+	for i := range decisions {
+		dec := &decisions[i]
+		if !dec.Accepted() {
+			continue
+		}
+		err = d.download(dec.Result, dec.Episodes)
+		if err != nil {
+			glog.Warningf("Couldn't snatch %s, trying the next result: %s", dec.Result.Name, err)
+			continue
+		}
+		for _, ep := range dec.Episodes {
+			ep.Status = dec.SnatchedStatus(ep)
+		}
+		err = d.DBH.SaveEpisodes(dec.Episodes)
+		if err != nil {
+			glog.Errorf("Error saving episodes for %s: %s", dec.Result.Name, err)
+		}
+		return dec, nil
+	}
+	return nil, err
+}
+
+// download gets the result's file from its provider, checks it's valid and
+// sends it to the download client for that type of provider.  The download is
+// recorded against the episodes so it can be imported once it's finished, and
+// their release name is set to the result's.
+func (d *Daemon) download(r providers.ProviderResult, eps []*db.Episode) error {
 	// Don't like this, super fragile
 	p, ok := d.Providers[r.ProviderName]
 	if !ok {
 		return fmt.Errorf("This daemon doesn't know about provider %s, skipping", r.ProviderName)
 	}
 
-	if ep.Status == types.WANTED {
-		destPath := ""
-		switch p.Type() {
-		case providers.NZB:
-			destPath = d.Config.Storage.NZBBlackhole
-		case providers.TORRENT:
-			destPath = d.Config.Storage.TorrentBlackhole
-		default:
-			e := fmt.Sprintf("Unknown provider type %s for %s", p.Type(), r.ProviderName)
-			glog.Error(e)
-			return errors.New(e)
+	client, err := d.DownloadClients.For(p.Type())
+	if err != nil {
+		glog.Error(err)
+		return err
+	}
+	filename, filecont, err := p.GetURL(r.URL)
+	if err != nil {
+		return fmt.Errorf("Couldn't download %s: %s", r.URL, err)
+	}
+	err = providers.CheckDownload(p.Type(), r, filecont)
+	if err != nil {
+		return fmt.Errorf("Rejecting %s: %s", r.Name, err)
+	}
+	id, err := client.Add(downloaders.Download{
+		Name:     r.Name,
+		URL:      r.URL,
+		Filename: filename,
+		Content:  filecont,
+	})
+	if err != nil {
+		return fmt.Errorf("Error sending %s to %s: %s", r.Name, client.Name(), err)
+	}
+	glog.Infof("Sent %s to %s as %s", r.Name, client.Name(), id)
+	err = d.DBH.AddDownload(db.Download{
+		Client:     client.Name(),
+		DownloadID: id,
+		Name:       r.Name,
+		Provider:   r.ProviderName,
+		URL:        r.URL,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording download of %s: %s", r.Name, err)
+	}
+	for _, ep := range eps {
+		ep.ReleaseName = r.Name
+	}
+	err = d.DBH.AddHistory(db.History{
+		Event:      db.HistoryGrabbed,
+		Name:       r.Name,
+		Provider:   r.ProviderName,
+		Quality:    quality.QualityFromName(r.Name, r.Anime),
+		Size:       r.Size,
+		Client:     client.Name(),
+		DownloadID: id,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", r.Name, err)
+	}
+	return nil
+}
+
+// completedDownloadInterval is how often the download clients are checked for
+// finished downloads.
+const completedDownloadInterval = time.Minute * 5
+
+// maxImportAttempts is how many times importing a finished download is tried
+// before it's left in the download client for the user to sort out.
+const maxImportAttempts = 12
+
+// HandleCompletedDownloads is meant to be run as a background goroutine which
+// imports downloads once the download clients have finished them and retries
+// the ones that failed.
+func (d *Daemon) HandleCompletedDownloads() {
+	for {
+		d.CheckCompletedDownloads()
+		d.CheckTimedOutDownloads()
+		time.Sleep(completedDownloadInterval)
+	}
+}
+
+// CheckCompletedDownloads asks each download client for the downloads it has
+// finished and imports the ones tv2go grabbed.  Failed downloads are
+// blacklisted and their episodes searched for again.
+func (d *Daemon) CheckCompletedDownloads() {
+	for _, client := range d.DownloadClients {
+		history, err := client.History()
+		if err != nil {
+			glog.Errorf("Error getting history from %s: %s", client.Name(), err)
+			continue
+		}
+		for _, item := range history {
+			switch item.Status {
+			case downloaders.COMPLETED:
+				err = d.importDownload(client, item)
+				if err != nil {
+					d.importFailed(client.Name(), item, err)
+				}
+			case downloaders.FAILED:
+				reason := item.Message
+				if reason == "" {
+					reason = "Failed in " + client.Name()
+				}
+				err = d.failDownload(client.Name(), item.ID, reason)
+				if err != nil {
+					glog.Errorf("Error handling failed download %s from %s: %s", item.Name, client.Name(), err)
+				}
+			}
+		}
+	}
+}
+
+// CheckTimedOutDownloads fails downloads whose episodes have been SNATCHED
+// for longer than the configured timeout, unless their client has finished
+// them and they're waiting to be imported.  Downloads on clients that can't be
+// reached are left alone.  Downloads whose episodes aren't SNATCHED anymore,
+// because they were imported some other way or changed by hand, aren't
+// tracked any longer.
+func (d *Daemon) CheckTimedOutDownloads() {
+	downloads, err := d.DBH.GetAllDownloads()
+	if err != nil {
+		glog.Errorf("Error getting downloads: %s", err)
+		return
+	}
+	timeout := time.Duration(d.Config.Downloads.Timeout) * time.Hour
+	var items map[string]map[string]downloaders.Item
+	type downloadKey struct{ client, id string }
+	checked := map[downloadKey]bool{}
+	for _, dl := range downloads {
+		key := downloadKey{dl.Client, dl.DownloadID}
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		snatched := false
+		for _, other := range downloads {
+			if other.Client != dl.Client || other.DownloadID != dl.DownloadID {
+				continue
+			}
+			ep, err := d.DBH.GetEpisodeByID(other.EpisodeID)
+			if err == nil && ep.Status.IsSnatched() {
+				snatched = true
+				break
+			}
+		}
+		if !snatched {
+			glog.Infof("No longer tracking %s, its episodes aren't snatched", dl.Name)
+			err = d.DBH.DeleteDownloads(dl.Client, dl.DownloadID)
+		} else if timeout > 0 && time.Since(dl.Added) > timeout {
+			if items == nil {
+				items = d.clientItems()
+			}
+			clientItems, reachable := items[dl.Client]
+			item, found := clientItems[dl.DownloadID]
+			switch {
+			case !reachable:
+				glog.Warningf("Can't check %s, %s can't be reached", dl.Name, dl.Client)
+			case found && item.Status == downloaders.COMPLETED:
+				glog.Warningf("%s has finished but hasn't been imported", dl.Name)
+			default:
+				err = d.failDownload(dl.Client, dl.DownloadID, fmt.Sprintf("Not finished after %s", timeout))
+			}
+		}
+		if err != nil {
+			glog.Errorf("Error checking download %s: %s", dl.Name, err)
+		}
+	}
+}
+
+// clientItems returns what each download client is doing, by client name and
+// then download id.  Clients that can't be reached are left out.
+func (d *Daemon) clientItems() map[string]map[string]downloaders.Item {
+	items := map[string]map[string]downloaders.Item{}
+	for _, client := range d.DownloadClients {
+		queue, err := client.Queue()
+		if err == nil {
+			var history []downloaders.Item
+			history, err = client.History()
+			queue = append(queue, history...)
 		}
-		filename, filecont, err := p.GetURL(r.URL)
 		if err != nil {
-			return fmt.Errorf("Couldn't download %s: %s", r.URL, err)
+			glog.Errorf("Error getting downloads from %s: %s", client.Name(), err)
+			continue
 		}
-		fname, err := d.Storage.SaveToFile(destPath, filename, filecont)
+		byID := make(map[string]downloaders.Item, len(queue))
+		for _, item := range queue {
+			byID[item.ID] = item
+		}
+		items[client.Name()] = byID
+	}
+	return items
+}
+
+// failDownload blacklists a download that failed, removes it and its data
+// from the download client, and sets its episodes back to WANTED (or
+// DOWNLOADED if they were being upgraded).  They're searched for again in the
+// background so other downloads aren't held up by the providers.
+func (d *Daemon) failDownload(clientName, downloadID, reason string) error {
+	downloads, err := d.DBH.GetDownloads(clientName, downloadID)
+	if err != nil {
+		return err
+	}
+	if len(downloads) == 0 {
+		return nil
+	}
+	dl := downloads[0]
+	glog.Warningf("Download %s from %s failed: %s", dl.Name, clientName, reason)
+	err = d.DBH.AddBlacklist(&db.Blacklist{
+		ShowID:    dl.ShowID,
+		EpisodeID: dl.EpisodeID,
+		Name:      dl.Name,
+		Provider:  dl.Provider,
+		URL:       dl.URL,
+		Reason:    reason,
+	})
+	if err != nil {
+		return fmt.Errorf("Error blacklisting %s: %s", dl.Name, err)
+	}
+
+	client, err := d.DownloadClients.Named(clientName)
+	if err == nil {
+		err = client.Remove(downloadID, true)
+	}
+	if err != nil {
+		glog.Errorf("Error removing %s from %s: %s", dl.Name, clientName, err)
+	}
+	err = d.DBH.DeleteDownloads(clientName, downloadID)
+	if err != nil {
+		return err
+	}
+
+	eps := []*db.Episode{}
+	for _, dl := range downloads {
+		ep, err := d.DBH.GetEpisodeByID(dl.EpisodeID)
 		if err != nil {
-			return fmt.Errorf("Error saving file to %s: %s", fname, err)
+			glog.Errorf("Couldn't find episode for %s: %s", dl.Name, err)
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	anime := len(eps) > 0 && eps[0].Show.Anime
+	err = d.DBH.AddHistory(db.History{
+		Event:      db.HistoryFailed,
+		Name:       dl.Name,
+		Provider:   dl.Provider,
+		Quality:    quality.QualityFromName(dl.Name, anime),
+		Client:     clientName,
+		DownloadID: downloadID,
+		Message:    reason,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", dl.Name, err)
+	}
+
+	retry := []db.Episode{}
+	for _, ep := range eps {
+		if !ep.Status.IsSnatched() {
+			continue
 		}
-		ep.Status = types.SNATCHED
+		ep.Status = ep.Status.Unsnatched()
 		err = d.DBH.SaveEpisode(ep)
 		if err != nil {
 			glog.Errorf("Error saving episode %s: %s", ep.Name, err)
+			continue
 		}
+		retry = append(retry, *ep)
+	}
+	if len(retry) > 0 {
+		glog.Infof("Searching for %d episodes to replace %s", len(retry), dl.Name)
+		go d.Backlog.Search(retry)
 	}
 	return nil
 }
+
+// importFailed records a failed attempt to import a finished download.  After
+// maxImportAttempts it's given up on, which is recorded in its episodes'
+// history.
+func (d *Daemon) importFailed(clientName string, item downloaders.Item, importErr error) {
+	attempts, err := d.DBH.RecordImportFailure(clientName, item.ID, importErr.Error())
+	if err != nil {
+		glog.Errorf("Error recording failed import of %s: %s", item.Name, err)
+	}
+	if attempts < maxImportAttempts {
+		glog.Errorf("Error importing %s from %s (attempt %d of %d): %s", item.Name, clientName, attempts, maxImportAttempts, importErr)
+		return
+	}
+	glog.Errorf("Giving up importing %s from %s after %d attempts: %s", item.Name, clientName, attempts, importErr)
+	downloads, err := d.DBH.GetDownloads(clientName, item.ID)
+	if err != nil || len(downloads) == 0 {
+		return
+	}
+	eps := []*db.Episode{}
+	for _, dl := range downloads {
+		ep, err := d.DBH.GetEpisodeByID(dl.EpisodeID)
+		if err != nil {
+			glog.Errorf("Couldn't find episode for %s: %s", dl.Name, err)
+			continue
+		}
+		eps = append(eps, ep)
+	}
+	err = d.DBH.AddHistory(db.History{
+		Event:      db.HistoryImportFailed,
+		Name:       downloads[0].Name,
+		Provider:   downloads[0].Provider,
+		Size:       item.Size,
+		Client:     clientName,
+		DownloadID: item.ID,
+		Message:    importErr.Error(),
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", item.Name, err)
+	}
+}
+
+// importDownload moves a finished download's files into place and removes it
+// from the client.  Files of episodes being upgraded are replaced and the
+// import is recorded as an upgrade.  Downloads tv2go doesn't know about, or
+// has given up importing, are left alone.
+func (d *Daemon) importDownload(client downloaders.DownloadClient, item downloaders.Item) error {
+	downloads, err := d.DBH.GetDownloads(client.Name(), item.ID)
+	if err != nil {
+		return err
+	}
+	if len(downloads) == 0 || downloads[0].ImportAttempts >= maxImportAttempts {
+		return nil
+	}
+	show, err := d.DBH.GetShowByID(downloads[0].ShowID)
+	if err != nil {
+		return fmt.Errorf("Couldn't find show for %s: %s", item.Name, err)
+	}
+	upgrading := false
+	replaced := ""
+	for _, dl := range downloads {
+		ep, err := d.DBH.GetEpisodeByID(dl.EpisodeID)
+		if err != nil {
+			continue
+		}
+		switch ep.Status {
+		case types.SNATCHED_BEST:
+			upgrading = true
+			replaced = ep.Quality.String()
+		case types.SNATCHED_PROPER:
+			upgrading = true
+			replaced = fmt.Sprintf("%s revision %d", ep.Quality, ep.Version)
+		}
+	}
+	glog.Infof("Importing %s from %s", item.Path, client.Name())
+	eps, err := d.Postprocessor.ProcessPath(item.Path, show, glog.Infof)
+	if err != nil {
+		return err
+	}
+	if len(eps) == 0 {
+		return fmt.Errorf("No episodes were imported from %s", item.Path)
+	}
+	glog.Infof("Imported %d episodes from %s", len(eps), item.Name)
+	// The release name is what the quality and revision were judged on when
+	// it was grabbed.
+	grabbed := quality.QualityFromName(downloads[0].Name, show.Anime)
+	np := naming.NewNameParser(naming.StandardRegexes)
+	if show.Anime {
+		np = naming.NewNameParser(naming.AnimeRegex)
+	}
+	rev := np.Parse(downloads[0].Name).Revision
+	imported := make([]*db.Episode, len(eps))
+	for i := range eps {
+		imported[i] = &eps[i]
+		if grabbed != quality.UNKNOWN {
+			eps[i].Quality = grabbed
+		}
+		if rev > eps[i].Version {
+			eps[i].Version = rev
+		}
+	}
+	err = d.DBH.SaveEpisodes(imported)
+	if err != nil {
+		glog.Errorf("Error saving quality of %s: %s", item.Name, err)
+	}
+	hist := db.History{
+		Event:      db.HistoryImported,
+		Name:       downloads[0].Name,
+		Provider:   downloads[0].Provider,
+		Quality:    grabbed,
+		Size:       item.Size,
+		Client:     client.Name(),
+		DownloadID: item.ID,
+		Message:    item.Path,
+	}
+	if upgrading {
+		hist.Event = db.HistoryUpgraded
+		hist.Message = fmt.Sprintf("Replaced %s with %s", replaced, item.Path)
+	}
+	err = d.DBH.AddHistory(hist, imported)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", item.Name, err)
+	}
+
+	err = client.Remove(item.ID, false)
+	if err != nil {
+		glog.Errorf("Error removing %s from %s: %s", item.Name, client.Name(), err)
+	}
+	return d.DBH.DeleteDownloads(client.Name(), item.ID)
+}
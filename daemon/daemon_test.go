@@ -1,11 +1,23 @@
 package daemon
 
 import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/hobeone/tv2go/config"
 	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/downloaders"
 	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
+	"github.com/hobeone/tv2go/test_helpers"
+	"github.com/hobeone/tv2go/types"
 	. "github.com/onsi/gomega"
 )
 
@@ -14,7 +26,10 @@ func TestProcessProviderResult(t *testing.T) {
 
 	cfg := config.NewTestConfig()
 	//cfg.DB.Verbose = true
-	cfg.Providers = append(cfg.Providers, config.ProviderConfig{Name: "nzbsOrg", API: "123"})
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "nzbsOrg", Type: "nzbsorg", API: "123", Enabled: true},
+		config.ProviderConfig{Name: "nyaaTorrents", Type: "nyaatorrents", Enabled: true},
+	)
 	d := NewDaemon(cfg)
 
 	db.LoadFixtures(t, d.DBH)
@@ -37,3 +52,547 @@ func TestProcessProviderResult(t *testing.T) {
 	err := d.ProcessProviderResult(pr)
 	Expect(err).To(MatchError("Couldn't download : Get : unsupported protocol scheme \"\""))
 }
+
+func TestProcessProviderResultAirByDate(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "nzbsOrg", Type: "nzbsorg", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+
+	db.LoadFixtures(t, d.DBH)
+	show, err := d.DBH.GetShowByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	show.AirByDate = true
+	Expect(d.DBH.SaveShow(show)).ToNot(HaveOccurred())
+
+	pr := providers.ProviderResult{
+		Name:         "show1.2006.01.01.720p.HDTV.x264-GRP",
+		ProviderName: "nzbsOrg",
+	}
+	err = d.ProcessProviderResult(pr)
+	Expect(err).To(MatchError("Couldn't download : Get : unsupported protocol scheme \"\""))
+}
+
+func TestProcessProviderResultAbsoluteNumber(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "nyaaTorrents", Type: "nyaatorrents", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+
+	db.LoadFixtures(t, d.DBH)
+	show, err := d.DBH.GetShowByID(2)
+	Expect(err).ToNot(HaveOccurred())
+	show.Anime = true
+	Expect(d.DBH.SaveShow(show)).ToNot(HaveOccurred())
+
+	pr := providers.ProviderResult{
+		Name:         "[HorribleSubs] show2 - 02 [720p].mkv",
+		Anime:        true,
+		ProviderName: "nyaaTorrents",
+	}
+	err = d.ProcessProviderResult(pr)
+	Expect(err).To(MatchError("Couldn't download : Get : unsupported protocol scheme \"\""))
+}
+
+func TestProcessProviderResultSeasonPack(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "nzbsOrg", Type: "nzbsorg", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+
+	db.LoadFixtures(t, d.DBH)
+
+	pr := providers.ProviderResult{
+		Name:         "show1.S01.720p.HDTV.x264-GRP",
+		ProviderName: "nzbsOrg",
+	}
+	err := d.ProcessProviderResult(pr)
+	Expect(err).To(MatchError("Couldn't download : Get : unsupported protocol scheme \"\""))
+
+	pr.Name = "show2.S01.720p.HDTV.x264-GRP"
+	err = d.ProcessProviderResult(pr)
+	Expect(err).To(MatchError("Season pack show2.S01.720p.HDTV.x264-GRP has 1 wanted episodes, need 2, skipping"))
+}
+
+const searchFeed = `<?xml version="1.0" encoding="utf-8" ?>
+<rss version="2.0" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+<channel>
+<item>
+	<title>show1.S01E01.720p.HDTV.x264-BAD</title>
+	<guid isPermaLink="false">bad</guid>
+	<link>%[1]s/getnzb/bad.nzb</link>
+	<pubDate>Sun, 08 Mar 2015 14:43:47 +0000</pubDate>
+</item>
+<item>
+	<title>show1.S01E01.720p.HDTV.x264-GOOD</title>
+	<guid isPermaLink="false">good</guid>
+	<link>%[1]s/getnzb/good.nzb</link>
+	<pubDate>Sun, 08 Mar 2015 14:43:47 +0000</pubDate>
+</item>
+</channel>
+</rss>`
+
+const goodNZB = `<?xml version="1.0" encoding="utf-8" ?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+ <file poster="poster@example.com" date="1425825827" subject="show1.S01E01.720p.HDTV.x264-GOOD.rar yEnc (1/1)">
+   <groups><group>alt.binaries.teevee</group></groups>
+   <segments><segment bytes="400000" number="1">part1of1@example.com</segment></segments>
+ </file>
+</nzb>`
+
+func TestProcessProviderResultsTriesNextResult(t *testing.T) {
+	RegisterTestingT(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/getnzb/bad.nzb":
+			w.Write([]byte("<html><body>Download limit reached</body></html>"))
+		case r.URL.Path == "/getnzb/good.nzb":
+			w.Header().Set("Content-Disposition", "attachment; filename=good.nzb")
+			w.Write([]byte(goodNZB))
+		case r.URL.Query().Get("t") == "caps":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprintf(w, searchFeed, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	blackhole, err := ioutil.TempDir("", "tv2go_blackhole")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(blackhole)
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.NZBBlackhole = blackhole
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	results, err := d.Providers["test"].GetNewItems()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(results).To(HaveLen(2))
+	d.ProcessProviderResults(results)
+
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.SNATCHED))
+	Expect(ep.ReleaseName).To(Equal("show1.S01E01.720p.HDTV.x264-GOOD"))
+	_, err = os.Stat(filepath.Join(blackhole, "good.nzb"))
+	Expect(err).ToNot(HaveOccurred())
+
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(db.HistoryGrabbed))
+	Expect(history[0].Name).To(Equal("show1.S01E01.720p.HDTV.x264-GOOD"))
+	Expect(history[0].Provider).To(Equal("test"))
+	Expect(history[0].Quality).To(Equal(quality.HDTV))
+}
+
+func TestProcessProviderResultsGrabsBest(t *testing.T) {
+	RegisterTestingT(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment; filename=good.nzb")
+		w.Write([]byte(goodNZB))
+	}))
+	defer server.Close()
+
+	blackhole, err := ioutil.TempDir("", "tv2go_blackhole")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(blackhole)
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.NZBBlackhole = blackhole
+	cfg.Decisions.IgnoreWords = []string{"german"}
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	d.ProcessProviderResults([]providers.ProviderResult{
+		{Name: "show1.S01E01.HDTV.x264-SD", ProviderName: "test", URL: server.URL + "/sd.nzb"},
+		{Name: "show1.S01E01.GERMAN.1080p.WEB-DL.x264-GER", ProviderName: "test", URL: server.URL + "/ger.nzb"},
+		{Name: "show1.S01E01.720p.HDTV.x264-GOOD", ProviderName: "test", URL: server.URL + "/good.nzb"},
+		{Name: "show1.S01E01.720p.HDTV.x264-LATER", ProviderName: "test", URL: server.URL + "/later.nzb"},
+	})
+
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.SNATCHED))
+	Expect(ep.ReleaseName).To(Equal("show1.S01E01.720p.HDTV.x264-GOOD"))
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+}
+
+func TestCheckCompletedDownloads(t *testing.T) {
+	RegisterTestingT(t)
+
+	tmpdir, err := ioutil.TempDir("", "tv2go_completed")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(tmpdir)
+	downloadDir := filepath.Join(tmpdir, "downloads", "show1.S01E01.720p.HDTV.x264-GOOD")
+	Expect(os.MkdirAll(downloadDir, 0755)).ToNot(HaveOccurred())
+	mediaFile := filepath.Join(downloadDir, "show1.S01E01.720p.HDTV.x264-GOOD.mkv")
+	Expect(ioutil.WriteFile(mediaFile, []byte("video"), 0644)).ToNot(HaveOccurred())
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.Directories = []string{tmpdir}
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	show, err := d.DBH.GetShowByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	show.Location = filepath.Join(tmpdir, "show1")
+	Expect(d.DBH.SaveShow(show)).ToNot(HaveOccurred())
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+
+	client := &test_helpers.FakeDownloadClient{
+		HistoryItems: []downloaders.Item{
+			{ID: "other", Name: "Not.Ours", Status: downloaders.COMPLETED, Path: "/nonexistent"},
+			{ID: "nzo_1", Name: "show1.S01E01.720p.HDTV.x264-GOOD", Status: downloaders.COMPLETED, Path: downloadDir},
+		},
+	}
+	d.DownloadClients = downloaders.ClientRegistry{providers.NZB: client}
+	err = d.DBH.AddDownload(db.Download{
+		Client:     "fake",
+		DownloadID: "nzo_1",
+		Name:       "show1.S01E01.720p.HDTV.x264-GOOD",
+	}, []*db.Episode{ep})
+	Expect(err).ToNot(HaveOccurred())
+
+	d.CheckCompletedDownloads()
+
+	Expect(client.Removed).To(Equal([]string{"nzo_1"}))
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.DOWNLOADED))
+	_, err = os.Stat(ep.Location)
+	Expect(err).ToNot(HaveOccurred())
+	downloads, err := d.DBH.GetDownloads("fake", "nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(BeEmpty())
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(db.HistoryImported))
+	Expect(history[0].DownloadID).To(Equal("nzo_1"))
+}
+
+func TestCheckCompletedDownloadsGivesUp(t *testing.T) {
+	RegisterTestingT(t)
+
+	tmpdir, err := ioutil.TempDir("", "tv2go_completed")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(tmpdir)
+	downloadDir := filepath.Join(tmpdir, "downloads", "show1.S01E01.720p.HDTV.x264-GOOD")
+	Expect(os.MkdirAll(downloadDir, 0755)).ToNot(HaveOccurred())
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.Directories = []string{tmpdir}
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+
+	client := &test_helpers.FakeDownloadClient{
+		HistoryItems: []downloaders.Item{
+			{ID: "nzo_1", Name: "show1.S01E01.720p.HDTV.x264-GOOD", Status: downloaders.COMPLETED, Path: downloadDir},
+		},
+	}
+	d.DownloadClients = downloaders.ClientRegistry{providers.NZB: client}
+	err = d.DBH.AddDownload(db.Download{
+		Client:     "fake",
+		DownloadID: "nzo_1",
+		Name:       "show1.S01E01.720p.HDTV.x264-GOOD",
+	}, []*db.Episode{ep})
+	Expect(err).ToNot(HaveOccurred())
+
+	for i := 0; i < maxImportAttempts+2; i++ {
+		d.CheckCompletedDownloads()
+	}
+
+	Expect(client.Removed).To(BeEmpty())
+	downloads, err := d.DBH.GetDownloads("fake", "nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(HaveLen(1))
+	Expect(downloads[0].ImportAttempts).To(Equal(maxImportAttempts))
+	Expect(downloads[0].ImportError).To(ContainSubstring("Couldn't find any media files"))
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(db.HistoryImportFailed))
+	Expect(history[0].DownloadID).To(Equal("nzo_1"))
+}
+
+func TestUpgradeBelowCutoff(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment; filename=better.nzb")
+		w.Write([]byte(goodNZB))
+	}))
+	defer server.Close()
+
+	tmpdir, err := ioutil.TempDir("", "tv2go_upgrade")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(tmpdir)
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.Directories = []string{tmpdir}
+	cfg.Storage.NZBBlackhole = tmpdir
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	show, err := d.DBH.GetShowByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	show.Location = filepath.Join(tmpdir, "show1")
+	Expect(d.DBH.SaveShow(show)).ToNot(HaveOccurred())
+	show.QualityGroup.Cutoff = quality.HDWEBDL
+	Expect(d.DBH.SaveQualityGroup(&show.QualityGroup)).ToNot(HaveOccurred())
+
+	oldFile := filepath.Join(show.Location, "show1.S01E01.avi")
+	Expect(os.MkdirAll(show.Location, 0755)).ToNot(HaveOccurred())
+	Expect(ioutil.WriteFile(oldFile, []byte("old video"), 0644)).ToNot(HaveOccurred())
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep.Status = types.DOWNLOADED
+	ep.Quality = quality.HDTV
+	ep.Location = oldFile
+	Expect(d.DBH.SaveEpisode(ep)).ToNot(HaveOccurred())
+
+	d.ProcessProviderResults([]providers.ProviderResult{
+		{Name: "show1.S01E01.720p.HDTV.x264-SAME", ProviderName: "test", URL: server.URL + "/same.nzb"},
+		{Name: "show1.S01E01.720p.WEB-DL.DD5.1.H.264-BETTER", ProviderName: "test", URL: server.URL + "/better.nzb"},
+	})
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.SNATCHED_BEST))
+	Expect(ep.ReleaseName).To(Equal("show1.S01E01.720p.WEB-DL.DD5.1.H.264-BETTER"))
+
+	downloadDir := filepath.Join(tmpdir, "downloads", "show1.S01E01.720p.WEB-DL.DD5.1.H.264-BETTER")
+	Expect(os.MkdirAll(downloadDir, 0755)).ToNot(HaveOccurred())
+	mediaFile := filepath.Join(downloadDir, "show1.S01E01.720p.WEB-DL.DD5.1.H.264-BETTER.mkv")
+	Expect(ioutil.WriteFile(mediaFile, []byte("new video"), 0644)).ToNot(HaveOccurred())
+	client := &test_helpers.FakeDownloadClient{
+		HistoryItems: []downloaders.Item{
+			{ID: "nzo_1", Name: ep.ReleaseName, Status: downloaders.COMPLETED, Path: downloadDir},
+		},
+	}
+	d.DownloadClients = downloaders.ClientRegistry{providers.NZB: client}
+	err = d.DBH.AddDownload(db.Download{
+		Client:     "fake",
+		DownloadID: "nzo_1",
+		Name:       ep.ReleaseName,
+	}, []*db.Episode{ep})
+	Expect(err).ToNot(HaveOccurred())
+
+	d.CheckCompletedDownloads()
+
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.DOWNLOADED))
+	Expect(ep.Quality).To(Equal(quality.HDWEBDL))
+	Expect(ep.Location).ToNot(Equal(oldFile))
+	_, err = os.Stat(ep.Location)
+	Expect(err).ToNot(HaveOccurred())
+	_, err = os.Stat(oldFile)
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(2))
+	Expect(history[0].Event).To(Equal(db.HistoryUpgraded))
+	Expect(history[0].Message).To(HavePrefix("Replaced HD TV with "))
+	Expect(history[1].Event).To(Equal(db.HistoryGrabbed))
+
+	// Now it's at the cutoff it isn't upgraded again.
+	d.ProcessProviderResults([]providers.ProviderResult{
+		{Name: "show1.S01E01.720p.BluRay.x264-BEST", ProviderName: "test", URL: server.URL + "/best.nzb"},
+	})
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.DOWNLOADED))
+}
+
+func TestGrabProper(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", "attachment; filename=proper.nzb")
+		w.Write([]byte(goodNZB))
+	}))
+	defer server.Close()
+
+	tmpdir, err := ioutil.TempDir("", "tv2go_proper")
+	Expect(err).ToNot(HaveOccurred())
+	defer os.RemoveAll(tmpdir)
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.Directories = []string{tmpdir}
+	cfg.Storage.NZBBlackhole = tmpdir
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep.Status = types.DOWNLOADED
+	ep.Quality = quality.HDTV
+	ep.Version = 1
+	ep.AirDate = time.Now().Add(-30 * 24 * time.Hour)
+	Expect(d.DBH.SaveEpisode(ep)).ToNot(HaveOccurred())
+
+	results := []providers.ProviderResult{
+		{Name: "show1.S01E01.720p.HDTV.x264-GRP", ProviderName: "test", URL: server.URL + "/first.nzb"},
+		{Name: "show1.S01E01.PROPER.720p.HDTV.x264-GRP", ProviderName: "test", URL: server.URL + "/proper.nzb"},
+	}
+
+	// Too long after airing to be replaced.
+	d.ProcessProviderResults(results)
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.DOWNLOADED))
+
+	ep.AirDate = time.Now().Add(-24 * time.Hour)
+	Expect(d.DBH.SaveEpisode(ep)).ToNot(HaveOccurred())
+	d.ProcessProviderResults(results)
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.SNATCHED_PROPER))
+	Expect(ep.ReleaseName).To(Equal("show1.S01E01.PROPER.720p.HDTV.x264-GRP"))
+}
+
+func TestCheckFailedDownloads(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := config.NewTestConfig()
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+
+	client := &test_helpers.FakeDownloadClient{
+		HistoryItems: []downloaders.Item{
+			{ID: "nzo_1", Name: "show1.S01E01.720p.HDTV.x264-BAD", Status: downloaders.FAILED, Message: "Repair failed"},
+		},
+	}
+	d.DownloadClients = downloaders.ClientRegistry{providers.NZB: client}
+
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep.Status = types.SNATCHED
+	Expect(d.DBH.SaveEpisode(ep)).ToNot(HaveOccurred())
+	err = d.DBH.AddDownload(db.Download{
+		Client:     "fake",
+		DownloadID: "nzo_1",
+		Name:       "show1.S01E01.720p.HDTV.x264-BAD",
+		Provider:   "test",
+	}, []*db.Episode{ep})
+	Expect(err).ToNot(HaveOccurred())
+
+	d.CheckCompletedDownloads()
+
+	Expect(client.Removed).To(Equal([]string{"nzo_1"}))
+	ep, err = d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.WANTED))
+	blacklist, err := d.DBH.GetBlacklist()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(blacklist).To(HaveLen(1))
+	Expect(blacklist[0].Name).To(Equal("show1.S01E01.720p.HDTV.x264-BAD"))
+	Expect(blacklist[0].Reason).To(Equal("Repair failed"))
+	Expect(blacklist[0].EpisodeID).To(Equal(ep.ID))
+	history, _, err := d.DBH.GetHistory(db.HistoryFilter{EpisodeID: ep.ID})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(db.HistoryFailed))
+	Expect(history[0].Message).To(Equal("Repair failed"))
+	downloads, err := d.DBH.GetDownloads("fake", "nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(BeEmpty())
+
+	pr := providers.ProviderResult{
+		Name:         "show1.S01E01.720p.HDTV.x264-BAD",
+		ProviderName: "test",
+	}
+	err = d.ProcessProviderResult(pr)
+	Expect(err).To(MatchError("Rejected show1.S01E01.720p.HDTV.x264-BAD: Blacklisted"))
+}
+
+func TestCheckTimedOutDownloads(t *testing.T) {
+	RegisterTestingT(t)
+
+	cfg := config.NewTestConfig()
+	d := NewDaemon(cfg)
+	db.LoadFixtures(t, d.DBH)
+	client := &test_helpers.FakeDownloadClient{
+		HistoryItems: []downloaders.Item{
+			{ID: "finished", Name: "show2.S01E01-FINISHED", Status: downloaders.COMPLETED},
+		},
+	}
+	down := &test_helpers.FakeDownloadClient{ClientName: "down", Err: errors.New("connection refused")}
+	d.DownloadClients = downloaders.ClientRegistry{providers.NZB: client, providers.TORRENT: down}
+
+	eps := make([]*db.Episode, 4)
+	for i := range eps {
+		ep, err := d.DBH.GetEpisodeByID(int64(i + 1))
+		Expect(err).ToNot(HaveOccurred())
+		ep.Status = types.SNATCHED
+		eps[i] = ep
+	}
+	eps[1].Status = types.DOWNLOADED
+	Expect(d.DBH.SaveEpisodes(eps)).ToNot(HaveOccurred())
+
+	old := time.Now().Add(-time.Duration(cfg.Downloads.Timeout)*time.Hour - time.Hour)
+	Expect(d.DBH.AddDownload(db.Download{Client: "fake", DownloadID: "stuck", Name: "show1.S01E01-STUCK", Added: old}, eps[0:1])).ToNot(HaveOccurred())
+	Expect(d.DBH.AddDownload(db.Download{Client: "fake", DownloadID: "done", Name: "show1.S01E02-DONE", Added: old}, eps[1:2])).ToNot(HaveOccurred())
+	Expect(d.DBH.AddDownload(db.Download{Client: "fake", DownloadID: "finished", Name: "show2.S01E01-FINISHED", Added: old}, eps[2:3])).ToNot(HaveOccurred())
+	Expect(d.DBH.AddDownload(db.Download{Client: "down", DownloadID: "unknown", Name: "show2.S02E01-UNKNOWN", Added: old}, eps[3:4])).ToNot(HaveOccurred())
+
+	d.CheckTimedOutDownloads()
+
+	Expect(client.Removed).To(Equal([]string{"stuck"}))
+	Expect(down.Removed).To(BeEmpty())
+	ep, err := d.DBH.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.WANTED))
+	listed, err := d.DBH.IsBlacklisted("show1.S01E01-STUCK")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeTrue())
+	listed, err = d.DBH.IsBlacklisted("show1.S01E02-DONE")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeFalse())
+	all, err := d.DBH.GetAllDownloads()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(HaveLen(2))
+	Expect(all[0].DownloadID).To(Equal("finished"))
+	Expect(all[1].DownloadID).To(Equal("unknown"))
+
+	// With no timeout nothing is failed.
+	cfg.Downloads.Timeout = 0
+	down.Err = nil
+	d.CheckTimedOutDownloads()
+	all, err = d.DBH.GetAllDownloads()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(HaveLen(2))
+}
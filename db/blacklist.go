@@ -0,0 +1,72 @@
+package db
+
+import (
+	"time"
+)
+
+// Blacklist records a release that failed to download so it isn't grabbed
+// again.
+type Blacklist struct {
+	ID        int64 `gorm:"column:id; primary_key:yes"`
+	ShowID    int64
+	EpisodeID int64
+	Name      string // release name
+	Provider  string
+	URL       string
+	Reason    string
+	Added     time.Time
+}
+
+// AfterFind updates all times to UTC because SQLite driver sets everything to local
+func (b *Blacklist) AfterFind() error {
+	b.Added = b.Added.UTC()
+	return nil
+}
+
+// AddBlacklist blacklists a release.
+func (h *Handle) AddBlacklist(b *Blacklist) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	if b.Added.IsZero() {
+		b.Added = time.Now()
+	}
+	return h.db.Save(b).Error
+}
+
+// IsBlacklisted returns true if a release with the given name has been
+// blacklisted.
+func (h *Handle) IsBlacklisted(name string) (bool, error) {
+	var count int
+	err := h.db.Model(&Blacklist{}).Where("name = ?", name).Count(&count).Error
+	return count > 0, err
+}
+
+// GetBlacklist returns all the blacklisted releases, newest first.
+func (h *Handle) GetBlacklist() ([]Blacklist, error) {
+	var blacklist []Blacklist
+	err := h.db.Order("added desc").Find(&blacklist).Error
+	return blacklist, err
+}
+
+// DeleteBlacklist removes the blacklist entry with the given id, letting the
+// release be grabbed again.
+func (h *Handle) DeleteBlacklist(id int64) error {
+	var b Blacklist
+	err := h.db.Find(&b, id).Error
+	if err != nil {
+		return err
+	}
+	if !h.writeUpdates {
+		return nil
+	}
+	return h.db.Delete(&b).Error
+}
+
+// ClearBlacklist removes every blacklist entry.
+func (h *Handle) ClearBlacklist() error {
+	if !h.writeUpdates {
+		return nil
+	}
+	return h.db.Delete(Blacklist{}).Error
+}
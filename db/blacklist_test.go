@@ -0,0 +1,44 @@
+package db
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestBlacklist(t *testing.T) {
+	d := setupTest(t)
+
+	b := &Blacklist{
+		ShowID:    1,
+		EpisodeID: 1,
+		Name:      "show1.S01E01.720p-GRP",
+		Provider:  "nzbsOrg",
+		Reason:    "Repair failed",
+	}
+	Expect(d.AddBlacklist(b)).ToNot(HaveOccurred())
+	Expect(b.ID).ToNot(BeZero())
+	Expect(d.AddBlacklist(&Blacklist{Name: "show1.S01E02.720p-GRP"})).ToNot(HaveOccurred())
+
+	listed, err := d.IsBlacklisted("show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeTrue())
+	listed, err = d.IsBlacklisted("show1.S01E01.720p-OTHER")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeFalse())
+
+	all, err := d.GetBlacklist()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(HaveLen(2))
+
+	Expect(d.DeleteBlacklist(b.ID)).ToNot(HaveOccurred())
+	Expect(d.DeleteBlacklist(b.ID)).To(HaveOccurred())
+	listed, err = d.IsBlacklisted("show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeFalse())
+
+	Expect(d.ClearBlacklist()).ToNot(HaveOccurred())
+	all, err = d.GetBlacklist()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(BeEmpty())
+}
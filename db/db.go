@@ -37,6 +37,11 @@ func setupDB(db gorm.DB) error {
 		&quality.QualityGroup{},
 		&NameException{},
 		&LastPollTime{},
+		&SeenRelease{},
+		&ProviderState{},
+		&Download{},
+		&Blacklist{},
+		&History{},
 	).Error
 	if err != nil {
 		tx.Rollback()
@@ -48,6 +53,10 @@ func setupDB(db gorm.DB) error {
 		"idx_show_season_ep", "show_id", "season", "episode",
 	)
 	tx.Model(&Show{}).AddUniqueIndex("idx_show_name", "name")
+	tx.Model(&SeenRelease{}).AddUniqueIndex("idx_seen_release_provider_guid", "provider", "guid")
+	tx.Model(&Download{}).AddIndex("idx_download_client_id", "client", "download_id")
+	tx.Model(&Blacklist{}).AddIndex("idx_blacklist_name", "name")
+	tx.Model(&History{}).AddIndex("idx_history_show_episode", "show_id", "episode_id")
 	tx.Model(&quality.QualityGroup{}).AddUniqueIndex("idx_quality_group_name", "name")
 	tx.Commit()
 	RunMigrations(&db)
@@ -119,12 +128,21 @@ func NewMemoryDBHandle(verbose bool, writeUpdates bool) *Handle {
 //
 // TODO: make this a little more sophisticated.
 func RunMigrations(dbh *gorm.DB) {
-	tx := dbh.Begin()
-	err := Migration001AddBaseData(tx)
-	if err != nil {
-		tx.Rollback()
+	migrations := []func(*gorm.DB) error{
+		Migration001AddBaseData,
+		Migration002OrderBaseQualityGroups,
+	}
+	// Each migration is run in its own transaction as they fail once they've
+	// already been applied.
+	for _, migration := range migrations {
+		tx := dbh.Begin()
+		err := migration(tx)
+		if err != nil {
+			tx.Rollback()
+			continue
+		}
+		tx.Commit()
 	}
-	tx.Commit()
 }
 
 // Migration001AddBaseData adds the baseline quality groups.
@@ -190,6 +208,40 @@ func Migration001AddBaseData(tx *gorm.DB) error {
 	return nil
 }
 
+// Migration002OrderBaseQualityGroups puts the qualities of the baseline
+// groups Migration001AddBaseData saved out of order in order from least to
+// most preferred.  Groups that have been changed since are left alone.
+func Migration002OrderBaseQualityGroups(tx *gorm.DB) error {
+	reorders := []struct {
+		name     string
+		old, new []quality.Quality
+	}{
+		{
+			name: "HDALL",
+			old:  []quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY, quality.FULLHDWEBDL, quality.FULLHDTV, quality.FULLHDBLURAY},
+			new:  []quality.Quality{quality.HDTV, quality.FULLHDTV, quality.HDWEBDL, quality.FULLHDWEBDL, quality.HDBLURAY, quality.FULLHDBLURAY},
+		},
+		{
+			name: "HD720p",
+			old:  []quality.Quality{quality.HDWEBDL, quality.HDBLURAY, quality.HDTV},
+			new:  []quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY},
+		},
+	}
+	for _, r := range reorders {
+		old := quality.QualityGroup{Qualities: r.old}
+		old.BeforeSave()
+		new := quality.QualityGroup{Qualities: r.new}
+		new.BeforeSave()
+		err := tx.Model(&quality.QualityGroup{}).
+			Where("name = ? and quality_string = ?", r.name, old.QualityString).
+			UpdateColumn("quality_string", new.QualityString).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Testing functionality
 
 // TestReporter is a shim interface so we don't need to include the testing
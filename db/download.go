@@ -0,0 +1,100 @@
+package db
+
+import (
+	"time"
+)
+
+// Download records a release sent to a download client so it can be matched
+// back to the episode it was grabbed for once the client is done with it.
+// Releases with more than one episode have a Download for each.
+type Download struct {
+	ID         int64  `gorm:"column:id; primary_key:yes"`
+	Client     string // name of the download client
+	DownloadID string // the client's id for the download
+	Name       string // release name
+	Provider   string
+	URL        string
+	ShowID     int64
+	EpisodeID  int64
+	Added      time.Time
+
+	ImportAttempts int    // times importing the finished download has failed
+	ImportError    string // why the last import failed
+}
+
+// AfterFind updates all times to UTC because SQLite driver sets everything to local
+func (d *Download) AfterFind() error {
+	d.Added = d.Added.UTC()
+	return nil
+}
+
+// AddDownload saves a copy of the given download for each of the episodes.
+func (h *Handle) AddDownload(d Download, eps []*Episode) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	if d.Added.IsZero() {
+		d.Added = time.Now()
+	}
+	tx := h.db.Begin()
+	for _, ep := range eps {
+		epd := d
+		epd.ShowID = ep.ShowId
+		epd.EpisodeID = ep.ID
+		err := tx.Save(&epd).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+// GetDownloads returns the records for the download with the given client
+// and id.  It returns an empty list if tv2go didn't grab the download.
+func (h *Handle) GetDownloads(client, downloadID string) ([]Download, error) {
+	var downloads []Download
+	err := h.db.Where("client = ? and download_id = ?", client, downloadID).Find(&downloads).Error
+	return downloads, err
+}
+
+// GetAllDownloads returns every download tv2go is tracking, oldest first.
+func (h *Handle) GetAllDownloads() ([]Download, error) {
+	var downloads []Download
+	err := h.db.Order("added").Find(&downloads).Error
+	return downloads, err
+}
+
+// DeleteDownloads stops tracking the download with the given client and id.
+func (h *Handle) DeleteDownloads(client, downloadID string) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	return h.db.Where("client = ? and download_id = ?", client, downloadID).Delete(Download{}).Error
+}
+
+// RecordImportFailure records a failed attempt to import the download with
+// the given client and id.  It returns how many attempts have failed.
+func (h *Handle) RecordImportFailure(client, downloadID, reason string) (int, error) {
+	downloads, err := h.GetDownloads(client, downloadID)
+	if err != nil || len(downloads) == 0 {
+		return 0, err
+	}
+	attempts := downloads[0].ImportAttempts + 1
+	if !h.writeUpdates {
+		return attempts, nil
+	}
+	tx := h.db.Begin()
+	for _, dl := range downloads {
+		dl.ImportAttempts = attempts
+		dl.ImportError = reason
+		err = tx.Save(&dl).Error
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	tx.Commit()
+	return attempts, nil
+}
@@ -0,0 +1,61 @@
+package db
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestDownloads(t *testing.T) {
+	d := setupTest(t)
+
+	ep1, err := d.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep2, err := d.GetEpisodeByID(2)
+	Expect(err).ToNot(HaveOccurred())
+
+	err = d.AddDownload(Download{
+		Client:     "sabnzbd",
+		DownloadID: "SABnzbd_nzo_1",
+		Name:       "show1.S01E01E02.720p-GRP",
+		Provider:   "nzbsOrg",
+	}, []*Episode{ep1, ep2})
+	Expect(err).ToNot(HaveOccurred())
+
+	downloads, err := d.GetDownloads("sabnzbd", "SABnzbd_nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(HaveLen(2))
+	Expect(downloads[0].EpisodeID).To(Equal(ep1.ID))
+	Expect(downloads[0].ShowID).To(Equal(ep1.ShowId))
+	Expect(downloads[1].EpisodeID).To(Equal(ep2.ID))
+	Expect(downloads[0].Added.IsZero()).To(BeFalse())
+
+	downloads, err = d.GetDownloads("nzbget", "SABnzbd_nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(BeEmpty())
+
+	all, err := d.GetAllDownloads()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(HaveLen(2))
+
+	attempts, err := d.RecordImportFailure("sabnzbd", "SABnzbd_nzo_1", "No episodes were imported")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(attempts).To(Equal(1))
+	attempts, err = d.RecordImportFailure("sabnzbd", "SABnzbd_nzo_1", "Still nothing")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(attempts).To(Equal(2))
+	downloads, err = d.GetDownloads("sabnzbd", "SABnzbd_nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	for _, dl := range downloads {
+		Expect(dl.ImportAttempts).To(Equal(2))
+		Expect(dl.ImportError).To(Equal("Still nothing"))
+	}
+	attempts, err = d.RecordImportFailure("sabnzbd", "unknown", "Not tracked")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(attempts).To(BeZero())
+
+	Expect(d.DeleteDownloads("sabnzbd", "SABnzbd_nzo_1")).ToNot(HaveOccurred())
+	all, err = d.GetAllDownloads()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(BeEmpty())
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/naming"
 	"github.com/hobeone/tv2go/quality"
 	"github.com/hobeone/tv2go/types"
 	"github.com/jinzhu/gorm"
@@ -33,6 +34,7 @@ type Episode struct {
 	SceneAbsoluteNumber int64
 	Version             int64
 	ReleaseGroup        string
+	LastSearched        time.Time // when the backlog last searched providers for it
 }
 
 // BeforeSave performs validation on the record before saving
@@ -56,6 +58,7 @@ func (e *Episode) BeforeSave() error {
 // AfterFind fixes the SQLite driver sets everything to local
 func (e *Episode) AfterFind() error {
 	e.AirDate = e.AirDate.UTC()
+	e.LastSearched = e.LastSearched.UTC()
 	return nil
 }
 
@@ -92,6 +95,104 @@ func (h *Handle) GetEpisodeByShowSeasonAndNumber(showid, season, number int64) (
 	return &eps[0], nil
 }
 
+// GetEpisodeByShowAndAirDate returns the episode of the show that aired on the
+// given day.
+func (h *Handle) GetEpisodeByShowAndAirDate(showid int64, airdate time.Time) (*Episode, error) {
+	var eps []Episode
+
+	day := time.Date(airdate.Year(), airdate.Month(), airdate.Day(), 0, 0, 0, 0, time.UTC)
+	err := h.db.Where("show_id = ? and air_date >= ? and air_date < ?", showid, day, day.AddDate(0, 0, 1)).Find(&eps).Error
+
+	if err != nil {
+		return nil, err
+	}
+	if len(eps) == 0 {
+		return nil, gorm.RecordNotFound
+	}
+
+	return &eps[0], nil
+}
+
+// GetEpisodeByShowAndAbsoluteNumber returns the episode of the show with the
+// given absolute number.  The scene absolute number is used in preference to
+// the indexer's when it's set.
+func (h *Handle) GetEpisodeByShowAndAbsoluteNumber(showid, number int64) (*Episode, error) {
+	var eps []Episode
+
+	err := h.db.Where("show_id = ? and (scene_absolute_number = ? or (scene_absolute_number = 0 and absolute_number = ?))", showid, number, number).Find(&eps).Error
+
+	if err != nil {
+		return nil, err
+	}
+	if len(eps) == 0 {
+		return nil, gorm.RecordNotFound
+	}
+
+	return &eps[0], nil
+}
+
+// GetEpisodeByParseResult finds the episode of show a parsed release or file
+// name refers to.  Air by date shows are matched on the parsed air date when
+// there is one and anime on the absolute episode number, falling back to
+// treating it as an episode of the parsed season (as when a name exception
+// maps a name to a season).  Otherwise the season and first episode number
+// are used.
+func (h *Handle) GetEpisodeByParseResult(show *Show, pr *naming.ParseResult) (*Episode, error) {
+	if show.AirByDate && !pr.AirDate.IsZero() {
+		return h.GetEpisodeByShowAndAirDate(show.ID, pr.AirDate)
+	}
+	if show.Anime && len(pr.EpisodeNumbers) == 0 && len(pr.AbsoluteEpisodeNumbers) > 0 {
+		ep, err := h.GetEpisodeByShowAndAbsoluteNumber(show.ID, pr.AbsoluteEpisodeNumbers[0])
+		if err == nil {
+			return ep, nil
+		}
+		glog.Infof("No episode of %s with absolute number %d, trying season %d", show.Name, pr.AbsoluteEpisodeNumbers[0], pr.SeasonNumber)
+	}
+	return h.GetEpisodeByShowSeasonAndNumber(show.ID, pr.SeasonNumber, pr.FirstEpisode())
+}
+
+// GetEpisodesByParseResult is like GetEpisodeByParseResult but also returns
+// every other episode covered by a multi-episode file or release, such as
+// S01E01-E03.
+func (h *Handle) GetEpisodesByParseResult(show *Show, pr *naming.ParseResult) ([]*Episode, error) {
+	ep, err := h.GetEpisodeByParseResult(show, pr)
+	if err != nil {
+		return nil, err
+	}
+	eps := []*Episode{ep}
+	if len(pr.EpisodeNumbers) < 2 {
+		return eps, nil
+	}
+	last := pr.EpisodeNumbers[len(pr.EpisodeNumbers)-1]
+	for num := ep.Episode + 1; num <= last; num++ {
+		extra, err := h.GetEpisodeByShowSeasonAndNumber(show.ID, ep.Season, num)
+		if err != nil {
+			glog.Warningf("Couldn't find %s S%dE%d from %s: %s", show.Name, ep.Season, num, pr.OriginalName, err)
+			continue
+		}
+		eps = append(eps, extra)
+	}
+	return eps, nil
+}
+
+// GetSeasonEpisodes returns all of the episodes in a season of the show.
+func (h *Handle) GetSeasonEpisodes(showid, season int64) ([]Episode, error) {
+	var eps []Episode
+	err := h.db.Where("show_id = ? and season = ?", showid, season).Order("episode").Find(&eps).Error
+	return eps, err
+}
+
+// GetWantedEpisodes returns the WANTED episodes of shows that aren't paused,
+// newest first, with their shows loaded.
+func (h *Handle) GetWantedEpisodes() ([]Episode, error) {
+	var eps []Episode
+	err := h.db.Preload("Show").Select("episode.*").
+		Joins("join show on show.id = episode.show_id").
+		Where("episode.status = ? and show.paused = ?", types.WANTED, false).
+		Order("episode.air_date desc").Find(&eps).Error
+	return eps, err
+}
+
 // SaveEpisode saves the given episode to the database
 func (h *Handle) SaveEpisode(e *Episode) error {
 	if h.writeUpdates {
@@ -2,6 +2,7 @@ package db
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
 )
@@ -29,3 +30,66 @@ func TestEpisodeValidations(t *testing.T) {
 	err = d.SaveEpisode(dbep)
 	Expect(err).To(MatchError("Episode must be set"))
 }
+
+func TestGetEpisodeByShowAndAirDate(t *testing.T) {
+	d := setupTest(t)
+
+	ep, err := d.GetEpisodeByShowAndAirDate(1, time.Date(2006, time.January, 1, 0, 0, 0, 0, time.UTC))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Name).To(Equal("show1episode1"))
+
+	_, err = d.GetEpisodeByShowAndAirDate(1, time.Date(2006, time.January, 2, 0, 0, 0, 0, time.UTC))
+	Expect(err).To(HaveOccurred())
+}
+
+func TestGetEpisodeByShowAndAbsoluteNumber(t *testing.T) {
+	d := setupTest(t)
+
+	ep, err := d.GetEpisodeByShowAndAbsoluteNumber(2, 2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Name).To(Equal("show2episode2"))
+
+	ep.SceneAbsoluteNumber = 5
+	Expect(d.SaveEpisode(ep)).ToNot(HaveOccurred())
+
+	_, err = d.GetEpisodeByShowAndAbsoluteNumber(2, 2)
+	Expect(err).To(HaveOccurred())
+	ep, err = d.GetEpisodeByShowAndAbsoluteNumber(2, 5)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Name).To(Equal("show2episode2"))
+}
+
+func TestGetSeasonEpisodes(t *testing.T) {
+	d := setupTest(t)
+
+	eps, err := d.GetSeasonEpisodes(1, 1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(HaveLen(2))
+	Expect(eps[0].Name).To(Equal("show1episode1"))
+	Expect(eps[1].Name).To(Equal("show1episode2"))
+
+	eps, err = d.GetSeasonEpisodes(1, 2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(BeEmpty())
+}
+
+func TestGetWantedEpisodes(t *testing.T) {
+	d := setupTest(t)
+
+	eps, err := d.GetWantedEpisodes()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(HaveLen(4))
+	Expect(eps[0].Name).To(Equal("show1episode1"))
+	Expect(eps[0].Show.Name).To(Equal("show1"))
+	Expect(eps[1].Name).To(Equal("show2episode2"))
+
+	show, err := d.GetShowByID(2)
+	Expect(err).ToNot(HaveOccurred())
+	show.Paused = true
+	Expect(d.SaveShow(show)).ToNot(HaveOccurred())
+	eps, err = d.GetWantedEpisodes()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(eps).To(HaveLen(2))
+	Expect(eps[0].Name).To(Equal("show1episode1"))
+	Expect(eps[1].Name).To(Equal("show1episode2"))
+}
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"time"
+
+	"github.com/hobeone/tv2go/quality"
+)
+
+// History events
+const (
+	HistoryGrabbed      = "grabbed"       // sent to a download client
+	HistoryImported     = "imported"      // moved into the show's directory
+	HistoryFailed       = "failed"        // the download failed and was blacklisted
+	HistoryDeleted      = "deleted"       // removed from the download client or disk
+	HistoryUpgraded     = "upgraded"      // replaced an existing file
+	HistoryImportFailed = "import failed" // the finished download couldn't be imported
+)
+
+// History records something that happened to a release for an episode.
+type History struct {
+	ID         int64 `gorm:"column:id; primary_key:yes"`
+	ShowID     int64
+	EpisodeID  int64
+	Event      string
+	Name       string // release name
+	Provider   string
+	Quality    quality.Quality
+	Size       int64
+	Client     string // name of the download client
+	DownloadID string // the client's id for the download
+	Message    string
+	Date       time.Time
+}
+
+// AfterFind updates all times to UTC because SQLite driver sets everything to local
+func (h *History) AfterFind() error {
+	h.Date = h.Date.UTC()
+	return nil
+}
+
+// HistoryFilter selects a page of history.  Zero ids match everything.
+type HistoryFilter struct {
+	ShowID    int64
+	EpisodeID int64
+	Offset    int
+	Limit     int // no limit if <= 0
+}
+
+// AddHistory saves a copy of the given history event for each of the
+// episodes.
+func (h *Handle) AddHistory(hist History, eps []*Episode) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	if hist.Date.IsZero() {
+		hist.Date = time.Now()
+	}
+	tx := h.db.Begin()
+	for _, ep := range eps {
+		eph := hist
+		eph.ShowID = ep.ShowId
+		eph.EpisodeID = ep.ID
+		err := tx.Save(&eph).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	tx.Commit()
+	return nil
+}
+
+// GetHistory returns the history matching the filter, newest first, along
+// with how many events match in total.
+func (h *Handle) GetHistory(f HistoryFilter) ([]History, int, error) {
+	query := h.db.Model(&History{})
+	if f.ShowID != 0 {
+		query = query.Where("show_id = ?", f.ShowID)
+	}
+	if f.EpisodeID != 0 {
+		query = query.Where("episode_id = ?", f.EpisodeID)
+	}
+	var total int
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	query = query.Order("date desc, id desc").Offset(f.Offset)
+	if f.Limit > 0 {
+		query = query.Limit(f.Limit)
+	}
+	var history []History
+	err = query.Find(&history).Error
+	return history, total, err
+}
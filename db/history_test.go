@@ -0,0 +1,55 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hobeone/tv2go/quality"
+	. "github.com/onsi/gomega"
+)
+
+func TestHistory(t *testing.T) {
+	d := setupTest(t)
+
+	ep1, err := d.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep2, err := d.GetEpisodeByID(2)
+	Expect(err).ToNot(HaveOccurred())
+
+	grabbed := time.Now().Add(-time.Hour)
+	err = d.AddHistory(History{
+		Event:      HistoryGrabbed,
+		Name:       "show1.S01E01E02.720p.HDTV.x264-GRP",
+		Provider:   "nzbsOrg",
+		Quality:    quality.HDTV,
+		Size:       1000,
+		Client:     "sabnzbd",
+		DownloadID: "SABnzbd_nzo_1",
+		Date:       grabbed,
+	}, []*Episode{ep1, ep2})
+	Expect(err).ToNot(HaveOccurred())
+	err = d.AddHistory(History{
+		Event: HistoryImported,
+		Name:  "show1.S01E01E02.720p.HDTV.x264-GRP",
+	}, []*Episode{ep1})
+	Expect(err).ToNot(HaveOccurred())
+
+	history, total, err := d.GetHistory(HistoryFilter{})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(total).To(Equal(3))
+	Expect(history).To(HaveLen(3))
+	Expect(history[0].Event).To(Equal(HistoryImported))
+	Expect(history[1].Quality).To(Equal(quality.HDTV))
+
+	history, total, err = d.GetHistory(HistoryFilter{EpisodeID: ep2.ID})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(total).To(Equal(1))
+	Expect(history[0].Event).To(Equal(HistoryGrabbed))
+	Expect(history[0].ShowID).To(Equal(ep2.ShowId))
+
+	history, total, err = d.GetHistory(HistoryFilter{ShowID: ep1.ShowId, Offset: 1, Limit: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(total).To(Equal(3))
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(HistoryGrabbed))
+}
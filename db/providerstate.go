@@ -0,0 +1,47 @@
+package db
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// ProviderState stores a provider's request counts and failure backoff so
+// they survive restarts.
+type ProviderState struct {
+	ID            int64
+	Name          string
+	Failures      int       // consecutive failed requests
+	Disables      int       // times disabled since the last success
+	DisabledUntil time.Time // don't use the provider before this time
+	DayStart      time.Time // start of the current daily request budget
+	DayRequests   int       // requests made since DayStart
+}
+
+// AfterFind updates all times to UTC because SQLite driver sets everything to local
+func (s *ProviderState) AfterFind() error {
+	s.DisabledUntil = s.DisabledUntil.UTC()
+	s.DayStart = s.DayStart.UTC()
+	return nil
+}
+
+// GetProviderState returns the saved state for the named provider.  If there
+// is no saved state it returns a new, unsaved one.
+func (h *Handle) GetProviderState(name string) (*ProviderState, error) {
+	state := &ProviderState{
+		Name: name,
+	}
+	err := h.db.Where("name = ?", name).FirstOrInit(state).Error
+	if err != nil {
+		glog.Errorf("Couldn't find or create provider state: %s", err)
+	}
+	return state, err
+}
+
+// SaveProviderState saves the given provider state to the database.
+func (h *Handle) SaveProviderState(s *ProviderState) error {
+	if h.writeUpdates {
+		return h.db.Save(s).Error
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestProviderState(t *testing.T) {
+	d := setupTest(t)
+
+	state, err := d.GetProviderState("nzbsOrg")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(state.ID).To(BeZero())
+	Expect(state.Name).To(Equal("nzbsOrg"))
+
+	until := time.Date(2015, time.March, 4, 12, 0, 0, 0, time.UTC)
+	state.Failures = 5
+	state.DisabledUntil = until
+	Expect(d.SaveProviderState(state)).ToNot(HaveOccurred())
+
+	state, err = d.GetProviderState("nzbsOrg")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(state.ID).ToNot(BeZero())
+	Expect(state.Failures).To(Equal(5))
+	Expect(state.DisabledUntil).To(Equal(until))
+}
@@ -29,3 +29,46 @@ func (h *Handle) GetQualityGroupFromStringOrDefault(name string) *quality.Qualit
 	h.db.FirstOrInit(qual, quality.DefaultQualityGroup)
 	return qual
 }
+
+// GetQualityGroupByName returns the quality group with the given name or an
+// error if it doesn't exist.
+func (h *Handle) GetQualityGroupByName(name string) (*quality.QualityGroup, error) {
+	qg := &quality.QualityGroup{}
+	err := h.db.Where("name = ?", name).Find(qg).Error
+	return qg, err
+}
+
+// SaveQualityGroup saves the quality group, creating it if it doesn't exist.
+// There's only one default group so if it's the default any other group
+// stops being.
+func (h *Handle) SaveQualityGroup(qg *quality.QualityGroup) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	tx := h.db.Begin()
+	err := tx.Save(qg).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if qg.Default {
+		groups := []quality.QualityGroup{}
+		err = tx.Find(&groups).Error
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		for i := range groups {
+			if groups[i].ID == qg.ID || !groups[i].Default {
+				continue
+			}
+			groups[i].Default = false
+			err = tx.Save(&groups[i]).Error
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+	return tx.Commit().Error
+}
@@ -43,3 +43,67 @@ func TestGetQualityGroups(t *testing.T) {
 	Expect(err).ToNot(HaveOccurred())
 	Expect(qualityGroups).To(HaveLen(5))
 }
+
+func TestSaveQualityGroup(t *testing.T) {
+	d := setupTest(t)
+	RegisterTestingT(t)
+
+	qg, err := d.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(qg.Qualities).To(Equal([]quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY}))
+	qg.Cutoff = quality.HDWEBDL
+	Expect(d.SaveQualityGroup(qg)).ToNot(HaveOccurred())
+
+	qg, err = d.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(qg.Cutoff).To(Equal(quality.HDWEBDL))
+
+	_, err = d.GetQualityGroupByName("missing")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestSaveQualityGroupDefault(t *testing.T) {
+	d := setupTest(t)
+	RegisterTestingT(t)
+
+	qg, err := d.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	qg.Default = true
+	Expect(d.SaveQualityGroup(qg)).ToNot(HaveOccurred())
+
+	groups, err := d.GetQualityGroups()
+	Expect(err).ToNot(HaveOccurred())
+	defaults := []string{}
+	for _, g := range groups {
+		if g.Default {
+			defaults = append(defaults, g.Name)
+		}
+	}
+	Expect(defaults).To(Equal([]string{"HD720p"}))
+}
+
+func TestMigration002OrderBaseQualityGroups(t *testing.T) {
+	d := setupTest(t)
+	RegisterTestingT(t)
+
+	// Put HDALL back in the order older installs saved it in and change HD720p
+	// from its default.
+	hdall, err := d.GetQualityGroupByName("HDALL")
+	Expect(err).ToNot(HaveOccurred())
+	hdall.Qualities = []quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY, quality.FULLHDWEBDL, quality.FULLHDTV, quality.FULLHDBLURAY}
+	Expect(d.SaveQualityGroup(hdall)).ToNot(HaveOccurred())
+	custom := []quality.Quality{quality.HDBLURAY, quality.HDTV}
+	hd720p, err := d.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	hd720p.Qualities = custom
+	Expect(d.SaveQualityGroup(hd720p)).ToNot(HaveOccurred())
+
+	Expect(Migration002OrderBaseQualityGroups(&d.db)).ToNot(HaveOccurred())
+
+	hdall, err = d.GetQualityGroupByName("HDALL")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(hdall.Qualities).To(Equal([]quality.Quality{quality.HDTV, quality.FULLHDTV, quality.HDWEBDL, quality.FULLHDWEBDL, quality.HDBLURAY, quality.FULLHDBLURAY}))
+	hd720p, err = d.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(hd720p.Qualities).To(Equal(custom))
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"time"
+)
+
+// SeenRelease records a release a provider has returned so pollers only send
+// on new releases.
+type SeenRelease struct {
+	ID        int64 `gorm:"column:id; primary_key:yes"`
+	Provider  string
+	GUID      string `gorm:"column:guid"`
+	Name      string
+	FirstSeen time.Time
+}
+
+// AfterFind updates all times to UTC because SQLite driver sets everything to local
+func (s *SeenRelease) AfterFind() error {
+	s.FirstSeen = s.FirstSeen.UTC()
+	return nil
+}
+
+// AddSeenRelease records that the provider has returned the release with the
+// given guid.  It returns true if the release hadn't been seen before.
+func (h *Handle) AddSeenRelease(provider, guid, name string) (bool, error) {
+	var count int
+	err := h.db.Model(&SeenRelease{}).Where("provider = ? and guid = ?", provider, guid).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if !h.writeUpdates {
+		return true, nil
+	}
+	sr := &SeenRelease{
+		Provider:  provider,
+		GUID:      guid,
+		Name:      name,
+		FirstSeen: time.Now(),
+	}
+	return true, h.db.Save(sr).Error
+}
+
+// GetSeenReleases returns the releases seen from the given provider, newest
+// first.
+func (h *Handle) GetSeenReleases(provider string) ([]SeenRelease, error) {
+	var releases []SeenRelease
+	err := h.db.Where("provider = ?", provider).Order("first_seen desc").Find(&releases).Error
+	return releases, err
+}
+
+// PurgeSeenReleases removes the releases first seen before the given time.
+func (h *Handle) PurgeSeenReleases(before time.Time) error {
+	if !h.writeUpdates {
+		return nil
+	}
+	return h.db.Where("first_seen < ?", before).Delete(SeenRelease{}).Error
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSeenReleases(t *testing.T) {
+	d := setupTest(t)
+
+	isNew, err := d.AddSeenRelease("nzbsOrg", "abc123", "show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(isNew).To(BeTrue())
+
+	isNew, err = d.AddSeenRelease("nzbsOrg", "abc123", "show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(isNew).To(BeFalse())
+
+	isNew, err = d.AddSeenRelease("nyaaTorrents", "abc123", "show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(isNew).To(BeTrue())
+
+	releases, err := d.GetSeenReleases("nzbsOrg")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(releases).To(HaveLen(1))
+	Expect(releases[0].GUID).To(Equal("abc123"))
+
+	err = d.PurgeSeenReleases(time.Now().Add(time.Minute))
+	Expect(err).ToNot(HaveOccurred())
+	releases, err = d.GetSeenReleases("nzbsOrg")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(releases).To(BeEmpty())
+}
+
+func TestSeenReleasesReadOnly(t *testing.T) {
+	d := setupTest(t)
+	_, err := d.AddSeenRelease("nzbsOrg", "abc123", "show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+
+	d.writeUpdates = false
+	isNew, err := d.AddSeenRelease("nzbsOrg", "def456", "show1.S01E02.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(isNew).To(BeTrue())
+
+	err = d.PurgeSeenReleases(time.Now().Add(time.Minute))
+	Expect(err).ToNot(HaveOccurred())
+
+	releases, err := d.GetSeenReleases("nzbsOrg")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(releases).To(HaveLen(1))
+	Expect(releases[0].GUID).To(Equal("abc123"))
+}
@@ -70,6 +70,15 @@ func (s *Show) AfterFind() error {
 	return nil
 }
 
+// QualityProfile returns the show's quality group, or the default one if it
+// doesn't have any qualities.
+func (s *Show) QualityProfile() quality.QualityGroup {
+	if len(s.QualityGroup.Qualities) == 0 {
+		return quality.DefaultQualityGroup
+	}
+	return s.QualityGroup
+}
+
 // NextAirdateForShow returns the date of the next episode for this show.
 func (h *Handle) NextAirdateForShow(dbshow *Show) *time.Time {
 	var ep Episode
@@ -0,0 +1,340 @@
+// Package decision decides which releases from providers are worth grabbing
+// for an episode and ranks the ones that are.
+package decision
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
+	"github.com/hobeone/tv2go/types"
+)
+
+// Candidate is a provider result being considered for some episodes.
+type Candidate struct {
+	Result   providers.ProviderResult
+	Type     providers.ProviderType
+	Parse    naming.ParseResult
+	Quality  quality.Quality
+	Show     *db.Show      // the show the release is wanted for
+	Episodes []*db.Episode // the episodes the release is wanted for
+	Matched  []*db.Episode // the episodes the release's name matched
+	MatchErr error         // why the release's name didn't match any episodes
+}
+
+// Revision returns the candidate's revision, 1 for the first release.
+func (c *Candidate) Revision() int64 {
+	return revisionOf(c.Parse.Revision)
+}
+
+// IsProper returns true if the candidate is a PROPER, REPACK or newer version
+// of the downloaded episode's file: the same quality at a higher revision.
+func (c *Candidate) IsProper(ep *db.Episode) bool {
+	return ep.Status == types.DOWNLOADED && c.Quality == ep.Quality && c.Revision() > revisionOf(ep.Version)
+}
+
+// SnatchedStatus returns the status the episode gets when the candidate is
+// grabbed for it: SNATCHED_PROPER if it's a proper of the episode's file,
+// SNATCHED_BEST if it's replacing the file otherwise or else SNATCHED.
+func (c *Candidate) SnatchedStatus(ep *db.Episode) types.EpisodeStatus {
+	if c.IsProper(ep) {
+		return types.SNATCHED_PROPER
+	}
+	return ep.Status.Snatched()
+}
+
+// revisionOf treats an unknown revision as the first release.
+func revisionOf(rev int64) int64 {
+	if rev < 1 {
+		return 1
+	}
+	return rev
+}
+
+// Decision is a Candidate and the reasons it was rejected, if any.
+type Decision struct {
+	Candidate
+	Rejections []string
+}
+
+// Accepted returns true if the candidate passed every spec.
+func (d Decision) Accepted() bool {
+	return len(d.Rejections) == 0
+}
+
+// String describes the decision along with how the release's name and
+// quality were parsed, for logging.
+func (d Decision) String() string {
+	p := d.Parse
+	desc := fmt.Sprintf("%s from %s (quality %s, series '%s', season %d, episodes %v, absolute %v, air date %s, group '%s', regex %s)",
+		d.Result.Name, d.Result.ProviderName, d.Quality, p.SeriesName, p.SeasonNumber,
+		p.EpisodeNumbers, p.AbsoluteEpisodeNumbers, p.AirDate.Format("2006-01-02"), p.ReleaseGroup, p.RegexUsed)
+	if d.Accepted() {
+		return "Accepted " + desc
+	}
+	return fmt.Sprintf("Rejected %s: %s", desc, strings.Join(d.Rejections, "; "))
+}
+
+// Spec is a rule a Candidate has to pass to be grabbed.
+type Spec interface {
+	// Check returns why the candidate is rejected, or "" if it passes.
+	Check(c *Candidate) string
+}
+
+// Engine checks candidates against its specs and ranks the accepted ones.
+type Engine struct {
+	DBH             *db.Handle
+	Providers       providers.ProviderRegistry
+	Specs           []Spec
+	PreferredGroups []string
+	ProperWindow    time.Duration // how long after airing a downloaded episode can be replaced by a proper
+}
+
+// NewEngine creates an Engine with the specs set up from the config.
+func NewEngine(cfg *config.Config, dbh *db.Handle, provs providers.ProviderRegistry) *Engine {
+	rules := cfg.Decisions
+	window := time.Duration(rules.ProperWindow) * 24 * time.Hour
+	return &Engine{
+		DBH:       dbh,
+		Providers: provs,
+		Specs: []Spec{
+			episodeSpec{},
+			wantedSpec{ProperWindow: window},
+			qualitySpec{},
+			sizeSpec{Min: rules.MinSize, Max: rules.MaxSize},
+			wordsSpec{Ignore: rules.IgnoreWords, Require: rules.RequireWords},
+			seedersSpec{Min: rules.MinSeeders},
+			ageSpec{MaxDays: rules.MaxAge},
+			blacklistSpec{DBH: dbh},
+		},
+		PreferredGroups: rules.PreferredGroups,
+		ProperWindow:    window,
+	}
+}
+
+// providerType returns the type of provider the result came from.
+func (e *Engine) providerType(r providers.ProviderResult) providers.ProviderType {
+	if p, ok := e.Providers[r.ProviderName]; ok {
+		return p.Type()
+	}
+	return r.Type
+}
+
+// Match parses the result's name and finds the show and episodes it's of.
+// Season packs are matched to every episode in the season.
+func (e *Engine) Match(r providers.ProviderResult) (Candidate, error) {
+	c := Candidate{
+		Result: r,
+		Type:   e.providerType(r),
+	}
+	var np *naming.NameParser
+	if r.Anime {
+		np = naming.NewNameParser(naming.AnimeRegex)
+	} else {
+		np = naming.NewNameParser(naming.StandardRegexes)
+	}
+	pr := np.Parse(r.Name)
+	c.Parse = pr
+	c.Quality = quality.QualityFromName(r.Name, r.Anime)
+
+	if !pr.HasEpisode() && !pr.IsSeasonPack() {
+		return c, fmt.Errorf("Provider result %s had no episodes, skipping", pr.OriginalName)
+	}
+
+	dbshow, season, err := e.DBH.GetShowByAllNames(pr.SeriesName)
+
+	if err != nil {
+		return c, fmt.Errorf("Couldn't match '%s' to any known show name: %s", pr.SeriesName, err)
+	}
+
+	if season > -1 {
+		pr.SeasonNumber = season
+	}
+
+	if dbshow.Anime && !r.Anime && !pr.IsSeasonPack() {
+		apr := naming.NewNameParser(naming.AnimeRegex).Parse(r.Name)
+		if len(apr.AbsoluteEpisodeNumbers) > 0 {
+			pr.AbsoluteEpisodeNumbers = apr.AbsoluteEpisodeNumbers
+		}
+	}
+	c.Parse = pr
+	c.Show = dbshow
+	c.Quality = quality.QualityFromName(r.Name, dbshow.Anime || r.Anime)
+
+	if pr.IsSeasonPack() {
+		eps, err := e.DBH.GetSeasonEpisodes(dbshow.ID, pr.SeasonNumber)
+		if err != nil {
+			return c, fmt.Errorf("Error getting episodes for %s season %d: %s", dbshow.Name, pr.SeasonNumber, err)
+		}
+		for i := range eps {
+			c.Matched = append(c.Matched, &eps[i])
+		}
+		return c, nil
+	}
+
+	c.Matched, err = e.DBH.GetEpisodesByParseResult(dbshow, &pr)
+	if err != nil {
+		return c, fmt.Errorf("Can't find episode in DB for %s: %s", pr.OriginalName, err)
+	}
+	return c, nil
+}
+
+// candidateFor matches the result and sets it up to be checked for the given
+// episodes of the show.
+func (e *Engine) candidateFor(show *db.Show, eps []*db.Episode, r providers.ProviderResult) Candidate {
+	c, err := e.Match(r)
+	c.MatchErr = err
+	if c.Show == nil && show.Anime {
+		c.Quality = quality.QualityFromName(r.Name, true)
+	}
+	c.Show = show
+	c.Episodes = eps
+	return c
+}
+
+// Decide checks each candidate against the specs and returns the decisions
+// ranked best first.
+func (e *Engine) Decide(candidates []Candidate) []Decision {
+	decisions := make([]Decision, len(candidates))
+	for i, c := range candidates {
+		e.loadQualityGroup(c.Show)
+		decisions[i] = Decision{Candidate: c, Rejections: []string{}}
+		for _, spec := range e.Specs {
+			reason := spec.Check(&decisions[i].Candidate)
+			if reason != "" {
+				decisions[i].Rejections = append(decisions[i].Rejections, reason)
+			}
+		}
+		glog.Info(decisions[i].String())
+	}
+	e.Rank(decisions)
+	return decisions
+}
+
+// Evaluate decides which of the results from searching for the episodes of
+// show are acceptable.  The decisions are ranked best first.
+func (e *Engine) Evaluate(show *db.Show, eps []*db.Episode, results []providers.ProviderResult) []Decision {
+	candidates := make([]Candidate, len(results))
+	for i, r := range results {
+		candidates[i] = e.candidateFor(show, eps, r)
+	}
+	return e.Decide(candidates)
+}
+
+// loadQualityGroup makes sure the show's quality group has been loaded, as
+// shows loaded along with an episode don't have it.
+func (e *Engine) loadQualityGroup(show *db.Show) {
+	if show == nil || show.QualityGroup.ID != 0 || show.QualityGroupID == 0 || e.DBH == nil {
+		return
+	}
+	dbshow, err := e.DBH.GetShowByID(show.ID)
+	if err != nil {
+		glog.Errorf("Error loading quality group for %s: %s", show.Name, err)
+		return
+	}
+	show.QualityGroup = dbshow.QualityGroup
+}
+
+// Rank sorts the decisions best first: accepted before rejected, then by
+// the show's preference for their quality, revision, preferred release group
+// and seeders.  Otherwise the order is unchanged.
+func (e *Engine) Rank(decisions []Decision) {
+	sort.Stable(byRank{decisions: decisions, preferred: e.PreferredGroups})
+}
+
+// Best returns the best accepted decision, or nil if there aren't any.  The
+// decisions need to have been ranked.
+func Best(decisions []Decision) *Decision {
+	if len(decisions) > 0 && decisions[0].Accepted() {
+		return &decisions[0]
+	}
+	return nil
+}
+
+// Accepted returns the accepted decisions.
+func Accepted(decisions []Decision) []Decision {
+	accepted := []Decision{}
+	for _, d := range decisions {
+		if d.Accepted() {
+			accepted = append(accepted, d)
+		}
+	}
+	return accepted
+}
+
+// Wanted returns the episodes releases are wanted for: the WANTED ones, the
+// DOWNLOADED ones below the cutoff of the show's quality group and the
+// DOWNLOADED ones recent enough to be replaced by a proper.
+func (e *Engine) Wanted(show *db.Show, eps []*db.Episode) []*db.Episode {
+	profile := qualityProfile(show)
+	wanted := []*db.Episode{}
+	for _, ep := range eps {
+		if ep.Status == types.WANTED || (ep.Status == types.DOWNLOADED && (!profile.CutoffMet(ep.Quality) || inProperWindow(ep, e.ProperWindow))) {
+			wanted = append(wanted, ep)
+		}
+	}
+	return wanted
+}
+
+// inProperWindow returns true if the episode aired recently enough for its
+// file to be replaced by a proper.
+func inProperWindow(ep *db.Episode, window time.Duration) bool {
+	return window > 0 && !ep.AirDate.IsZero() && time.Since(ep.AirDate) <= window
+}
+
+// qualityProfile returns the show's quality group, or the default one if
+// there isn't a show.
+func qualityProfile(show *db.Show) quality.QualityGroup {
+	if show == nil {
+		return quality.DefaultQualityGroup
+	}
+	return show.QualityProfile()
+}
+
+type byRank struct {
+	decisions []Decision
+	preferred []string
+}
+
+func (r byRank) Len() int      { return len(r.decisions) }
+func (r byRank) Swap(i, j int) { r.decisions[i], r.decisions[j] = r.decisions[j], r.decisions[i] }
+
+func (r byRank) Less(i, j int) bool {
+	a, b := r.decisions[i], r.decisions[j]
+	if a.Accepted() != b.Accepted() {
+		return a.Accepted()
+	}
+	qa, qb := qualityProfile(a.Show).Rank(a.Quality), qualityProfile(b.Show).Rank(b.Quality)
+	if qa != qb {
+		return qa > qb
+	}
+	if ra, rb := a.Revision(), b.Revision(); ra != rb {
+		return ra > rb
+	}
+	pa, pb := r.groupRank(a), r.groupRank(b)
+	if pa != pb {
+		return pa < pb
+	}
+	if a.Type == providers.TORRENT && b.Type == providers.TORRENT {
+		return a.Result.Seeders > b.Result.Seeders
+	}
+	return false
+}
+
+// groupRank returns the position of the candidate's release group in the
+// preferred groups, or the number of them if it isn't one.
+func (r byRank) groupRank(d Decision) int {
+	for i, g := range r.preferred {
+		if strings.EqualFold(g, d.Parse.ReleaseGroup) {
+			return i
+		}
+	}
+	return len(r.preferred)
+}
@@ -0,0 +1,223 @@
+package decision
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
+	"github.com/hobeone/tv2go/types"
+	. "github.com/onsi/gomega"
+)
+
+func testCandidate(name string, q quality.Quality) Candidate {
+	ep := &db.Episode{ID: 1, Season: 1, Episode: 1, Status: types.WANTED}
+	return Candidate{
+		Result:   providers.ProviderResult{Name: name},
+		Parse:    naming.ParseResult{OriginalName: name, SeasonNumber: 1, EpisodeNumbers: []int64{1}},
+		Quality:  q,
+		Show:     &db.Show{Name: "show1"},
+		Episodes: []*db.Episode{ep},
+		Matched:  []*db.Episode{ep},
+	}
+}
+
+func TestSpecs(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := testCandidate("show1.S01E01.720p.HDTV.x264-GRP", quality.HDTV)
+	Expect(episodeSpec{}.Check(&c)).To(BeEmpty())
+	Expect(wantedSpec{}.Check(&c)).To(BeEmpty())
+	Expect(qualitySpec{}.Check(&c)).To(BeEmpty())
+
+	c.MatchErr = errors.New("Couldn't match")
+	Expect(episodeSpec{}.Check(&c)).To(Equal("Couldn't match"))
+	c.MatchErr = nil
+	c.Matched = []*db.Episode{&db.Episode{ID: 2}}
+	Expect(episodeSpec{}.Check(&c)).To(Equal("Doesn't contain S1E1"))
+
+	c.Episodes[0].Status = types.ARCHIVED
+	Expect(wantedSpec{}.Check(&c)).To(Equal("S1E1 is ARCHIVED, not wanted"))
+
+	c.Quality = quality.SDTV
+	Expect(qualitySpec{}.Check(&c)).To(ContainSubstring("isn't allowed"))
+	c.Show.QualityGroup = quality.QualityGroup{Name: "SD", Qualities: []quality.Quality{quality.SDTV}}
+	Expect(qualitySpec{}.Check(&c)).To(BeEmpty())
+
+	c.Result.Size = 50 * 1024 * 1024
+	Expect(sizeSpec{Min: 100}.Check(&c)).To(Equal("50MB per episode is smaller than 100MB"))
+	Expect(sizeSpec{Max: 40}.Check(&c)).To(Equal("50MB per episode is bigger than 40MB"))
+	Expect(sizeSpec{Min: 10, Max: 100}.Check(&c)).To(BeEmpty())
+
+	Expect(wordsSpec{Ignore: []string{"hdtv"}}.Check(&c)).To(Equal("Contains ignored word hdtv"))
+	Expect(wordsSpec{Require: []string{"WEB-DL", "BluRay"}}.Check(&c)).To(Equal("Doesn't contain any of WEB-DL, BluRay"))
+	Expect(wordsSpec{Require: []string{"x264"}}.Check(&c)).To(BeEmpty())
+
+	Expect(seedersSpec{Min: 1}.Check(&c)).To(BeEmpty())
+	c.Type = providers.TORRENT
+	Expect(seedersSpec{Min: 1}.Check(&c)).To(Equal("0 seeders is less than 1"))
+
+	Expect(ageSpec{MaxDays: 10}.Check(&c)).To(BeEmpty())
+	age := time.Now().Add(-time.Hour * 24 * 20)
+	c.Result.Age = &age
+	Expect(ageSpec{MaxDays: 10}.Check(&c)).To(Equal("20 days old is older than 10"))
+}
+
+func TestSeasonPackForOneEpisode(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := testCandidate("show1.S01.720p.HDTV.x264-GRP", quality.HDTV)
+	c.Parse.EpisodeNumbers = []int64{}
+	c.Parse.RegexUsed = "season_only"
+	Expect(c.Parse.IsSeasonPack()).To(BeTrue())
+	Expect(episodeSpec{}.Check(&c)).To(Equal("Season pack with 1 wanted episodes, need 2"))
+}
+
+func TestSizeOfSeasonPack(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := testCandidate("show1.S01.720p.HDTV.x264-GRP", quality.HDTV)
+	c.Matched = []*db.Episode{}
+	for i := int64(1); i <= 10; i++ {
+		c.Matched = append(c.Matched, &db.Episode{ID: i, Season: 1, Episode: i})
+	}
+	c.Episodes = c.Matched[:2]
+	c.Result.Size = 10 * 500 * 1024 * 1024
+	Expect(sizeSpec{Max: 1000}.Check(&c)).To(BeEmpty())
+	Expect(sizeSpec{Max: 400}.Check(&c)).To(Equal("500MB per episode is bigger than 400MB"))
+}
+
+func TestDecideRanksCandidates(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := &Engine{
+		Specs:           []Spec{qualitySpec{}, wordsSpec{Ignore: []string{"german"}}},
+		PreferredGroups: []string{"BEST", "GOOD"},
+	}
+	sd := testCandidate("show1.S01E01.HDTV.x264-BEST", quality.SDTV)
+	hd := testCandidate("show1.S01E01.720p.HDTV.x264-OTHER", quality.HDTV)
+	good := testCandidate("show1.S01E01.720p.HDTV.x264-GOOD", quality.HDTV)
+	good.Parse.ReleaseGroup = "GOOD"
+	german := testCandidate("show1.S01E01.GERMAN.1080p.WEB-DL-BEST", quality.FULLHDWEBDL)
+	german.Parse.ReleaseGroup = "BEST"
+
+	decisions := e.Decide([]Candidate{sd, hd, german, good})
+	Expect(decisions).To(HaveLen(4))
+	Expect(decisions[0].Result.Name).To(Equal(good.Result.Name))
+	Expect(decisions[1].Result.Name).To(Equal(hd.Result.Name))
+	Expect(decisions[2].Accepted()).To(BeFalse())
+	Expect(decisions[3].Accepted()).To(BeFalse())
+	Expect(Accepted(decisions)).To(HaveLen(2))
+	Expect(Best(decisions).Result.Name).To(Equal(good.Result.Name))
+
+	decisions = e.Decide([]Candidate{sd, german})
+	Expect(Best(decisions)).To(BeNil())
+}
+
+func TestRankBySeeders(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := &Engine{}
+	few := testCandidate("show1.S01E01.720p.HDTV.x264-FEW", quality.HDTV)
+	few.Type = providers.TORRENT
+	few.Result.Seeders = 2
+	many := testCandidate("show1.S01E01.720p.HDTV.x264-MANY", quality.HDTV)
+	many.Type = providers.TORRENT
+	many.Result.Seeders = 200
+
+	decisions := e.Decide([]Candidate{few, many})
+	Expect(decisions[0].Result.Name).To(Equal(many.Result.Name))
+}
+
+func TestDecisionString(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := testCandidate("show1.S01E01.720p.HDTV.x264-GRP", quality.HDTV)
+	c.Parse.SeriesName = "show1"
+	c.Parse.ReleaseGroup = "GRP"
+	d := Decision{Candidate: c}
+	Expect(d.String()).To(HavePrefix("Accepted show1.S01E01.720p.HDTV.x264-GRP"))
+	Expect(d.String()).To(ContainSubstring("series 'show1', season 1, episodes [1]"))
+	Expect(d.String()).To(ContainSubstring("group 'GRP'"))
+
+	d.Rejections = []string{"Blacklisted", "Contains ignored word x264"}
+	Expect(d.String()).To(HavePrefix("Rejected show1.S01E01.720p.HDTV.x264-GRP"))
+	Expect(d.String()).To(HaveSuffix(": Blacklisted; Contains ignored word x264"))
+}
+
+func TestUpgrades(t *testing.T) {
+	RegisterTestingT(t)
+
+	c := testCandidate("show1.S01E01.720p.WEB-DL.DD5.1.H.264-GRP", quality.HDWEBDL)
+	c.Show.QualityGroup = quality.QualityGroup{
+		Name:      "HD",
+		Qualities: []quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY},
+		Cutoff:    quality.HDWEBDL,
+	}
+	ep := c.Episodes[0]
+	ep.Status = types.DOWNLOADED
+	ep.Quality = quality.HDTV
+	Expect((&Engine{}).Wanted(c.Show, c.Episodes)).To(HaveLen(1))
+	Expect(wantedSpec{}.Check(&c)).To(BeEmpty())
+
+	c.Quality = quality.HDTV
+	Expect(wantedSpec{}.Check(&c)).To(Equal("HD TV isn't an upgrade on S1E1's HD TV"))
+
+	ep.Quality = quality.HDWEBDL
+	Expect((&Engine{}).Wanted(c.Show, c.Episodes)).To(BeEmpty())
+	Expect(wantedSpec{}.Check(&c)).To(Equal("S1E1 is already DOWNLOADED at 720p WEB-DL, which meets the cutoff"))
+
+	c.Show.QualityGroup.Cutoff = quality.UNKNOWN
+	ep.Quality = quality.HDTV
+	Expect((&Engine{}).Wanted(c.Show, c.Episodes)).To(BeEmpty())
+}
+
+func TestRankByQualityGroupOrder(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := &Engine{}
+	webdl := testCandidate("show1.S01E01.720p.WEB-DL.DD5.1.H.264-GRP", quality.HDWEBDL)
+	fullhd := testCandidate("show1.S01E01.1080p.HDTV.x264-GRP", quality.FULLHDTV)
+	group := quality.QualityGroup{Qualities: []quality.Quality{quality.FULLHDTV, quality.HDWEBDL}}
+	webdl.Show.QualityGroup = group
+	fullhd.Show.QualityGroup = group
+
+	decisions := e.Decide([]Candidate{fullhd, webdl})
+	Expect(decisions[0].Result.Name).To(Equal(webdl.Result.Name))
+}
+
+func TestPropers(t *testing.T) {
+	RegisterTestingT(t)
+
+	e := &Engine{ProperWindow: 7 * 24 * time.Hour}
+	spec := wantedSpec{ProperWindow: e.ProperWindow}
+	first := testCandidate("show1.S01E01.720p.HDTV.x264-GRP", quality.HDTV)
+	proper := testCandidate("show1.S01E01.PROPER.720p.HDTV.x264-GRP", quality.HDTV)
+	proper.Parse.Revision = 2
+
+	decisions := e.Decide([]Candidate{first, proper})
+	Expect(decisions[0].Result.Name).To(Equal(proper.Result.Name))
+
+	ep := proper.Episodes[0]
+	ep.Status = types.DOWNLOADED
+	ep.Quality = quality.HDTV
+	ep.AirDate = time.Now().Add(-48 * time.Hour)
+	Expect(proper.IsProper(ep)).To(BeTrue())
+	Expect(proper.SnatchedStatus(ep)).To(Equal(types.SNATCHED_PROPER))
+	Expect(first.SnatchedStatus(ep)).To(Equal(types.SNATCHED_BEST))
+	Expect(e.Wanted(proper.Show, proper.Episodes)).To(HaveLen(1))
+	Expect(spec.Check(&proper)).To(BeEmpty())
+
+	ep.Version = 2
+	Expect(proper.IsProper(ep)).To(BeFalse())
+	Expect(proper.SnatchedStatus(ep)).To(Equal(types.SNATCHED_BEST))
+	Expect(spec.Check(&proper)).To(Equal("S1E1 is already DOWNLOADED at HD TV, which meets the cutoff"))
+
+	ep.Version = 1
+	ep.AirDate = time.Now().Add(-30 * 24 * time.Hour)
+	Expect(e.Wanted(proper.Show, proper.Episodes)).To(BeEmpty())
+	Expect(spec.Check(&proper)).To(Equal("S1E1 aired too long ago to be replaced by revision 2"))
+}
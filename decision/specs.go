@@ -0,0 +1,182 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/types"
+)
+
+// MinSeasonPackWanted is how many episodes of a season need to be wanted
+// before a season pack is downloaded instead of the individual episodes.
+const MinSeasonPackWanted = 2
+
+// episodeSpec rejects releases that aren't of the episodes wanted.  Season
+// packs are only wanted when at least MinSeasonPackWanted episodes are.
+type episodeSpec struct{}
+
+func (s episodeSpec) Check(c *Candidate) string {
+	if c.MatchErr != nil {
+		return c.MatchErr.Error()
+	}
+	if len(c.Episodes) < MinSeasonPackWanted && c.Parse.IsSeasonPack() {
+		return fmt.Sprintf("Season pack with %d wanted episodes, need %d", len(c.Episodes), MinSeasonPackWanted)
+	}
+	matched := map[int64]bool{}
+	for _, ep := range c.Matched {
+		matched[ep.ID] = true
+	}
+	for _, ep := range c.Episodes {
+		if !matched[ep.ID] {
+			return fmt.Sprintf("Doesn't contain S%dE%d", ep.Season, ep.Episode)
+		}
+	}
+	return ""
+}
+
+// wantedSpec rejects releases for episodes that aren't wanted.  Episodes
+// downloaded below the cutoff of the show's quality group are wanted by
+// releases that would upgrade them, and ones that aired within the proper
+// window by propers of the same quality.
+type wantedSpec struct {
+	ProperWindow time.Duration
+}
+
+func (s wantedSpec) Check(c *Candidate) string {
+	if len(c.Episodes) == 0 {
+		return "No wanted episodes"
+	}
+	profile := qualityProfile(c.Show)
+	for _, ep := range c.Episodes {
+		switch {
+		case ep.Status == types.WANTED:
+		case c.IsProper(ep):
+			if !inProperWindow(ep, s.ProperWindow) {
+				return fmt.Sprintf("S%dE%d aired too long ago to be replaced by revision %d", ep.Season, ep.Episode, c.Revision())
+			}
+		case ep.Status == types.DOWNLOADED && profile.CutoffMet(ep.Quality):
+			return fmt.Sprintf("S%dE%d is already %s at %s, which meets the cutoff", ep.Season, ep.Episode, ep.Status, ep.Quality)
+		case ep.Status == types.DOWNLOADED:
+			if !profile.IsUpgrade(ep.Quality, c.Quality) {
+				return fmt.Sprintf("%s isn't an upgrade on S%dE%d's %s", c.Quality, ep.Season, ep.Episode, ep.Quality)
+			}
+		default:
+			return fmt.Sprintf("S%dE%d is %s, not wanted", ep.Season, ep.Episode, ep.Status)
+		}
+	}
+	return ""
+}
+
+// qualitySpec rejects releases whose quality isn't in the show's quality
+// group.
+type qualitySpec struct{}
+
+func (s qualitySpec) Check(c *Candidate) string {
+	profile := qualityProfile(c.Show)
+	if !profile.Includes(c.Quality) {
+		return fmt.Sprintf("Quality %s isn't allowed by %s", c.Quality, profile.Name)
+	}
+	return ""
+}
+
+// sizeSpec rejects releases that are too small or too big for the number of
+// episodes in them.  Releases of unknown size are accepted.
+type sizeSpec struct {
+	Min int64 // MB per episode
+	Max int64 // MB per episode
+}
+
+func (s sizeSpec) Check(c *Candidate) string {
+	if c.Result.Size <= 0 {
+		return ""
+	}
+	// Season packs hold every episode they matched, not just the wanted ones.
+	count := int64(len(c.Matched))
+	if count < 1 {
+		count = int64(len(c.Episodes))
+	}
+	if count < 1 {
+		count = 1
+	}
+	perEpisode := c.Result.Size / count / (1024 * 1024)
+	if s.Min > 0 && perEpisode < s.Min {
+		return fmt.Sprintf("%dMB per episode is smaller than %dMB", perEpisode, s.Min)
+	}
+	if s.Max > 0 && perEpisode > s.Max {
+		return fmt.Sprintf("%dMB per episode is bigger than %dMB", perEpisode, s.Max)
+	}
+	return ""
+}
+
+// blacklistSpec rejects releases that have been blacklisted.
+type blacklistSpec struct {
+	DBH *db.Handle
+}
+
+func (s blacklistSpec) Check(c *Candidate) string {
+	blacklisted, err := s.DBH.IsBlacklisted(c.Result.Name)
+	if err != nil {
+		return fmt.Sprintf("Error checking blacklist: %s", err)
+	}
+	if blacklisted {
+		return "Blacklisted"
+	}
+	return ""
+}
+
+// wordsSpec rejects releases whose name contains any of the ignore words or,
+// if there are any require words, none of them.  Case is ignored.
+type wordsSpec struct {
+	Ignore  []string
+	Require []string
+}
+
+func (s wordsSpec) Check(c *Candidate) string {
+	name := strings.ToLower(c.Result.Name)
+	for _, w := range s.Ignore {
+		if strings.Contains(name, strings.ToLower(w)) {
+			return fmt.Sprintf("Contains ignored word %s", w)
+		}
+	}
+	if len(s.Require) == 0 {
+		return ""
+	}
+	for _, w := range s.Require {
+		if strings.Contains(name, strings.ToLower(w)) {
+			return ""
+		}
+	}
+	return fmt.Sprintf("Doesn't contain any of %s", strings.Join(s.Require, ", "))
+}
+
+// seedersSpec rejects torrents without enough seeders.
+type seedersSpec struct {
+	Min int64
+}
+
+func (s seedersSpec) Check(c *Candidate) string {
+	if c.Type != providers.TORRENT || c.Result.Seeders >= s.Min {
+		return ""
+	}
+	return fmt.Sprintf("%d seeders is less than %d", c.Result.Seeders, s.Min)
+}
+
+// ageSpec rejects releases that were published too long ago, eg beyond a
+// usenet server's retention.  Releases of unknown age are accepted.
+type ageSpec struct {
+	MaxDays int
+}
+
+func (s ageSpec) Check(c *Candidate) string {
+	if s.MaxDays <= 0 || c.Result.Age == nil {
+		return ""
+	}
+	days := int(time.Since(*c.Result.Age).Hours() / 24)
+	if days > s.MaxDays {
+		return fmt.Sprintf("%d days old is older than %d", days, s.MaxDays)
+	}
+	return ""
+}
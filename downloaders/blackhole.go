@@ -0,0 +1,79 @@
+package downloaders
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hobeone/tv2go/storage"
+)
+
+// Blackhole saves downloads to a directory watched by some other program.  It
+// can't tell what happens to them after that.
+type Blackhole struct {
+	ClientName string
+	Dir        string
+	broker     *storage.Broker
+}
+
+// NewBlackhole creates a client saving downloads to dir.
+func NewBlackhole(name, dir string, broker *storage.Broker) *Blackhole {
+	return &Blackhole{
+		ClientName: name,
+		Dir:        dir,
+		broker:     broker,
+	}
+}
+
+// Name returns the client's name.
+func (b *Blackhole) Name() string {
+	return b.ClientName
+}
+
+// Add saves the download's contents to the directory.  The id is the path of
+// the saved file.
+func (b *Blackhole) Add(d Download) (string, error) {
+	if len(d.Content) == 0 {
+		return "", fmt.Errorf("Nothing to save for %s", d.Name)
+	}
+	return b.broker.SaveToFile(b.Dir, d.Filename, d.Content)
+}
+
+// Status reports a download as queued while its file is still in the
+// directory.  Once the file has been picked up its status is unknown.
+func (b *Blackhole) Status(id string) (*Item, error) {
+	item := &Item{
+		ID:   id,
+		Name: filepath.Base(id),
+	}
+	if _, err := os.Stat(id); err == nil {
+		item.Status = QUEUED
+	}
+	return item, nil
+}
+
+// Remove deletes the download's file if it's still in the directory.
+func (b *Blackhole) Remove(id string, deleteData bool) error {
+	dir, err := filepath.Abs(b.Dir)
+	if err != nil {
+		return err
+	}
+	if filepath.Dir(filepath.Clean(id)) != dir {
+		return fmt.Errorf("%s isn't in blackhole directory %s", id, dir)
+	}
+	err = os.Remove(id)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Queue returns nothing as a blackhole has no queue.
+func (b *Blackhole) Queue() ([]Item, error) {
+	return []Item{}, nil
+}
+
+// History returns nothing as a blackhole has no history.
+func (b *Blackhole) History() ([]Item, error) {
+	return []Item{}, nil
+}
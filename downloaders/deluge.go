@@ -0,0 +1,355 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/torrent"
+)
+
+// delugeNotAuthenticated is the error code Deluge's web api returns when the
+// session has expired.
+const delugeNotAuthenticated = 1
+
+var delugeFields = []string{
+	"name", "state", "total_wanted", "total_done", "is_finished",
+	"save_path", "label", "message", "eta",
+}
+
+// Deluge sends torrents to Deluge through the JSON-RPC api of its web ui.
+type Deluge struct {
+	ClientName string
+	URL        string // url of the api, eg http://localhost:8112/json
+	Password   string
+	Label      string // needs the label plugin
+	Dir        string // where to save downloads, Deluge's default if empty
+	Client     *http.Client
+	requestID  int64
+}
+
+// NewDeluge creates a new client talking to the Deluge web ui at baseURL.
+func NewDeluge(name, baseURL, password string, options ...func(*Deluge)) *Deluge {
+	apiURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(apiURL, "/json") {
+		apiURL += "/json"
+	}
+	jar, _ := cookiejar.New(nil)
+	d := &Deluge{
+		ClientName: name,
+		URL:        apiURL,
+		Password:   password,
+		Client:     &http.Client{Timeout: clientTimeout, Jar: jar},
+	}
+	for _, option := range options {
+		option(d)
+	}
+	return d
+}
+
+// SetDelugeLabel is used in the NewDeluge constructor to set the label
+// torrents are added with.
+func SetDelugeLabel(label string) func(*Deluge) {
+	return func(d *Deluge) {
+		d.Label = strings.ToLower(label)
+	}
+}
+
+// SetDelugeDir is used in the NewDeluge constructor to set the directory
+// downloads are saved to.
+func SetDelugeDir(dir string) func(*Deluge) {
+	return func(d *Deluge) {
+		d.Dir = dir
+	}
+}
+
+// Name returns the client's name.
+func (d *Deluge) Name() string {
+	return d.ClientName
+}
+
+type delugeRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     int64         `json:"id"`
+}
+
+type delugeError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type delugeResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *delugeError    `json:"error"`
+}
+
+type delugeTorrent struct {
+	Name        string  `json:"name"`
+	State       string  `json:"state"`
+	TotalWanted int64   `json:"total_wanted"`
+	TotalDone   int64   `json:"total_done"`
+	IsFinished  bool    `json:"is_finished"`
+	SavePath    string  `json:"save_path"`
+	Label       string  `json:"label"`
+	Message     string  `json:"message"`
+	ETA         float64 `json:"eta"` // seconds, 0 if unknown
+}
+
+// rpc makes a single JSON-RPC call.
+func (d *Deluge) rpc(method string, result interface{}, params ...interface{}) (*delugeError, error) {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(delugeRequest{
+		Method: method,
+		Params: params,
+		ID:     atomic.AddInt64(&d.requestID, 1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	r, err := d.Client.Post(d.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("Error talking to Deluge %s: %s", d.ClientName, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Deluge %s returned %s", d.ClientName, r.Status)
+	}
+	resp := &delugeResponse{}
+	err = json.NewDecoder(r.Body).Decode(resp)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding Deluge response: %s", err)
+	}
+	if resp.Error != nil {
+		return resp.Error, nil
+	}
+	if result != nil {
+		err = json.Unmarshal(resp.Result, result)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding Deluge %s result: %s", method, err)
+		}
+	}
+	return nil, nil
+}
+
+// login authenticates with the web ui and makes sure it's connected to a
+// Deluge daemon.
+func (d *Deluge) login() error {
+	var ok bool
+	rerr, err := d.rpc("auth.login", &ok, d.Password)
+	if err != nil {
+		return err
+	}
+	if rerr != nil || !ok {
+		return fmt.Errorf("Couldn't log in to Deluge %s", d.ClientName)
+	}
+
+	var connected bool
+	_, err = d.rpc("web.connected", &connected)
+	if err != nil || connected {
+		return err
+	}
+	hosts := [][]interface{}{}
+	_, err = d.rpc("web.get_hosts", &hosts)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 || len(hosts[0]) == 0 {
+		return fmt.Errorf("Deluge %s isn't connected to a daemon and doesn't know any", d.ClientName)
+	}
+	glog.Infof("Connecting Deluge %s to daemon %v", d.ClientName, hosts[0][0])
+	_, err = d.rpc("web.connect", nil, hosts[0][0])
+	return err
+}
+
+// call makes a JSON-RPC call, logging in first if needed.
+func (d *Deluge) call(method string, result interface{}, params ...interface{}) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		rerr, err := d.rpc(method, result, params...)
+		if err != nil {
+			return err
+		}
+		if rerr == nil {
+			return nil
+		}
+		if rerr.Code != delugeNotAuthenticated || attempt > 0 {
+			return fmt.Errorf("Deluge %s error calling %s: %s", d.ClientName, method, rerr.Message)
+		}
+		err = d.login()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add sends the torrent, magnet link or torrent url to Deluge.  The id is the
+// torrent's info hash.
+func (d *Deluge) Add(dl Download) (string, error) {
+	options := map[string]interface{}{}
+	if d.Dir != "" {
+		options["download_location"] = d.Dir
+	}
+	var hash string
+	var err error
+	switch {
+	case torrent.IsMagnet(string(dl.Content)):
+		err = d.call("core.add_torrent_magnet", &hash, string(dl.Content), options)
+	case len(dl.Content) > 0:
+		filename := dl.Filename
+		if filename == "" {
+			filename = dl.Name + ".torrent"
+		}
+		err = d.call("core.add_torrent_file", &hash, filename, base64.StdEncoding.EncodeToString(dl.Content), options)
+	case torrent.IsMagnet(dl.URL):
+		err = d.call("core.add_torrent_magnet", &hash, dl.URL, options)
+	case dl.URL != "":
+		err = d.call("core.add_torrent_url", &hash, dl.URL, options)
+	default:
+		return "", fmt.Errorf("No torrent or magnet link for %s", dl.Name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if hash == "" {
+		return "", fmt.Errorf("Deluge %s didn't add %s, it may already have it", d.ClientName, dl.Name)
+	}
+	if d.Label != "" {
+		err = d.setLabel(hash)
+		if err != nil {
+			glog.Warningf("Couldn't label %s: %s", dl.Name, err)
+		}
+	}
+	glog.Infof("Added %s to Deluge %s as %s", dl.Name, d.ClientName, hash)
+	return hash, nil
+}
+
+// setLabel labels the torrent, creating the label if it doesn't exist yet.
+func (d *Deluge) setLabel(hash string) error {
+	labels := []string{}
+	err := d.call("label.get_labels", &labels)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, l := range labels {
+		if l == d.Label {
+			found = true
+			break
+		}
+	}
+	if !found {
+		err = d.call("label.add", nil, d.Label)
+		if err != nil {
+			return err
+		}
+	}
+	return d.call("label.set_torrent", nil, hash, d.Label)
+}
+
+func delugeItem(hash string, t delugeTorrent) Item {
+	item := Item{
+		ID:        hash,
+		Name:      t.Name,
+		Category:  t.Label,
+		Size:      t.TotalWanted,
+		Remaining: t.TotalWanted - t.TotalDone,
+		Path:      filepath.Join(t.SavePath, t.Name),
+		ETA:       time.Duration(t.ETA * float64(time.Second)),
+	}
+	switch {
+	case t.State == "Error":
+		item.Status = FAILED
+		item.Message = t.Message
+	case t.IsFinished || t.State == "Seeding":
+		item.Status = COMPLETED
+	case t.State == "Paused":
+		item.Status = PAUSED
+	case t.State == "Queued":
+		item.Status = QUEUED
+	default:
+		item.Status = DOWNLOADING
+	}
+	return item
+}
+
+// torrents returns the torrents matching the filter.
+func (d *Deluge) torrents(filter map[string]interface{}) ([]Item, error) {
+	torrents := map[string]delugeTorrent{}
+	err := d.call("core.get_torrents_status", &torrents, filter, delugeFields)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(torrents))
+	for hash, t := range torrents {
+		items = append(items, delugeItem(hash, t))
+	}
+	sort.Sort(itemsByName(items))
+	return items, nil
+}
+
+// labelled returns our torrents, or all of them if we don't have a label.
+func (d *Deluge) labelled() ([]Item, error) {
+	filter := map[string]interface{}{}
+	if d.Label != "" {
+		filter["label"] = d.Label
+	}
+	return d.torrents(filter)
+}
+
+// Queue returns the torrents Deluge is still downloading.
+func (d *Deluge) Queue() ([]Item, error) {
+	all, err := d.labelled()
+	if err != nil {
+		return nil, err
+	}
+	queue, _ := splitFinished(all)
+	return queue, nil
+}
+
+// History returns the torrents Deluge has finished or given up on.
+// Finished torrents may still be seeding.
+func (d *Deluge) History() ([]Item, error) {
+	all, err := d.labelled()
+	if err != nil {
+		return nil, err
+	}
+	_, history := splitFinished(all)
+	return history, nil
+}
+
+// Status returns the state of the torrent with the given info hash.
+func (d *Deluge) Status(id string) (*Item, error) {
+	items, err := d.torrents(map[string]interface{}{"id": []string{strings.ToLower(id)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s doesn't know about download %s", d.ClientName, id)
+	}
+	return &items[0], nil
+}
+
+// Remove removes the torrent from Deluge.
+func (d *Deluge) Remove(id string, deleteData bool) error {
+	var ok bool
+	err := d.call("core.remove_torrent", &ok, strings.ToLower(id), deleteData)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Deluge %s couldn't remove %s", d.ClientName, id)
+	}
+	return nil
+}
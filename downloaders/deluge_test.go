@@ -0,0 +1,172 @@
+package downloaders
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const delugeTorrentsJSON = `{
+  "aaaa": {"name": "Show.S01E01.720p", "state": "Downloading", "total_wanted": 1000, "total_done": 600, "is_finished": false, "save_path": "/downloads/tv2go", "label": "tv2go", "eta": 90},
+  "bbbb": {"name": "Show.S01E02.720p", "state": "Seeding", "total_wanted": 1000, "total_done": 1000, "is_finished": true, "save_path": "/downloads/tv2go", "label": "tv2go"},
+  "cccc": {"name": "Show.S01E03.720p", "state": "Error", "total_wanted": 1000, "total_done": 0, "save_path": "/downloads/tv2go", "label": "tv2go", "message": "No space left on device"},
+  "dddd": {"name": "Show.S01E04.720p", "state": "Paused", "total_wanted": 1000, "total_done": 10, "save_path": "/downloads/tv2go", "label": "tv2go"}
+}`
+
+type delugeTestRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     int64             `json:"id"`
+}
+
+func testDelugeServer(t *testing.T) (*httptest.Server, *[]delugeTestRequest) {
+	reqs := []delugeTestRequest{}
+	connected := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/json" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		req := delugeTestRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Errorf("Error decoding request: %s", err)
+			return
+		}
+		reqs = append(reqs, req)
+		result := "null"
+		_, cookieErr := r.Cookie("_session_id")
+		switch {
+		case req.Method == "auth.login":
+			var password string
+			json.Unmarshal(req.Params[0], &password)
+			if password != "deluge" {
+				result = "false"
+				break
+			}
+			http.SetCookie(w, &http.Cookie{Name: "_session_id", Value: "session1", Path: "/"})
+			result = "true"
+		case cookieErr != nil:
+			fmt.Fprintf(w, `{"id": %d, "result": null, "error": {"message": "Not authenticated", "code": 1}}`, req.ID)
+			return
+		case req.Method == "web.connected":
+			result = fmt.Sprintf("%t", connected)
+		case req.Method == "web.get_hosts":
+			result = `[["host1", "127.0.0.1", 58846, "localclient"]]`
+		case req.Method == "web.connect":
+			connected = true
+		case req.Method == "core.add_torrent_magnet", req.Method == "core.add_torrent_file", req.Method == "core.add_torrent_url":
+			result = `"c12fe1c06bba254a9dc9f519b335aa7c1367a88a"`
+		case req.Method == "label.get_labels":
+			result = `["movies"]`
+		case req.Method == "core.get_torrents_status":
+			filter := map[string]interface{}{}
+			json.Unmarshal(req.Params[0], &filter)
+			if _, ok := filter["id"]; ok {
+				result = `{"bbbb": {"name": "Show.S01E02.720p", "state": "Paused", "is_finished": true, "save_path": "/downloads/tv2go"}}`
+				break
+			}
+			result = delugeTorrentsJSON
+		case req.Method == "core.remove_torrent":
+			result = "true"
+		}
+		fmt.Fprintf(w, `{"id": %d, "result": %s, "error": null}`, req.ID, result)
+	}))
+	return server, &reqs
+}
+
+func delugeMethods(reqs []delugeTestRequest) []string {
+	methods := make([]string, len(reqs))
+	for i, r := range reqs {
+		methods[i] = r.Method
+	}
+	return methods
+}
+
+func TestDelugeAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testDelugeServer(t)
+	defer server.Close()
+
+	d := NewDeluge("deluge", server.URL, "deluge", SetDelugeLabel("TV2Go"), SetDelugeDir("/downloads/tv2go"))
+	Expect(d.URL).To(Equal(server.URL + "/json"))
+
+	id, err := d.Add(Download{Name: "Show.S01E01.720p", Content: []byte(testMagnet)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	Expect(delugeMethods(*reqs)).To(Equal([]string{
+		"core.add_torrent_magnet", "auth.login", "web.connected", "web.get_hosts",
+		"web.connect", "core.add_torrent_magnet", "label.get_labels", "label.add",
+		"label.set_torrent",
+	}))
+	options := map[string]string{}
+	json.Unmarshal((*reqs)[5].Params[1], &options)
+	Expect(options["download_location"]).To(Equal("/downloads/tv2go"))
+	var label string
+	json.Unmarshal((*reqs)[8].Params[1], &label)
+	Expect(label).To(Equal("tv2go"))
+
+	*reqs = (*reqs)[:0]
+	_, err = d.Add(Download{Name: "Show.S01E01.720p", Filename: "a.torrent", Content: []byte(testTorrent)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect((*reqs)[0].Method).To(Equal("core.add_torrent_file"))
+
+	*reqs = (*reqs)[:0]
+	_, err = d.Add(Download{Name: "Show.S01E01.720p", URL: "http://example.com/a.torrent"})
+	Expect(err).ToNot(HaveOccurred())
+	Expect((*reqs)[0].Method).To(Equal("core.add_torrent_url"))
+
+	bad := NewDeluge("deluge", server.URL, "wrong")
+	_, err = bad.Add(Download{Name: "Show.S01E01.720p", URL: testMagnet})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestDelugeQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testDelugeServer(t)
+	defer server.Close()
+
+	d := NewDeluge("deluge", server.URL+"/", "deluge", SetDelugeLabel("tv2go"))
+	queue, err := d.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(queue).To(HaveLen(2))
+	Expect(queue[0].ID).To(Equal("aaaa"))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Remaining).To(BeEquivalentTo(400))
+	Expect(queue[0].ETA).To(Equal(90 * time.Second))
+	Expect(queue[1].Status).To(Equal(PAUSED))
+	last := (*reqs)[len(*reqs)-1]
+	filter := map[string]string{}
+	json.Unmarshal(last.Params[0], &filter)
+	Expect(filter["label"]).To(Equal("tv2go"))
+
+	history, err := d.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(2))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/tv2go/Show.S01E02.720p"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Message).To(Equal("No space left on device"))
+
+	item, err := d.Status("BBBB")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(COMPLETED))
+}
+
+func TestDelugeRemove(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testDelugeServer(t)
+	defer server.Close()
+
+	d := NewDeluge("deluge", server.URL, "deluge")
+	Expect(d.Remove("bbbb", true)).ToNot(HaveOccurred())
+	last := (*reqs)[len(*reqs)-1]
+	Expect(last.Method).To(Equal("core.remove_torrent"))
+	var deleteData bool
+	json.Unmarshal(last.Params[1], &deleteData)
+	Expect(deleteData).To(BeTrue())
+}
@@ -0,0 +1,273 @@
+// Package downloaders sends grabbed releases to download clients and asks them
+// how the downloads are going.
+package downloaders
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/storage"
+)
+
+// clientTimeout is how long to wait for a download client to respond.
+const clientTimeout = 30 * time.Second
+
+// Status is the state of a download in a client.
+type Status int
+
+// Download states
+const (
+	UNKNOWN Status = iota
+	QUEUED
+	DOWNLOADING
+	PAUSED
+	COMPLETED
+	FAILED
+)
+
+var statuses = [...]string{
+	"UNKNOWN",
+	"QUEUED",
+	"DOWNLOADING",
+	"PAUSED",
+	"COMPLETED",
+	"FAILED",
+}
+
+func (s Status) String() string {
+	return statuses[s]
+}
+
+// Finished returns true if the client is done with the download, whether it
+// worked or not.
+func (s Status) Finished() bool {
+	return s == COMPLETED || s == FAILED
+}
+
+// MarshalText marshals a Status as its name.
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Download is a release to add to a download client.
+type Download struct {
+	Name     string // release name
+	URL      string // where the release was downloaded from, or a magnet link
+	Filename string // name of the .nzb/.torrent file
+	Content  []byte // contents of the .nzb/.torrent file
+}
+
+// Item describes a download in a client's queue or history.
+type Item struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Category  string        `json:"category"`
+	Status    Status        `json:"status"`
+	Size      int64         `json:"size"`
+	Remaining int64         `json:"remaining"`
+	ETA       time.Duration `json:"eta,omitempty"`     // 0 if the client doesn't know
+	Path      string        `json:"path,omitempty"`    // where the finished files are
+	Message   string        `json:"message,omitempty"` // why it failed
+}
+
+// DownloadClient is something which downloads releases for us.
+type DownloadClient interface {
+	Name() string
+
+	// Add sends the download to the client and returns the client's id for
+	// it.
+	Add(d Download) (string, error)
+
+	// Status returns the current state of the download with the given id.
+	Status(id string) (*Item, error)
+
+	// Remove removes the download from the client's queue or history,
+	// optionally deleting the downloaded data.
+	Remove(id string, deleteData bool) error
+
+	// Queue returns the downloads the client is working on.
+	Queue() ([]Item, error)
+
+	// History returns the downloads the client has finished.
+	History() ([]Item, error)
+}
+
+// findItem looks for a download in a client's queue and then history.
+func findItem(c DownloadClient, id string) (*Item, error) {
+	item, _, err := locateItem(c, id)
+	return item, err
+}
+
+// locateItem is like findItem but also returns true if the download was found
+// in the queue rather than the history, which for some clients isn't the same
+// as it being unfinished, eg while it's being post processed.
+func locateItem(c DownloadClient, id string) (*Item, bool, error) {
+	queue, err := c.Queue()
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range queue {
+		if queue[i].ID == id {
+			return &queue[i], true, nil
+		}
+	}
+	history, err := c.History()
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range history {
+		if history[i].ID == id {
+			return &history[i], false, nil
+		}
+	}
+	return nil, false, fmt.Errorf("%s doesn't know about download %s", c.Name(), id)
+}
+
+// splitFinished splits items into those still downloading and those the
+// client has finished with, for clients which keep both in one list.
+func splitFinished(items []Item) (queue []Item, history []Item) {
+	queue, history = []Item{}, []Item{}
+	for _, item := range items {
+		if item.Status.Finished() {
+			history = append(history, item)
+		} else {
+			queue = append(queue, item)
+		}
+	}
+	return queue, history
+}
+
+type itemsByName []Item
+
+func (a itemsByName) Len() int           { return len(a) }
+func (a itemsByName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a itemsByName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// ClientRegistry maps each type of provider to the client its grabs are sent
+// to.
+type ClientRegistry map[providers.ProviderType]DownloadClient
+
+// NewClientRegistry creates the download clients in the given configuration.
+// Provider types without an enabled client get a blackhole client using the
+// directories in cfg.Storage.
+func NewClientRegistry(cfg *config.Config, broker *storage.Broker) (ClientRegistry, error) {
+	cr := ClientRegistry{}
+	for _, ccfg := range cfg.DownloadClients {
+		if !ccfg.Enabled {
+			glog.Infof("Download client %s is disabled, skipping", ccfg.Name)
+			continue
+		}
+		pt, err := providerType(ccfg.ProviderType)
+		if err != nil {
+			return nil, fmt.Errorf("Download client %s: %s", ccfg.Name, err)
+		}
+		if existing, ok := cr[pt]; ok {
+			return nil, fmt.Errorf("Download clients %s and %s both handle %s", existing.Name(), ccfg.Name, pt)
+		}
+		c, err := NewDownloadClient(ccfg, broker)
+		if err != nil {
+			return nil, err
+		}
+		cr[pt] = c
+	}
+	if _, ok := cr[providers.NZB]; !ok {
+		cr[providers.NZB] = NewBlackhole("nzbBlackhole", cfg.Storage.NZBBlackhole, broker)
+	}
+	if _, ok := cr[providers.TORRENT]; !ok {
+		cr[providers.TORRENT] = NewBlackhole("torrentBlackhole", cfg.Storage.TorrentBlackhole, broker)
+	}
+	return cr, nil
+}
+
+func providerType(s string) (providers.ProviderType, error) {
+	switch strings.ToLower(s) {
+	case "nzb":
+		return providers.NZB, nil
+	case "torrent":
+		return providers.TORRENT, nil
+	}
+	return providers.UNKNOWN, fmt.Errorf("Unknown provider type '%s', should be nzb or torrent", s)
+}
+
+// NewDownloadClient creates a DownloadClient from the given configuration.
+func NewDownloadClient(cfg config.DownloadClientConfig, broker *storage.Broker) (DownloadClient, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("Download client name can not be empty")
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "blackhole":
+		if cfg.Directory == "" {
+			return nil, fmt.Errorf("Blackhole download client %s needs a Directory", cfg.Name)
+		}
+		return NewBlackhole(cfg.Name, cfg.Directory, broker), nil
+	case "sabnzbd":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("SABnzbd download client %s needs a URL", cfg.Name)
+		}
+		return NewSABnzbd(cfg.Name, cfg.URL, cfg.API, SetSABCategory(cfg.Category)), nil
+	case "nzbget":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("NZBGet download client %s needs a URL", cfg.Name)
+		}
+		return NewNZBGet(cfg.Name, cfg.URL, cfg.Username, cfg.Password,
+			SetNZBGetCategory(cfg.Category),
+			SetNZBGetPriority(cfg.Priority),
+		), nil
+	case "transmission":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("Transmission download client %s needs a URL", cfg.Name)
+		}
+		return NewTransmission(cfg.Name, cfg.URL, cfg.Username, cfg.Password,
+			SetTransmissionDir(cfg.Directory),
+		), nil
+	case "qbittorrent":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("qBittorrent download client %s needs a URL", cfg.Name)
+		}
+		return NewQBittorrent(cfg.Name, cfg.URL, cfg.Username, cfg.Password,
+			SetQBittorrentCategory(cfg.Category),
+			SetQBittorrentDir(cfg.Directory),
+		), nil
+	case "deluge":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("Deluge download client %s needs a URL", cfg.Name)
+		}
+		return NewDeluge(cfg.Name, cfg.URL, cfg.Password,
+			SetDelugeLabel(cfg.Category),
+			SetDelugeDir(cfg.Directory),
+		), nil
+	case "rtorrent":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("rTorrent download client %s needs a URL", cfg.Name)
+		}
+		return NewRTorrent(cfg.Name, cfg.URL, cfg.Username, cfg.Password,
+			SetRTorrentLabel(cfg.Category),
+			SetRTorrentDir(cfg.Directory),
+		), nil
+	default:
+		return nil, fmt.Errorf("Unknown type '%s' for download client %s", cfg.Type, cfg.Name)
+	}
+}
+
+// For returns the client for the given type of provider.
+func (cr ClientRegistry) For(t providers.ProviderType) (DownloadClient, error) {
+	c, ok := cr[t]
+	if !ok {
+		return nil, fmt.Errorf("No download client for %s providers", t)
+	}
+	return c, nil
+}
+
+// Named returns the client with the given name.
+func (cr ClientRegistry) Named(name string) (DownloadClient, error) {
+	for _, c := range cr {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("No download client named %s", name)
+}
@@ -0,0 +1,92 @@
+package downloaders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/storage"
+	. "github.com/onsi/gomega"
+)
+
+func testBroker(t *testing.T) (*storage.Broker, string) {
+	dir, err := ioutil.TempDir("", "tv2go_downloaders")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	broker, err := storage.NewBroker(dir)
+	if err != nil {
+		t.Fatalf("Error creating broker: %s", err)
+	}
+	return broker, dir
+}
+
+func TestNewClientRegistry(t *testing.T) {
+	RegisterTestingT(t)
+	broker, dir := testBroker(t)
+	defer os.RemoveAll(dir)
+
+	cfg := config.NewTestConfig()
+	cfg.Storage.NZBBlackhole = dir
+	cfg.Storage.TorrentBlackhole = dir
+	cr, err := NewClientRegistry(cfg, broker)
+	Expect(err).ToNot(HaveOccurred())
+	c, err := cr.For(providers.NZB)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(c.Name()).To(Equal("nzbBlackhole"))
+
+	cfg.DownloadClients = []config.DownloadClientConfig{
+		{Name: "sab", Type: "sabnzbd", ProviderType: "nzb", URL: "http://localhost:8080", Enabled: true},
+		{Name: "off", Type: "sabnzbd", ProviderType: "nzb", URL: "http://localhost:8081", Enabled: false},
+	}
+	cr, err = NewClientRegistry(cfg, broker)
+	Expect(err).ToNot(HaveOccurred())
+	c, err = cr.For(providers.NZB)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(c.Name()).To(Equal("sab"))
+	c, err = cr.For(providers.TORRENT)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(c.Name()).To(Equal("torrentBlackhole"))
+	c, err = cr.Named("sab")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(c.Name()).To(Equal("sab"))
+	_, err = cr.Named("off")
+	Expect(err).To(HaveOccurred())
+
+	cfg.DownloadClients[1].Enabled = true
+	_, err = NewClientRegistry(cfg, broker)
+	Expect(err).To(HaveOccurred())
+
+	cfg.DownloadClients = []config.DownloadClientConfig{
+		{Name: "bad", Type: "sabnzbd", ProviderType: "usenet", URL: "http://localhost:8080", Enabled: true},
+	}
+	_, err = NewClientRegistry(cfg, broker)
+	Expect(err).To(HaveOccurred())
+}
+
+func TestBlackhole(t *testing.T) {
+	RegisterTestingT(t)
+	broker, dir := testBroker(t)
+	defer os.RemoveAll(dir)
+
+	b := NewBlackhole("hole", dir, broker)
+	_, err := b.Add(Download{Name: "empty"})
+	Expect(err).To(HaveOccurred())
+
+	id, err := b.Add(Download{Name: "Show.S01E01", Filename: "Show.S01E01.nzb", Content: []byte("nzb")})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(filepath.Dir(id)).To(Equal(dir))
+
+	item, err := b.Status(id)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(QUEUED))
+
+	Expect(b.Remove("/etc/passwd", false)).To(HaveOccurred())
+	Expect(b.Remove(id, true)).ToNot(HaveOccurred())
+	item, err = b.Status(id)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(UNKNOWN))
+}
@@ -0,0 +1,336 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// NZBGet sends downloads to NZBGet through its JSON-RPC API.
+type NZBGet struct {
+	ClientName string
+	URL        string // url of the api, eg http://localhost:6789/jsonrpc
+	Username   string
+	Password   string
+	Category   string
+	Priority   int // -100 (very low) to 100 (very high), 900 to force
+	Client     *http.Client
+	requestID  int64
+}
+
+// NewNZBGet creates a new NZBGet client talking to the NZBGet at baseURL.
+func NewNZBGet(name, baseURL, username, password string, options ...func(*NZBGet)) *NZBGet {
+	apiURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(apiURL, "/jsonrpc") {
+		apiURL += "/jsonrpc"
+	}
+	n := &NZBGet{
+		ClientName: name,
+		URL:        apiURL,
+		Username:   username,
+		Password:   password,
+		Client:     &http.Client{Timeout: clientTimeout},
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// SetNZBGetCategory is used in the NewNZBGet constructor to set the category
+// downloads are added with.
+func SetNZBGetCategory(cat string) func(*NZBGet) {
+	return func(n *NZBGet) {
+		n.Category = cat
+	}
+}
+
+// SetNZBGetPriority is used in the NewNZBGet constructor to set the priority
+// downloads are added with.
+func SetNZBGetPriority(priority int) func(*NZBGet) {
+	return func(n *NZBGet) {
+		n.Priority = priority
+	}
+}
+
+// Name returns the client's name.
+func (n *NZBGet) Name() string {
+	return n.ClientName
+}
+
+type nzbgetRequest struct {
+	Method string        `json:"method"`
+	Params []interface{} `json:"params"`
+	ID     int64         `json:"id"`
+}
+
+type nzbgetError struct {
+	Name    string `json:"name"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type nzbgetResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *nzbgetError    `json:"error"`
+}
+
+// nzbgetGroup is an entry in the listgroups or history results.  Sizes are
+// split into two 32 bit halves.
+type nzbgetGroup struct {
+	NZBID           int64
+	NZBName         string // listgroups
+	Name            string // history
+	Kind            string // history: NZB, URL or DUP
+	Category        string
+	Status          string
+	FileSizeLo      uint32
+	FileSizeHi      uint32
+	RemainingSizeLo uint32
+	RemainingSizeHi uint32
+	DestDir         string
+	FinalDir        string
+}
+
+func (g nzbgetGroup) size() int64 {
+	return int64(g.FileSizeHi)<<32 | int64(g.FileSizeLo)
+}
+
+func (g nzbgetGroup) remaining() int64 {
+	return int64(g.RemainingSizeHi)<<32 | int64(g.RemainingSizeLo)
+}
+
+// nzbgetStatus is the part of the status result used to work out how long
+// downloads will take.
+type nzbgetStatus struct {
+	DownloadRate   int64 // bytes per second
+	DownloadPaused bool
+}
+
+// call makes a JSON-RPC call and decodes its result into result.
+func (n *NZBGet) call(method string, result interface{}, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(nzbgetRequest{
+		Method: method,
+		Params: params,
+		ID:     atomic.AddInt64(&n.requestID, 1),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.Username != "" {
+		req.SetBasicAuth(n.Username, n.Password)
+	}
+	r, err := n.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error talking to NZBGet %s: %s", n.ClientName, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("NZBGet %s returned %s", n.ClientName, r.Status)
+	}
+	resp := &nzbgetResponse{}
+	err = json.NewDecoder(r.Body).Decode(resp)
+	if err != nil {
+		return fmt.Errorf("Error decoding NZBGet response: %s", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("NZBGet %s error calling %s: %s", n.ClientName, method, resp.Error.Message)
+	}
+	err = json.Unmarshal(resp.Result, result)
+	if err != nil {
+		return fmt.Errorf("Error decoding NZBGet %s result: %s", method, err)
+	}
+	return nil
+}
+
+// Add sends the NZB contents to NZBGet, or has it fetch the url if there
+// aren't any.
+func (n *NZBGet) Add(d Download) (string, error) {
+	filename := d.Filename
+	if filename == "" {
+		filename = d.Name + ".nzb"
+	}
+	content := d.URL
+	if len(d.Content) > 0 {
+		content = base64.StdEncoding.EncodeToString(d.Content)
+	}
+	var id int64
+	err := n.call("append", &id,
+		filename,        // NZBFilename
+		content,         // NZBContent, base64 encoded nzb or a url
+		n.Category,      // Category
+		n.Priority,      // Priority
+		false,           // AddToTop
+		false,           // AddPaused
+		"",              // DupeKey
+		0,               // DupeScore
+		"SCORE",         // DupeMode
+		[]interface{}{}, // PPParameters
+	)
+	if err != nil {
+		return "", err
+	}
+	if id <= 0 {
+		return "", fmt.Errorf("NZBGet %s couldn't add %s", n.ClientName, d.Name)
+	}
+	glog.Infof("Added %s to NZBGet %s as %d", d.Name, n.ClientName, id)
+	return strconv.FormatInt(id, 10), nil
+}
+
+func nzbgetQueueStatus(status string) Status {
+	switch {
+	case status == "QUEUED":
+		return QUEUED
+	case strings.HasPrefix(status, "PAUSED"):
+		return PAUSED
+	default:
+		// DOWNLOADING, FETCHING and all the post processing states.
+		return DOWNLOADING
+	}
+}
+
+// nzbgetHistoryStatus maps the status of a history item, eg SUCCESS/ALL.
+// Only a failed script still leaves a good download behind; the other
+// warnings are for damaged or incomplete ones.  Items deleted by hand or as
+// duplicates aren't failures, only those NZBGet gave up on are.
+func nzbgetHistoryStatus(status string) Status {
+	parts := strings.SplitN(status, "/", 2)
+	switch parts[0] {
+	case "SUCCESS":
+		return COMPLETED
+	case "WARNING":
+		if len(parts) == 2 && parts[1] == "SCRIPT" {
+			return COMPLETED
+		}
+		return FAILED
+	case "FAILURE":
+		return FAILED
+	case "DELETED":
+		if len(parts) == 2 && parts[1] == "HEALTH" {
+			return FAILED
+		}
+		return UNKNOWN
+	default:
+		return UNKNOWN
+	}
+}
+
+// Queue returns the downloads NZBGet is working on.
+func (n *NZBGet) Queue() ([]Item, error) {
+	groups := []nzbgetGroup{}
+	err := n.call("listgroups", &groups, 0)
+	if err != nil {
+		return nil, err
+	}
+	status := nzbgetStatus{}
+	err = n.call("status", &status)
+	if err != nil {
+		glog.Warningf("Couldn't get the download rate from NZBGet %s: %s", n.ClientName, err)
+	}
+	// NZBGet downloads the queue in order, so each download has to wait for
+	// the ones ahead of it.
+	ahead := int64(0)
+	items := make([]Item, len(groups))
+	for i, g := range groups {
+		items[i] = Item{
+			ID:        strconv.FormatInt(g.NZBID, 10),
+			Name:      g.NZBName,
+			Category:  g.Category,
+			Status:    nzbgetQueueStatus(g.Status),
+			Size:      g.size(),
+			Remaining: g.remaining(),
+			Path:      g.DestDir,
+		}
+		if items[i].Status == PAUSED {
+			continue
+		}
+		ahead += items[i].Remaining
+		if status.DownloadRate > 0 && !status.DownloadPaused {
+			items[i].ETA = time.Duration(ahead/status.DownloadRate) * time.Second
+		}
+	}
+	return items, nil
+}
+
+// History returns the downloads NZBGet has finished with.
+func (n *NZBGet) History() ([]Item, error) {
+	groups := []nzbgetGroup{}
+	err := n.call("history", &groups, false)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]Item, 0, len(groups))
+	for _, g := range groups {
+		if g.Kind != "" && g.Kind != "NZB" {
+			continue
+		}
+		item := Item{
+			ID:       strconv.FormatInt(g.NZBID, 10),
+			Name:     g.Name,
+			Category: g.Category,
+			Status:   nzbgetHistoryStatus(g.Status),
+			Size:     g.size(),
+			Path:     g.FinalDir,
+		}
+		if item.Path == "" {
+			item.Path = g.DestDir
+		}
+		if item.Status == FAILED {
+			item.Message = g.Status
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Status returns the state of the download with the given NZBID.
+func (n *NZBGet) Status(id string) (*Item, error) {
+	return findItem(n, id)
+}
+
+// Remove deletes the download from NZBGet's queue or history.
+func (n *NZBGet) Remove(id string, deleteData bool) error {
+	nzbid, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid NZBGet id %s", id)
+	}
+	_, queued, err := locateItem(n, id)
+	if err != nil {
+		return err
+	}
+	command := "HistoryDelete"
+	if deleteData {
+		command = "HistoryFinalDelete"
+	}
+	if queued {
+		command = "GroupDelete"
+		if deleteData {
+			command = "GroupFinalDelete"
+		}
+	}
+	var ok bool
+	err = n.call("editqueue", &ok, command, "", []int64{nzbid})
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("NZBGet %s couldn't remove %s", n.ClientName, id)
+	}
+	return nil
+}
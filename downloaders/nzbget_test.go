@@ -0,0 +1,186 @@
+package downloaders
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const nzbgetGroupsJSON = `[
+  {"NZBID": 11, "NZBName": "Show.S01E01.720p", "Category": "tv2go", "Status": "DOWNLOADING", "FileSizeLo": 1000, "FileSizeHi": 1, "RemainingSizeLo": 500, "RemainingSizeHi": 0, "DestDir": "/downloads/inter/Show.S01E01.720p"},
+  {"NZBID": 12, "NZBName": "Show.S01E02.720p", "Category": "tv2go", "Status": "PAUSED", "FileSizeLo": 10, "FileSizeHi": 0}
+]`
+
+const nzbgetHistoryJSON = `[
+  {"NZBID": 13, "Kind": "NZB", "Name": "Show.S01E03.720p", "Category": "tv2go", "Status": "SUCCESS/ALL", "FileSizeLo": 1024, "DestDir": "/downloads/inter/x", "FinalDir": "/downloads/tv/Show.S01E03.720p"},
+  {"NZBID": 14, "Kind": "NZB", "Name": "Show.S01E04.720p", "Category": "tv2go", "Status": "FAILURE/PAR", "FileSizeLo": 2048, "DestDir": "/downloads/inter/Show.S01E04.720p"},
+  {"NZBID": 16, "Kind": "NZB", "Name": "Show.S01E05.720p", "Category": "tv2go", "Status": "DELETED/MANUAL", "FileSizeLo": 4096},
+  {"NZBID": 15, "Kind": "URL", "Name": "http://indexer/get/15", "Status": "FAILURE/FETCH"}
+]`
+
+type nzbgetTestRequest struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	ID     int64             `json:"id"`
+}
+
+func testNZBGetServer(t *testing.T) (*httptest.Server, *[]nzbgetTestRequest) {
+	reqs := []nzbgetTestRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jsonrpc" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "nzbget" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		req := nzbgetTestRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Errorf("Error decoding request: %s", err)
+			return
+		}
+		reqs = append(reqs, req)
+		result := ""
+		switch req.Method {
+		case "append":
+			result = "42"
+		case "listgroups":
+			result = nzbgetGroupsJSON
+		case "history":
+			result = nzbgetHistoryJSON
+		case "status":
+			result = `{"DownloadRate": 250, "DownloadPaused": false}`
+		case "editqueue":
+			result = "true"
+		default:
+			fmt.Fprintf(w, `{"version": "1.1", "id": %d, "error": {"name": "JSONRPCError", "code": 1, "message": "Invalid procedure"}}`, req.ID)
+			return
+		}
+		fmt.Fprintf(w, `{"version": "1.1", "id": %d, "result": %s}`, req.ID, result)
+	}))
+	return server, &reqs
+}
+
+func TestNZBGetAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testNZBGetServer(t)
+	defer server.Close()
+
+	n := NewNZBGet("nzbget", server.URL, "nzbget", "secret", SetNZBGetCategory("tv2go"), SetNZBGetPriority(50))
+	Expect(n.URL).To(Equal(server.URL + "/jsonrpc"))
+
+	id, err := n.Add(Download{Name: "Show.S01E01", Filename: "Show.S01E01.nzb", Content: []byte("nzb contents")})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("42"))
+
+	req := (*reqs)[0]
+	Expect(req.Method).To(Equal("append"))
+	Expect(req.Params).To(HaveLen(10))
+	var filename, content, category string
+	var priority int
+	json.Unmarshal(req.Params[0], &filename)
+	json.Unmarshal(req.Params[1], &content)
+	json.Unmarshal(req.Params[2], &category)
+	json.Unmarshal(req.Params[3], &priority)
+	Expect(filename).To(Equal("Show.S01E01.nzb"))
+	Expect(content).To(Equal(base64.StdEncoding.EncodeToString([]byte("nzb contents"))))
+	Expect(category).To(Equal("tv2go"))
+	Expect(priority).To(Equal(50))
+
+	_, err = n.Add(Download{Name: "Show.S01E01", URL: "http://indexer/get/1"})
+	Expect(err).ToNot(HaveOccurred())
+	json.Unmarshal((*reqs)[1].Params[1], &content)
+	Expect(content).To(Equal("http://indexer/get/1"))
+
+	n.Password = "wrong"
+	_, err = n.Add(Download{Name: "Show.S01E01", URL: "http://indexer/get/1"})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestNZBGetQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, _ := testNZBGetServer(t)
+	defer server.Close()
+
+	n := NewNZBGet("nzbget", server.URL+"/", "nzbget", "secret")
+	queue, err := n.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(queue).To(HaveLen(2))
+	Expect(queue[0].ID).To(Equal("11"))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Size).To(BeEquivalentTo(1<<32 + 1000))
+	Expect(queue[0].Remaining).To(BeEquivalentTo(500))
+	Expect(queue[0].ETA).To(Equal(2 * time.Second))
+	Expect(queue[1].Status).To(Equal(PAUSED))
+	Expect(queue[1].ETA).To(BeZero())
+
+	history, err := n.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(3))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/tv/Show.S01E03.720p"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Path).To(Equal("/downloads/inter/Show.S01E04.720p"))
+	Expect(history[1].Message).To(Equal("FAILURE/PAR"))
+	Expect(history[2].Status).To(Equal(UNKNOWN))
+
+	item, err := n.Status("14")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Name).To(Equal("Show.S01E04.720p"))
+}
+
+func TestNZBGetHistoryStatus(t *testing.T) {
+	RegisterTestingT(t)
+	statuses := map[string]Status{
+		"SUCCESS/ALL":        COMPLETED,
+		"SUCCESS/UNPACK":     COMPLETED,
+		"WARNING/SCRIPT":     COMPLETED,
+		"WARNING/DAMAGED":    FAILED,
+		"WARNING/REPAIRABLE": FAILED,
+		"WARNING/PASSWORD":   FAILED,
+		"WARNING/HEALTH":     FAILED,
+		"FAILURE/PAR":        FAILED,
+		"DELETED/HEALTH":     FAILED,
+		"DELETED/MANUAL":     UNKNOWN,
+		"DELETED/DUPE":       UNKNOWN,
+		"BOGUS":              UNKNOWN,
+	}
+	for status, expected := range statuses {
+		Expect(nzbgetHistoryStatus(status)).To(Equal(expected), "Wrong status for %s", status)
+	}
+}
+
+func TestNZBGetRemove(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testNZBGetServer(t)
+	defer server.Close()
+
+	n := NewNZBGet("nzbget", server.URL, "nzbget", "secret")
+	Expect(n.Remove("11", true)).ToNot(HaveOccurred())
+	last := (*reqs)[len(*reqs)-1]
+	Expect(last.Method).To(Equal("editqueue"))
+	var command string
+	json.Unmarshal(last.Params[0], &command)
+	Expect(command).To(Equal("GroupFinalDelete"))
+
+	Expect(n.Remove("13", false)).ToNot(HaveOccurred())
+	last = (*reqs)[len(*reqs)-1]
+	json.Unmarshal(last.Params[0], &command)
+	Expect(command).To(Equal("HistoryDelete"))
+
+	// Deleted by hand so not finished, but it's in the history.
+	Expect(n.Remove("16", true)).ToNot(HaveOccurred())
+	last = (*reqs)[len(*reqs)-1]
+	json.Unmarshal(last.Params[0], &command)
+	Expect(command).To(Equal("HistoryFinalDelete"))
+
+	Expect(n.Remove("abc", false)).To(HaveOccurred())
+}
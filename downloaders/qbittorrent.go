@@ -0,0 +1,302 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// qbInfiniteETA is the eta qBittorrent gives torrents that won't finish.
+const qbInfiniteETA = 8640000
+
+// QBittorrent sends torrents to qBittorrent through its Web API.
+type QBittorrent struct {
+	ClientName string
+	URL        string // base url of the web ui, eg http://localhost:8080
+	Username   string
+	Password   string
+	Category   string
+	Dir        string // where to save downloads, qBittorrent's default if empty
+	Client     *http.Client
+}
+
+// NewQBittorrent creates a new client talking to the qBittorrent at baseURL.
+func NewQBittorrent(name, baseURL, username, password string, options ...func(*QBittorrent)) *QBittorrent {
+	jar, _ := cookiejar.New(nil)
+	q := &QBittorrent{
+		ClientName: name,
+		URL:        strings.TrimRight(baseURL, "/"),
+		Username:   username,
+		Password:   password,
+		Client:     &http.Client{Timeout: clientTimeout, Jar: jar},
+	}
+	for _, option := range options {
+		option(q)
+	}
+	return q
+}
+
+// SetQBittorrentCategory is used in the NewQBittorrent constructor to set the
+// category torrents are added with.
+func SetQBittorrentCategory(cat string) func(*QBittorrent) {
+	return func(q *QBittorrent) {
+		q.Category = cat
+	}
+}
+
+// SetQBittorrentDir is used in the NewQBittorrent constructor to set the
+// directory downloads are saved to.
+func SetQBittorrentDir(dir string) func(*QBittorrent) {
+	return func(q *QBittorrent) {
+		q.Dir = dir
+	}
+}
+
+// Name returns the client's name.
+func (q *QBittorrent) Name() string {
+	return q.ClientName
+}
+
+type qbTorrent struct {
+	Hash        string `json:"hash"`
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	State       string `json:"state"`
+	Size        int64  `json:"size"`
+	AmountLeft  int64  `json:"amount_left"`
+	SavePath    string `json:"save_path"`
+	ContentPath string `json:"content_path"`
+	ETA         int64  `json:"eta"` // seconds, qbInfiniteETA if unknown
+}
+
+// login gets a new session cookie.
+func (q *QBittorrent) login() error {
+	r, err := q.Client.PostForm(q.URL+"/api/v2/auth/login", url.Values{
+		"username": {q.Username},
+		"password": {q.Password},
+	})
+	if err != nil {
+		return fmt.Errorf("Error talking to qBittorrent %s: %s", q.ClientName, err)
+	}
+	defer r.Body.Close()
+	body, _ := ioutil.ReadAll(r.Body)
+	if r.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != "Ok." {
+		return fmt.Errorf("Couldn't log in to qBittorrent %s: %s %s", q.ClientName, r.Status, body)
+	}
+	return nil
+}
+
+// do sends a request built by newReq, logging in and trying again if the
+// session has expired.  It returns the response body.
+func (q *QBittorrent) do(newReq func() (*http.Request, error)) ([]byte, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := newReq()
+		if err != nil {
+			return nil, err
+		}
+		r, err := q.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("Error talking to qBittorrent %s: %s", q.ClientName, err)
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if r.StatusCode == http.StatusForbidden && attempt == 0 {
+			err = q.login()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if r.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("qBittorrent %s returned %s: %s", q.ClientName, r.Status, body)
+		}
+		return body, nil
+	}
+	return nil, fmt.Errorf("qBittorrent %s rejected our login", q.ClientName)
+}
+
+func (q *QBittorrent) get(path string, params url.Values) ([]byte, error) {
+	return q.do(func() (*http.Request, error) {
+		return http.NewRequest("GET", q.URL+path+"?"+params.Encode(), nil)
+	})
+}
+
+func (q *QBittorrent) post(path string, params url.Values) ([]byte, error) {
+	return q.do(func() (*http.Request, error) {
+		req, err := http.NewRequest("POST", q.URL+path, strings.NewReader(params.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	})
+}
+
+// Add sends the torrent or magnet link to qBittorrent.  The id is the
+// torrent's info hash.
+func (q *QBittorrent) Add(d Download) (string, error) {
+	magnet, hash, err := torrentSource(d)
+	if err != nil {
+		return "", err
+	}
+	fields := map[string]string{}
+	if magnet != "" {
+		fields["urls"] = magnet
+	}
+	if q.Category != "" {
+		fields["category"] = q.Category
+	}
+	if q.Dir != "" {
+		fields["savepath"] = q.Dir
+	}
+	body, err := q.do(func() (*http.Request, error) {
+		buf := &bytes.Buffer{}
+		w := multipart.NewWriter(buf)
+		for k, v := range fields {
+			w.WriteField(k, v)
+		}
+		if magnet == "" {
+			filename := d.Filename
+			if filename == "" {
+				filename = hash + ".torrent"
+			}
+			part, err := w.CreateFormFile("torrents", filename)
+			if err != nil {
+				return nil, err
+			}
+			io.Copy(part, bytes.NewReader(d.Content))
+		}
+		w.Close()
+		req, err := http.NewRequest("POST", q.URL+"/api/v2/torrents/add", buf)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", w.FormDataContentType())
+		return req, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(string(body)) != "Ok." {
+		return "", fmt.Errorf("qBittorrent %s couldn't add %s: %s", q.ClientName, d.Name, body)
+	}
+	glog.Infof("Added %s to qBittorrent %s as %s", d.Name, q.ClientName, hash)
+	return hash, nil
+}
+
+func qbStatus(state string) Status {
+	switch state {
+	case "error", "missingFiles":
+		return FAILED
+	case "uploading", "stalledUP", "pausedUP", "queuedUP", "forcedUP", "checkingUP":
+		return COMPLETED
+	case "pausedDL":
+		return PAUSED
+	case "queuedDL":
+		return QUEUED
+	default:
+		// downloading, stalledDL, metaDL, forcedDL, checkingDL, allocating etc
+		return DOWNLOADING
+	}
+}
+
+func (q *QBittorrent) info(params url.Values) ([]Item, error) {
+	body, err := q.get("/api/v2/torrents/info", params)
+	if err != nil {
+		return nil, err
+	}
+	torrents := []qbTorrent{}
+	err = json.Unmarshal(body, &torrents)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding qBittorrent response: %s", err)
+	}
+	items := make([]Item, len(torrents))
+	for i, t := range torrents {
+		items[i] = Item{
+			ID:        t.Hash,
+			Name:      t.Name,
+			Category:  t.Category,
+			Status:    qbStatus(t.State),
+			Size:      t.Size,
+			Remaining: t.AmountLeft,
+			Path:      t.ContentPath,
+		}
+		// content_path is only in qBittorrent 4.3.2 and later
+		if items[i].Path == "" {
+			items[i].Path = filepath.Join(t.SavePath, t.Name)
+		}
+		if items[i].Status == FAILED {
+			items[i].Message = t.State
+		}
+		if t.ETA > 0 && t.ETA < qbInfiniteETA {
+			items[i].ETA = time.Duration(t.ETA) * time.Second
+		}
+	}
+	return items, nil
+}
+
+// torrents returns the torrents in our category, or all of them if we don't
+// have one.
+func (q *QBittorrent) torrents() ([]Item, error) {
+	params := url.Values{}
+	if q.Category != "" {
+		params.Set("category", q.Category)
+	}
+	return q.info(params)
+}
+
+// Queue returns the torrents qBittorrent is still downloading.
+func (q *QBittorrent) Queue() ([]Item, error) {
+	all, err := q.torrents()
+	if err != nil {
+		return nil, err
+	}
+	queue, _ := splitFinished(all)
+	return queue, nil
+}
+
+// History returns the torrents qBittorrent has finished or given up on.
+// Finished torrents may still be seeding.
+func (q *QBittorrent) History() ([]Item, error) {
+	all, err := q.torrents()
+	if err != nil {
+		return nil, err
+	}
+	_, history := splitFinished(all)
+	return history, nil
+}
+
+// Status returns the state of the torrent with the given info hash.
+func (q *QBittorrent) Status(id string) (*Item, error) {
+	items, err := q.info(url.Values{"hashes": {strings.ToLower(id)}})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%s doesn't know about download %s", q.ClientName, id)
+	}
+	return &items[0], nil
+}
+
+// Remove removes the torrent from qBittorrent.
+func (q *QBittorrent) Remove(id string, deleteData bool) error {
+	_, err := q.post("/api/v2/torrents/delete", url.Values{
+		"hashes":      {strings.ToLower(id)},
+		"deleteFiles": {fmt.Sprintf("%t", deleteData)},
+	})
+	return err
+}
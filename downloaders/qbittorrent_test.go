@@ -0,0 +1,132 @@
+package downloaders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const qbTorrentsJSON = `[
+  {"hash": "aaaa", "name": "Show.S01E01.720p", "category": "tv2go", "state": "downloading", "size": 1000, "amount_left": 400, "save_path": "/downloads/tv2go/", "eta": 120},
+  {"hash": "bbbb", "name": "Show.S01E02.720p", "category": "tv2go", "state": "stalledUP", "size": 1000, "amount_left": 0, "save_path": "/downloads/tv2go/", "content_path": "/downloads/tv2go/Show.S01E02.720p.mkv"},
+  {"hash": "cccc", "name": "Show.S01E03.720p", "category": "tv2go", "state": "pausedDL", "size": 1000, "amount_left": 1000, "save_path": "/downloads/tv2go/", "eta": 8640000},
+  {"hash": "dddd", "name": "Show.S01E04.720p", "category": "tv2go", "state": "missingFiles", "size": 1000, "amount_left": 0, "save_path": "/downloads/tv2go/"}
+]`
+
+func testQBittorrentServer(t *testing.T) (*httptest.Server, *[]*http.Request) {
+	reqs := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v2/auth/login" {
+			r.ParseForm()
+			if r.PostForm.Get("username") != "admin" || r.PostForm.Get("password") != "secret" {
+				fmt.Fprint(w, "Fails.")
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session1", Path: "/"})
+			fmt.Fprint(w, "Ok.")
+			return
+		}
+		if c, err := r.Cookie("SID"); err != nil || c.Value != "session1" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		reqs = append(reqs, r)
+		switch r.URL.Path {
+		case "/api/v2/torrents/add":
+			err := r.ParseMultipartForm(1 << 20)
+			if err != nil {
+				t.Errorf("Error parsing add request: %s", err)
+			}
+			if f, _, err := r.FormFile("torrents"); err == nil {
+				content, _ := ioutil.ReadAll(f)
+				if string(content) != testTorrent {
+					t.Errorf("Unexpected torrent content: %s", content)
+				}
+			}
+			fmt.Fprint(w, "Ok.")
+		case "/api/v2/torrents/info":
+			if r.URL.Query().Get("hashes") != "" {
+				fmt.Fprint(w, `[{"hash": "bbbb", "name": "Show.S01E02.720p", "state": "pausedUP", "save_path": "/downloads/tv2go/"}]`)
+				return
+			}
+			fmt.Fprint(w, qbTorrentsJSON)
+		case "/api/v2/torrents/delete":
+			r.ParseForm()
+			fmt.Fprint(w, "")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return server, &reqs
+}
+
+func TestQBittorrentAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testQBittorrentServer(t)
+	defer server.Close()
+
+	q := NewQBittorrent("qbit", server.URL+"/", "admin", "secret",
+		SetQBittorrentCategory("tv2go"), SetQBittorrentDir("/downloads/tv2go"))
+	id, err := q.Add(Download{Name: "Show.S01E01.720p", Filename: "Show.S01E01.720p.torrent", Content: []byte(testTorrent)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal(testTorrentHash(t)))
+	req := (*reqs)[0]
+	Expect(req.FormValue("category")).To(Equal("tv2go"))
+	Expect(req.FormValue("savepath")).To(Equal("/downloads/tv2go"))
+
+	id, err = q.Add(Download{Name: "Show.S01E01.720p", URL: testMagnet})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	Expect((*reqs)[1].FormValue("urls")).To(Equal(testMagnet))
+
+	bad := NewQBittorrent("qbit", server.URL, "admin", "wrong")
+	_, err = bad.Add(Download{Name: "Show.S01E01.720p", URL: testMagnet})
+	Expect(err).To(HaveOccurred())
+}
+
+func TestQBittorrentQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testQBittorrentServer(t)
+	defer server.Close()
+
+	q := NewQBittorrent("qbit", server.URL, "admin", "secret", SetQBittorrentCategory("tv2go"))
+	queue, err := q.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect((*reqs)[0].URL.Query().Get("category")).To(Equal("tv2go"))
+	Expect(queue).To(HaveLen(2))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Remaining).To(BeEquivalentTo(400))
+	Expect(queue[0].ETA).To(Equal(2 * time.Minute))
+	Expect(queue[1].Status).To(Equal(PAUSED))
+	Expect(queue[1].ETA).To(BeZero())
+
+	history, err := q.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(2))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/tv2go/Show.S01E02.720p.mkv"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Message).To(Equal("missingFiles"))
+
+	item, err := q.Status("BBBB")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(COMPLETED))
+	Expect(item.Path).To(Equal("/downloads/tv2go/Show.S01E02.720p"))
+}
+
+func TestQBittorrentRemove(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testQBittorrentServer(t)
+	defer server.Close()
+
+	q := NewQBittorrent("qbit", server.URL, "admin", "secret")
+	Expect(q.Remove("AAAA", true)).ToNot(HaveOccurred())
+	req := (*reqs)[0]
+	Expect(req.PostForm.Get("hashes")).To(Equal("aaaa"))
+	Expect(req.PostForm.Get("deleteFiles")).To(Equal("true"))
+}
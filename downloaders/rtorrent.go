@@ -0,0 +1,268 @@
+package downloaders
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// The fields fetched for each torrent by d.multicall2, in order.
+var rtorrentFields = []interface{}{
+	"", "main",
+	"d.hash=", "d.name=", "d.size_bytes=", "d.left_bytes=", "d.complete=",
+	"d.state=", "d.is_active=", "d.is_multi_file=", "d.directory=",
+	"d.custom1=", "d.message=", "d.down.rate=",
+}
+
+// RTorrent sends torrents to rTorrent through its XML-RPC interface, which
+// needs to be exposed by a web server (usually at /RPC2).
+type RTorrent struct {
+	ClientName string
+	URL        string // url of the XML-RPC interface, eg http://localhost/RPC2
+	Username   string
+	Password   string
+	Label      string // stored in custom1, like ruTorrent does
+	Dir        string // where to save downloads, rTorrent's default if empty
+	Client     *http.Client
+}
+
+// NewRTorrent creates a new client talking to the rTorrent at url.
+func NewRTorrent(name, url, username, password string, options ...func(*RTorrent)) *RTorrent {
+	r := &RTorrent{
+		ClientName: name,
+		URL:        url,
+		Username:   username,
+		Password:   password,
+		Client:     &http.Client{Timeout: clientTimeout},
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// SetRTorrentLabel is used in the NewRTorrent constructor to set the label
+// torrents are added with.
+func SetRTorrentLabel(label string) func(*RTorrent) {
+	return func(r *RTorrent) {
+		r.Label = label
+	}
+}
+
+// SetRTorrentDir is used in the NewRTorrent constructor to set the directory
+// downloads are saved to.
+func SetRTorrentDir(dir string) func(*RTorrent) {
+	return func(r *RTorrent) {
+		r.Dir = dir
+	}
+}
+
+// Name returns the client's name.
+func (r *RTorrent) Name() string {
+	return r.ClientName
+}
+
+// call makes an XML-RPC call and returns the decoded result.
+func (r *RTorrent) call(method string, params ...interface{}) (interface{}, error) {
+	body, err := encodeXMLRPC(method, params...)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", r.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "text/xml")
+	if r.Username != "" {
+		req.SetBasicAuth(r.Username, r.Password)
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error talking to rTorrent %s: %s", r.ClientName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rTorrent %s returned %s", r.ClientName, resp.Status)
+	}
+	result, err := decodeXMLRPC(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("rTorrent %s error calling %s: %s", r.ClientName, method, err)
+	}
+	return result, nil
+}
+
+// Add sends the torrent or magnet link to rTorrent and starts it.  The id is
+// the torrent's info hash.
+func (r *RTorrent) Add(d Download) (string, error) {
+	magnet, hash, err := torrentSource(d)
+	if err != nil {
+		return "", err
+	}
+	commands := []interface{}{}
+	if r.Dir != "" {
+		commands = append(commands, "d.directory.set="+rtorrentQuote(r.Dir))
+	}
+	if r.Label != "" {
+		commands = append(commands, "d.custom1.set="+rtorrentQuote(r.Label))
+	}
+	if magnet != "" {
+		_, err = r.call("load.start", append([]interface{}{"", magnet}, commands...)...)
+	} else {
+		_, err = r.call("load.raw_start", append([]interface{}{"", d.Content}, commands...)...)
+	}
+	if err != nil {
+		return "", err
+	}
+	glog.Infof("Added %s to rTorrent %s as %s", d.Name, r.ClientName, hash)
+	return hash, nil
+}
+
+// rtorrentQuote quotes a value for use in an rTorrent command, escaping
+// anything that would end the string early and let the rest of it run as
+// another command.
+func rtorrentQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// rtorrentItem converts a row of d.multicall2 results.
+func rtorrentItem(row []interface{}) (Item, error) {
+	if len(row) != len(rtorrentFields)-2 {
+		return Item{}, fmt.Errorf("Expected %d fields from d.multicall2, got %d", len(rtorrentFields)-2, len(row))
+	}
+	str := func(i int) string {
+		s, _ := row[i].(string)
+		return s
+	}
+	num := func(i int) int64 {
+		n, _ := row[i].(int64)
+		return n
+	}
+	item := Item{
+		ID:        strings.ToLower(str(0)),
+		Name:      str(1),
+		Size:      num(2),
+		Remaining: num(3),
+		Category:  str(9),
+		Path:      str(8),
+	}
+	// For single file torrents d.directory is the directory the file is in.
+	if num(7) == 0 {
+		item.Path = filepath.Join(item.Path, item.Name)
+	}
+	switch {
+	case num(4) == 1:
+		item.Status = COMPLETED
+	case num(5) == 0 && str(10) != "":
+		item.Status = FAILED
+		item.Message = str(10)
+	case num(5) == 0 || num(6) == 0:
+		item.Status = PAUSED
+	default:
+		item.Status = DOWNLOADING
+		if rate := num(11); rate > 0 {
+			item.ETA = time.Duration(item.Remaining/rate) * time.Second
+		}
+	}
+	return item, nil
+}
+
+// torrents returns all the torrents rTorrent has with our label, or all of
+// them if we don't have a label.
+func (r *RTorrent) torrents() ([]Item, error) {
+	result, err := r.call("d.multicall2", rtorrentFields...)
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := result.([]interface{})
+	items := []Item{}
+	for _, row := range rows {
+		fields, ok := row.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Unexpected d.multicall2 result from rTorrent %s", r.ClientName)
+		}
+		item, err := rtorrentItem(fields)
+		if err != nil {
+			return nil, err
+		}
+		if r.Label != "" && item.Category != r.Label {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Queue returns the torrents rTorrent is still downloading.
+func (r *RTorrent) Queue() ([]Item, error) {
+	all, err := r.torrents()
+	if err != nil {
+		return nil, err
+	}
+	queue, _ := splitFinished(all)
+	return queue, nil
+}
+
+// History returns the torrents rTorrent has finished or given up on.
+// Finished torrents may still be seeding.
+func (r *RTorrent) History() ([]Item, error) {
+	all, err := r.torrents()
+	if err != nil {
+		return nil, err
+	}
+	_, history := splitFinished(all)
+	return history, nil
+}
+
+// Status returns the state of the torrent with the given info hash.
+func (r *RTorrent) Status(id string) (*Item, error) {
+	all, err := r.torrents()
+	if err != nil {
+		return nil, err
+	}
+	for i := range all {
+		if strings.EqualFold(all[i].ID, id) {
+			return &all[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s doesn't know about download %s", r.ClientName, id)
+}
+
+// Remove removes the torrent from rTorrent.  rTorrent can't delete the data
+// itself, so if asked to it's deleted here, which only works if rTorrent
+// saves to a directory we can see.  To be safe it has to be under the
+// configured Dir, otherwise the torrent is still removed but its data is
+// left where it is.
+func (r *RTorrent) Remove(id string, deleteData bool) error {
+	var item *Item
+	if deleteData {
+		var err error
+		item, err = r.Status(id)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := r.call("d.erase", strings.ToUpper(id))
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		return nil
+	}
+	if r.Dir == "" {
+		glog.Warningf("Can't delete %s for rTorrent %s without a Directory set, leaving it", item.Path, r.ClientName)
+		return nil
+	}
+	rel, err := filepath.Rel(r.Dir, item.Path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		glog.Warningf("%s isn't in rTorrent %s's directory %s, leaving it", item.Path, r.ClientName, r.Dir)
+		return nil
+	}
+	glog.Infof("Deleting %s for rTorrent %s", item.Path, r.ClientName)
+	return os.RemoveAll(item.Path)
+}
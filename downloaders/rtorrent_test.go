@@ -0,0 +1,171 @@
+package downloaders
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func rtorrentRow(hash, name string, size, left, complete, state, active, multi int, dir, label, message string, rate int) string {
+	return fmt.Sprintf(`<value><array><data>
+<value><string>%s</string></value>
+<value><string>%s</string></value>
+<value><i8>%d</i8></value>
+<value><i8>%d</i8></value>
+<value><i8>%d</i8></value>
+<value><i8>%d</i8></value>
+<value><i8>%d</i8></value>
+<value><i8>%d</i8></value>
+<value><string>%s</string></value>
+<value><string>%s</string></value>
+<value><string>%s</string></value>
+<value><i8>%d</i8></value>
+</data></array></value>`, hash, name, size, left, complete, state, active, multi, dir, label, message, rate)
+}
+
+func xmlrpcResult(value string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param>` + value + `</param></params></methodResponse>`
+}
+
+func testRTorrentServer(t *testing.T, dir string) (*httptest.Server, *[]string) {
+	reqs := []string{}
+	rows := []string{
+		rtorrentRow("AAAA", "Show.S01E01.720p", 1000, 400, 0, 1, 1, 1, dir+"/Show.S01E01.720p", "tv2go", "", 100),
+		rtorrentRow("BBBB", "Show.S01E02.720p.mkv", 1000, 0, 1, 1, 1, 0, dir, "tv2go", "", 0),
+		rtorrentRow("CCCC", "Show.S01E03.720p", 1000, 1000, 0, 0, 0, 1, dir+"/Show.S01E03.720p", "tv2go", "Tracker: [Failure reason \"Unregistered torrent\"]", 0),
+		rtorrentRow("DDDD", "Other", 1000, 1000, 0, 1, 1, 1, "/downloads/other/Other", "", "", 0),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		reqs = append(reqs, string(body))
+		switch {
+		case bytes.Contains(body, []byte("<methodName>d.multicall2</methodName>")):
+			fmt.Fprint(w, xmlrpcResult(`<value><array><data>`+strings.Join(rows, "")+`</data></array></value>`))
+		case bytes.Contains(body, []byte("<methodName>load.")), bytes.Contains(body, []byte("<methodName>d.erase</methodName>")):
+			fmt.Fprint(w, xmlrpcResult(`<value><i4>0</i4></value>`))
+		default:
+			fmt.Fprint(w, `<?xml version="1.0"?><methodResponse><fault><value><struct>
+<member><name>faultCode</name><value><i4>-506</i4></value></member>
+<member><name>faultString</name><value><string>Method not defined</string></value></member>
+</struct></value></fault></methodResponse>`)
+		}
+	}))
+	return server, &reqs
+}
+
+func TestXMLRPC(t *testing.T) {
+	RegisterTestingT(t)
+
+	body, err := encodeXMLRPC("load.raw_start", "", []byte("abc"), `d.custom1.set="a&b"`, 1, true, []string{"x"})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(string(body)).To(ContainSubstring("<methodName>load.raw_start</methodName>"))
+	Expect(string(body)).To(ContainSubstring("<base64>YWJj</base64>"))
+	Expect(string(body)).To(ContainSubstring("a&amp;b"))
+	Expect(string(body)).To(ContainSubstring("<array><data><value><string>x</string></value></data></array>"))
+
+	_, err = encodeXMLRPC("bad", 1.5)
+	Expect(err).To(HaveOccurred())
+
+	v, err := decodeXMLRPC(strings.NewReader(xmlrpcResult(`<value><struct>
+<member><name>a</name><value>untyped</value></member>
+<member><name>b</name><value><boolean>1</boolean></value></member>
+<member><name>c</name><value><array><data></data></array></value></member>
+</struct></value>`)))
+	Expect(err).ToNot(HaveOccurred())
+	m := v.(map[string]interface{})
+	Expect(m["a"]).To(Equal("untyped"))
+	Expect(m["b"]).To(Equal(true))
+	Expect(m["c"]).To(Equal([]interface{}{}))
+}
+
+func TestRTorrentAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testRTorrentServer(t, "/downloads/tv2go")
+	defer server.Close()
+
+	r := NewRTorrent("rtorrent", server.URL+"/RPC2", "", "", SetRTorrentLabel("tv2go"), SetRTorrentDir("/downloads/tv2go"))
+	id, err := r.Add(Download{Name: "Show.S01E01.720p", Content: []byte(testMagnet)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	Expect((*reqs)[0]).To(ContainSubstring("<methodName>load.start</methodName>"))
+	Expect((*reqs)[0]).To(ContainSubstring(`d.directory.set=&#34;/downloads/tv2go&#34;`))
+	Expect((*reqs)[0]).To(ContainSubstring(`d.custom1.set=&#34;tv2go&#34;`))
+
+	id, err = r.Add(Download{Name: "Show.S01E01.720p", Content: []byte(testTorrent)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal(testTorrentHash(t)))
+	Expect((*reqs)[1]).To(ContainSubstring("<methodName>load.raw_start</methodName>"))
+}
+
+func TestRTorrentQuote(t *testing.T) {
+	RegisterTestingT(t)
+	Expect(rtorrentQuote(`/downloads/tv`)).To(Equal(`"/downloads/tv"`))
+	Expect(rtorrentQuote(`/tv";execute={rm,-rf,/};"`)).To(Equal(`"/tv\";execute={rm,-rf,/};\""`))
+	Expect(rtorrentQuote(`C:\tv\`)).To(Equal(`"C:\\tv\\"`))
+}
+
+func TestRTorrentQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, _ := testRTorrentServer(t, "/downloads/tv2go")
+	defer server.Close()
+
+	r := NewRTorrent("rtorrent", server.URL, "", "", SetRTorrentLabel("tv2go"))
+	queue, err := r.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(queue).To(HaveLen(1))
+	Expect(queue[0].ID).To(Equal("aaaa"))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Path).To(Equal("/downloads/tv2go/Show.S01E01.720p"))
+	Expect(queue[0].ETA).To(Equal(4 * time.Second))
+
+	history, err := r.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(2))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/tv2go/Show.S01E02.720p.mkv"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Message).To(ContainSubstring("Unregistered torrent"))
+
+	item, err := r.Status("BBBB")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(COMPLETED))
+
+	_, err = r.Status("DDDD")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestRTorrentRemove(t *testing.T) {
+	RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "tv2go_rtorrent")
+	if err != nil {
+		t.Fatalf("Error creating temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "Show.S01E02.720p.mkv")
+	ioutil.WriteFile(data, []byte("data"), 0644)
+
+	server, reqs := testRTorrentServer(t, dir)
+	defer server.Close()
+
+	// Without a Dir the torrent is still removed but the data is left.
+	r := NewRTorrent("rtorrent", server.URL, "", "")
+	Expect(r.Remove("bbbb", true)).ToNot(HaveOccurred())
+	Expect((*reqs)[len(*reqs)-1]).To(ContainSubstring("d.erase"))
+	_, err = os.Stat(data)
+	Expect(err).ToNot(HaveOccurred())
+
+	r.Dir = dir
+	Expect(r.Remove("bbbb", true)).ToNot(HaveOccurred())
+	Expect((*reqs)[len(*reqs)-1]).To(ContainSubstring("<string>BBBB</string>"))
+	_, err = os.Stat(data)
+	Expect(os.IsNotExist(err)).To(BeTrue())
+}
@@ -0,0 +1,301 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// SABnzbd sends downloads to SABnzbd through its HTTP API.
+type SABnzbd struct {
+	ClientName string
+	URL        string // url of the api, eg http://localhost:8080/sabnzbd/api
+	APIKey     string
+	Category   string
+	Client     *http.Client
+}
+
+// NewSABnzbd creates a new SABnzbd client talking to the SABnzbd at baseURL.
+func NewSABnzbd(name, baseURL, key string, options ...func(*SABnzbd)) *SABnzbd {
+	apiURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(apiURL, "/api") {
+		apiURL += "/api"
+	}
+	s := &SABnzbd{
+		ClientName: name,
+		URL:        apiURL,
+		APIKey:     key,
+		Client:     &http.Client{Timeout: clientTimeout},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	return s
+}
+
+// SetSABCategory is used in the NewSABnzbd constructor to set the category
+// downloads are added with.
+func SetSABCategory(cat string) func(*SABnzbd) {
+	return func(s *SABnzbd) {
+		s.Category = cat
+	}
+}
+
+// Name returns the client's name.
+func (s *SABnzbd) Name() string {
+	return s.ClientName
+}
+
+type sabResponse struct {
+	Status bool     `json:"status"`
+	Error  string   `json:"error"`
+	NzoIDs []string `json:"nzo_ids"`
+}
+
+type sabQueueSlot struct {
+	NzoID    string `json:"nzo_id"`
+	Filename string `json:"filename"`
+	Category string `json:"cat"`
+	Status   string `json:"status"`
+	MB       string `json:"mb"`
+	MBLeft   string `json:"mbleft"`
+	TimeLeft string `json:"timeleft"`
+}
+
+type sabQueueResponse struct {
+	Queue struct {
+		Slots []sabQueueSlot `json:"slots"`
+	} `json:"queue"`
+	Error string `json:"error"`
+}
+
+type sabHistorySlot struct {
+	NzoID       string `json:"nzo_id"`
+	Name        string `json:"name"`
+	Category    string `json:"category"`
+	Status      string `json:"status"`
+	Bytes       int64  `json:"bytes"`
+	Storage     string `json:"storage"`
+	FailMessage string `json:"fail_message"`
+}
+
+type sabHistoryResponse struct {
+	History struct {
+		Slots []sabHistorySlot `json:"slots"`
+	} `json:"history"`
+	Error string `json:"error"`
+}
+
+func (s *SABnzbd) params(mode string) url.Values {
+	u := url.Values{}
+	u.Set("mode", mode)
+	u.Set("output", "json")
+	u.Set("apikey", s.APIKey)
+	return u
+}
+
+func (s *SABnzbd) call(u url.Values, resp interface{}) error {
+	r, err := s.Client.Get(s.URL + "?" + u.Encode())
+	return s.decode(r, err, resp)
+}
+
+// decode reads a JSON API response into v.
+func (s *SABnzbd) decode(r *http.Response, err error, v interface{}) error {
+	if err != nil {
+		return fmt.Errorf("Error talking to SABnzbd %s: %s", s.ClientName, err)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("SABnzbd %s returned %s", s.ClientName, r.Status)
+	}
+	err = json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("Error decoding SABnzbd response: %s", err)
+	}
+	return nil
+}
+
+// Add sends the NZB contents to SABnzbd, or has it fetch the url if there
+// aren't any.
+func (s *SABnzbd) Add(d Download) (string, error) {
+	resp := &sabResponse{}
+	var err error
+	if len(d.Content) > 0 {
+		u := s.params("addfile")
+		u.Set("nzbname", d.Name)
+		if s.Category != "" {
+			u.Set("cat", s.Category)
+		}
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		filename := d.Filename
+		if filename == "" {
+			filename = d.Name + ".nzb"
+		}
+		part, perr := w.CreateFormFile("name", filename)
+		if perr != nil {
+			return "", perr
+		}
+		io.Copy(part, bytes.NewReader(d.Content))
+		w.Close()
+		r, perr := s.Client.Post(s.URL+"?"+u.Encode(), w.FormDataContentType(), body)
+		err = s.decode(r, perr, resp)
+	} else {
+		u := s.params("addurl")
+		u.Set("name", d.URL)
+		u.Set("nzbname", d.Name)
+		if s.Category != "" {
+			u.Set("cat", s.Category)
+		}
+		err = s.call(u, resp)
+	}
+	if err != nil {
+		return "", err
+	}
+	if !resp.Status || len(resp.NzoIDs) == 0 {
+		return "", fmt.Errorf("SABnzbd %s couldn't add %s: %s", s.ClientName, d.Name, resp.Error)
+	}
+	glog.Infof("Added %s to SABnzbd %s as %s", d.Name, s.ClientName, resp.NzoIDs[0])
+	return resp.NzoIDs[0], nil
+}
+
+func mbToBytes(mb string) int64 {
+	f, _ := strconv.ParseFloat(mb, 64)
+	return int64(f * 1024 * 1024)
+}
+
+func sabQueueStatus(status string) Status {
+	switch status {
+	case "Queued":
+		return QUEUED
+	case "Paused":
+		return PAUSED
+	default:
+		return DOWNLOADING
+	}
+}
+
+func sabHistoryStatus(status string) Status {
+	switch status {
+	case "Completed":
+		return COMPLETED
+	case "Failed":
+		return FAILED
+	default:
+		// Still post processing: Verifying, Repairing, Extracting etc.
+		return DOWNLOADING
+	}
+}
+
+// Queue returns the downloads SABnzbd is working on.
+func (s *SABnzbd) Queue() ([]Item, error) {
+	resp := &sabQueueResponse{}
+	err := s.call(s.params("queue"), resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("SABnzbd %s error: %s", s.ClientName, resp.Error)
+	}
+	items := make([]Item, len(resp.Queue.Slots))
+	for i, slot := range resp.Queue.Slots {
+		items[i] = Item{
+			ID:        slot.NzoID,
+			Name:      slot.Filename,
+			Category:  slot.Category,
+			Status:    sabQueueStatus(slot.Status),
+			Size:      mbToBytes(slot.MB),
+			Remaining: mbToBytes(slot.MBLeft),
+			ETA:       sabTimeLeft(slot.TimeLeft),
+		}
+	}
+	return items, nil
+}
+
+// History returns the downloads SABnzbd has finished with.
+func (s *SABnzbd) History() ([]Item, error) {
+	u := s.params("history")
+	if s.Category != "" {
+		u.Set("category", s.Category)
+	}
+	resp := &sabHistoryResponse{}
+	err := s.call(u, resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("SABnzbd %s error: %s", s.ClientName, resp.Error)
+	}
+	items := make([]Item, len(resp.History.Slots))
+	for i, slot := range resp.History.Slots {
+		items[i] = Item{
+			ID:       slot.NzoID,
+			Name:     slot.Name,
+			Category: slot.Category,
+			Status:   sabHistoryStatus(slot.Status),
+			Size:     slot.Bytes,
+			Path:     slot.Storage,
+			Message:  slot.FailMessage,
+		}
+	}
+	return items, nil
+}
+
+// Status returns the state of the download with the given nzo_id.
+func (s *SABnzbd) Status(id string) (*Item, error) {
+	return findItem(s, id)
+}
+
+// Remove deletes the download from SABnzbd's queue or history.
+func (s *SABnzbd) Remove(id string, deleteData bool) error {
+	_, queued, err := locateItem(s, id)
+	if err != nil {
+		return err
+	}
+	mode := "history"
+	if queued {
+		mode = "queue"
+	}
+	u := s.params(mode)
+	u.Set("name", "delete")
+	u.Set("value", id)
+	if deleteData {
+		u.Set("del_files", "1")
+	}
+	resp := &sabResponse{}
+	err = s.call(u, resp)
+	if err != nil {
+		return err
+	}
+	if !resp.Status {
+		return fmt.Errorf("SABnzbd %s couldn't remove %s: %s", s.ClientName, id, resp.Error)
+	}
+	return nil
+}
+
+// sabTimeLeft parses SABnzbd's [days:]hours:minutes:seconds time left.
+func sabTimeLeft(s string) time.Duration {
+	units := []time.Duration{time.Second, time.Minute, time.Hour, 24 * time.Hour}
+	parts := strings.Split(s, ":")
+	var left time.Duration
+	for i := range parts {
+		if i >= len(units) {
+			return 0
+		}
+		n, err := strconv.ParseInt(parts[len(parts)-1-i], 10, 64)
+		if err != nil {
+			return 0
+		}
+		left += time.Duration(n) * units[i]
+	}
+	return left
+}
@@ -0,0 +1,149 @@
+package downloaders
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const sabQueueJSON = `{"queue": {"slots": [
+  {"nzo_id": "SABnzbd_nzo_1", "filename": "Show.S01E01.720p", "cat": "tv2go", "status": "Downloading", "mb": "100.00", "mbleft": "50.00", "timeleft": "1:02:03"},
+  {"nzo_id": "SABnzbd_nzo_2", "filename": "Show.S01E02.720p", "cat": "tv2go", "status": "Paused", "mb": "1.00", "mbleft": "1.00"}
+]}}`
+
+const sabHistoryJSON = `{"history": {"slots": [
+  {"nzo_id": "SABnzbd_nzo_3", "name": "Show.S01E03.720p", "category": "tv2go", "status": "Completed", "bytes": 1024, "storage": "/downloads/Show.S01E03.720p"},
+  {"nzo_id": "SABnzbd_nzo_4", "name": "Show.S01E04.720p", "category": "tv2go", "status": "Failed", "bytes": 2048, "fail_message": "Out of retention"},
+  {"nzo_id": "SABnzbd_nzo_5", "name": "Show.S01E05.720p", "category": "tv2go", "status": "Extracting", "bytes": 4096}
+]}}`
+
+func testSABServer(t *testing.T) (*httptest.Server, *[]*http.Request) {
+	reqs := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs = append(reqs, r)
+		if r.URL.Path != "/sabnzbd/api" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("apikey") != "KEY" {
+			fmt.Fprint(w, `{"status": false, "error": "API Key Incorrect"}`)
+			return
+		}
+		switch r.URL.Query().Get("mode") {
+		case "addurl":
+			fmt.Fprint(w, `{"status": true, "nzo_ids": ["SABnzbd_nzo_url"]}`)
+		case "addfile":
+			f, h, err := r.FormFile("name")
+			if err != nil {
+				t.Errorf("No file in addfile request: %s", err)
+				return
+			}
+			content, _ := ioutil.ReadAll(f)
+			if h.Filename != "test.nzb" || string(content) != "nzb contents" {
+				t.Errorf("Unexpected file %s: %s", h.Filename, content)
+			}
+			fmt.Fprint(w, `{"status": true, "nzo_ids": ["SABnzbd_nzo_file"]}`)
+		case "queue":
+			if r.URL.Query().Get("name") == "delete" {
+				fmt.Fprint(w, `{"status": true}`)
+				return
+			}
+			fmt.Fprint(w, sabQueueJSON)
+		case "history":
+			if r.URL.Query().Get("name") == "delete" {
+				fmt.Fprint(w, `{"status": true}`)
+				return
+			}
+			fmt.Fprint(w, sabHistoryJSON)
+		}
+	}))
+	return server, &reqs
+}
+
+func TestSABnzbdAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testSABServer(t)
+	defer server.Close()
+
+	s := NewSABnzbd("sab", server.URL+"/sabnzbd/", "KEY", SetSABCategory("tv2go"))
+	Expect(s.URL).To(Equal(server.URL + "/sabnzbd/api"))
+
+	id, err := s.Add(Download{Name: "Show.S01E01", URL: "http://indexer/get/1"})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("SABnzbd_nzo_url"))
+	q := (*reqs)[0].URL.Query()
+	Expect(q.Get("name")).To(Equal("http://indexer/get/1"))
+	Expect(q.Get("nzbname")).To(Equal("Show.S01E01"))
+	Expect(q.Get("cat")).To(Equal("tv2go"))
+
+	id, err = s.Add(Download{Name: "Show.S01E01", Filename: "test.nzb", Content: []byte("nzb contents")})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("SABnzbd_nzo_file"))
+	Expect((*reqs)[1].Method).To(Equal("POST"))
+
+	s.APIKey = "WRONG"
+	_, err = s.Add(Download{Name: "Show.S01E01", URL: "http://indexer/get/1"})
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("API Key Incorrect"))
+}
+
+func TestSABnzbdQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, _ := testSABServer(t)
+	defer server.Close()
+
+	s := NewSABnzbd("sab", server.URL+"/sabnzbd", "KEY")
+	queue, err := s.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(queue).To(HaveLen(2))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Size).To(BeEquivalentTo(100 * 1024 * 1024))
+	Expect(queue[0].Remaining).To(BeEquivalentTo(50 * 1024 * 1024))
+	Expect(queue[0].ETA).To(Equal(time.Hour + 2*time.Minute + 3*time.Second))
+	Expect(queue[1].ETA).To(BeZero())
+	Expect(queue[1].Status).To(Equal(PAUSED))
+
+	history, err := s.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(3))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/Show.S01E03.720p"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Message).To(Equal("Out of retention"))
+	Expect(history[2].Status.Finished()).To(BeFalse())
+
+	item, err := s.Status("SABnzbd_nzo_3")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Name).To(Equal("Show.S01E03.720p"))
+
+	_, err = s.Status("missing")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestSABnzbdRemove(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testSABServer(t)
+	defer server.Close()
+
+	s := NewSABnzbd("sab", server.URL+"/sabnzbd/api", "KEY")
+	Expect(s.Remove("SABnzbd_nzo_1", true)).ToNot(HaveOccurred())
+	last := (*reqs)[len(*reqs)-1].URL.Query()
+	Expect(last.Get("mode")).To(Equal("queue"))
+	Expect(last.Get("value")).To(Equal("SABnzbd_nzo_1"))
+	Expect(last.Get("del_files")).To(Equal("1"))
+
+	Expect(s.Remove("SABnzbd_nzo_4", false)).ToNot(HaveOccurred())
+	last = (*reqs)[len(*reqs)-1].URL.Query()
+	Expect(last.Get("mode")).To(Equal("history"))
+	Expect(last.Get("del_files")).To(BeEmpty())
+
+	// Still being post processed but it's in the history.
+	Expect(s.Remove("SABnzbd_nzo_5", false)).ToNot(HaveOccurred())
+	last = (*reqs)[len(*reqs)-1].URL.Query()
+	Expect(last.Get("mode")).To(Equal("history"))
+	Expect(last.Get("value")).To(Equal("SABnzbd_nzo_5"))
+}
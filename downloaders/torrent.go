@@ -0,0 +1,32 @@
+package downloaders
+
+import (
+	"fmt"
+
+	"github.com/hobeone/tv2go/torrent"
+)
+
+// torrentSource works out whether a download is a magnet link or a .torrent
+// file and finds its info hash, which torrent clients use to identify it.
+// magnet is empty when the download should be added from its contents.
+func torrentSource(d Download) (magnet string, hash string, err error) {
+	switch {
+	case torrent.IsMagnet(string(d.Content)):
+		magnet = string(d.Content)
+	case len(d.Content) > 0:
+		m, err := torrent.Parse(d.Content)
+		if err != nil {
+			return "", "", fmt.Errorf("Invalid torrent for %s: %s", d.Name, err)
+		}
+		return "", m.InfoHash, nil
+	case torrent.IsMagnet(d.URL):
+		magnet = d.URL
+	default:
+		return "", "", fmt.Errorf("No torrent or magnet link for %s", d.Name)
+	}
+	m, err := torrent.ParseMagnet(magnet)
+	if err != nil {
+		return "", "", err
+	}
+	return magnet, m.InfoHash, nil
+}
@@ -0,0 +1,44 @@
+package downloaders
+
+import (
+	"testing"
+
+	"github.com/hobeone/tv2go/torrent"
+	. "github.com/onsi/gomega"
+)
+
+const testTorrent = "d8:announce23:http://tracker/announce4:infod6:lengthi1000e4:name20:Show.S01E01.720p.mkv12:piece lengthi16384e6:pieces0:ee"
+
+const testMagnet = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Show.S01E01.720p"
+
+func testTorrentHash(t *testing.T) string {
+	m, err := torrent.Parse([]byte(testTorrent))
+	if err != nil {
+		t.Fatalf("Error parsing test torrent: %s", err)
+	}
+	return m.InfoHash
+}
+
+func TestTorrentSource(t *testing.T) {
+	RegisterTestingT(t)
+
+	magnet, hash, err := torrentSource(Download{Name: "t", Content: []byte(testTorrent)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(magnet).To(BeEmpty())
+	Expect(hash).To(Equal(testTorrentHash(t)))
+
+	magnet, hash, err = torrentSource(Download{Name: "m", Content: []byte(testMagnet)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(magnet).To(Equal(testMagnet))
+	Expect(hash).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+
+	magnet, _, err = torrentSource(Download{Name: "m", URL: testMagnet})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(magnet).To(Equal(testMagnet))
+
+	_, _, err = torrentSource(Download{Name: "html", Content: []byte("<html></html>")})
+	Expect(err).To(HaveOccurred())
+
+	_, _, err = torrentSource(Download{Name: "url", URL: "http://example.com/t.torrent"})
+	Expect(err).To(HaveOccurred())
+}
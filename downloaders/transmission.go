@@ -0,0 +1,304 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const transmissionSessionHeader = "X-Transmission-Session-Id"
+
+// Transmission torrent states
+const (
+	trStopped = iota
+	trCheckWait
+	trCheck
+	trDownloadWait
+	trDownload
+	trSeedWait
+	trSeed
+)
+
+// trLocalError is the torrent error code for problems Transmission can't
+// recover from by itself, like running out of disk space.  Tracker errors are
+// left alone as they're usually temporary.
+const trLocalError = 3
+
+var transmissionFields = []string{
+	"hashString", "name", "status", "totalSize", "leftUntilDone",
+	"percentDone", "downloadDir", "error", "errorString", "eta",
+}
+
+// Transmission sends torrents to Transmission through its RPC interface.
+type Transmission struct {
+	ClientName string
+	URL        string // url of the rpc interface, eg http://localhost:9091/transmission/rpc
+	Username   string
+	Password   string
+	Dir        string // where to save downloads, Transmission's default if empty
+	Client     *http.Client
+
+	sessionLock sync.Mutex
+	sessionID   string
+}
+
+// NewTransmission creates a new client talking to the Transmission at
+// baseURL.
+func NewTransmission(name, baseURL, username, password string, options ...func(*Transmission)) *Transmission {
+	rpcURL := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(rpcURL, "/rpc") {
+		if !strings.HasSuffix(rpcURL, "/transmission") {
+			rpcURL += "/transmission"
+		}
+		rpcURL += "/rpc"
+	}
+	t := &Transmission{
+		ClientName: name,
+		URL:        rpcURL,
+		Username:   username,
+		Password:   password,
+		Client:     &http.Client{Timeout: clientTimeout},
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// SetTransmissionDir is used in the NewTransmission constructor to set the
+// directory downloads are saved to.
+func SetTransmissionDir(dir string) func(*Transmission) {
+	return func(t *Transmission) {
+		t.Dir = dir
+	}
+}
+
+// Name returns the client's name.
+func (t *Transmission) Name() string {
+	return t.ClientName
+}
+
+type transmissionRequest struct {
+	Method    string      `json:"method"`
+	Arguments interface{} `json:"arguments"`
+}
+
+type transmissionResponse struct {
+	Result    string          `json:"result"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type transmissionTorrent struct {
+	HashString    string  `json:"hashString"`
+	Name          string  `json:"name"`
+	Status        int     `json:"status"`
+	TotalSize     int64   `json:"totalSize"`
+	LeftUntilDone int64   `json:"leftUntilDone"`
+	PercentDone   float64 `json:"percentDone"`
+	DownloadDir   string  `json:"downloadDir"`
+	Error         int     `json:"error"`
+	ErrorString   string  `json:"errorString"`
+	ETA           int64   `json:"eta"` // seconds, negative if unknown
+}
+
+func (t *Transmission) session() string {
+	t.sessionLock.Lock()
+	defer t.sessionLock.Unlock()
+	return t.sessionID
+}
+
+func (t *Transmission) setSession(id string) {
+	t.sessionLock.Lock()
+	defer t.sessionLock.Unlock()
+	t.sessionID = id
+}
+
+// call makes an RPC call and decodes its arguments into result.  Transmission
+// rejects requests without a current session id with a 409 response that
+// includes a new one, so those are retried once with the new id.
+func (t *Transmission) call(method string, args interface{}, result interface{}) error {
+	body, err := json.Marshal(transmissionRequest{Method: method, Arguments: args})
+	if err != nil {
+		return err
+	}
+	var r *http.Response
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest("POST", t.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(transmissionSessionHeader, t.session())
+		if t.Username != "" {
+			req.SetBasicAuth(t.Username, t.Password)
+		}
+		r, err = t.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("Error talking to Transmission %s: %s", t.ClientName, err)
+		}
+		if r.StatusCode != http.StatusConflict {
+			break
+		}
+		r.Body.Close()
+		t.setSession(r.Header.Get(transmissionSessionHeader))
+		glog.Infof("Got new session id from Transmission %s", t.ClientName)
+	}
+	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("Transmission %s returned %s", t.ClientName, r.Status)
+	}
+	resp := &transmissionResponse{}
+	err = json.NewDecoder(r.Body).Decode(resp)
+	if err != nil {
+		return fmt.Errorf("Error decoding Transmission response: %s", err)
+	}
+	if resp.Result != "success" {
+		return fmt.Errorf("Transmission %s error calling %s: %s", t.ClientName, method, resp.Result)
+	}
+	if result == nil {
+		return nil
+	}
+	err = json.Unmarshal(resp.Arguments, result)
+	if err != nil {
+		return fmt.Errorf("Error decoding Transmission %s result: %s", method, err)
+	}
+	return nil
+}
+
+// Add sends the torrent or magnet link to Transmission.  The id is the
+// torrent's info hash.
+func (t *Transmission) Add(d Download) (string, error) {
+	magnet, hash, err := torrentSource(d)
+	if err != nil {
+		return "", err
+	}
+	args := map[string]interface{}{}
+	if magnet != "" {
+		args["filename"] = magnet
+	} else {
+		args["metainfo"] = base64.StdEncoding.EncodeToString(d.Content)
+	}
+	if t.Dir != "" {
+		args["download-dir"] = t.Dir
+	}
+	resp := struct {
+		Added     *transmissionTorrent `json:"torrent-added"`
+		Duplicate *transmissionTorrent `json:"torrent-duplicate"`
+	}{}
+	err = t.call("torrent-add", args, &resp)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case resp.Added != nil:
+		hash = resp.Added.HashString
+	case resp.Duplicate != nil:
+		glog.Infof("Transmission %s already has %s", t.ClientName, d.Name)
+		hash = resp.Duplicate.HashString
+	}
+	glog.Infof("Added %s to Transmission %s as %s", d.Name, t.ClientName, hash)
+	return hash, nil
+}
+
+func (t *Transmission) item(tt transmissionTorrent) Item {
+	item := Item{
+		ID:        tt.HashString,
+		Name:      tt.Name,
+		Size:      tt.TotalSize,
+		Remaining: tt.LeftUntilDone,
+		Path:      filepath.Join(tt.DownloadDir, tt.Name),
+	}
+	if tt.ETA > 0 {
+		item.ETA = time.Duration(tt.ETA) * time.Second
+	}
+	switch {
+	case tt.Error == trLocalError:
+		item.Status = FAILED
+		item.Message = tt.ErrorString
+	case tt.PercentDone >= 1 && tt.LeftUntilDone == 0:
+		item.Status = COMPLETED
+	case tt.Status == trStopped:
+		item.Status = PAUSED
+	case tt.Status == trDownloadWait || tt.Status == trCheckWait:
+		item.Status = QUEUED
+	default:
+		item.Status = DOWNLOADING
+	}
+	return item
+}
+
+// torrents returns all the torrents Transmission has, limited to the
+// download directory if one is set.
+func (t *Transmission) torrents() ([]Item, error) {
+	resp := struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}{}
+	err := t.call("torrent-get", map[string]interface{}{"fields": transmissionFields}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	items := []Item{}
+	for _, tt := range resp.Torrents {
+		if t.Dir != "" && filepath.Clean(tt.DownloadDir) != filepath.Clean(t.Dir) {
+			continue
+		}
+		items = append(items, t.item(tt))
+	}
+	return items, nil
+}
+
+// Queue returns the torrents Transmission is still downloading.
+func (t *Transmission) Queue() ([]Item, error) {
+	all, err := t.torrents()
+	if err != nil {
+		return nil, err
+	}
+	queue, _ := splitFinished(all)
+	return queue, nil
+}
+
+// History returns the torrents Transmission has finished or given up on.
+// Finished torrents may still be seeding.
+func (t *Transmission) History() ([]Item, error) {
+	all, err := t.torrents()
+	if err != nil {
+		return nil, err
+	}
+	_, history := splitFinished(all)
+	return history, nil
+}
+
+// Status returns the state of the torrent with the given info hash.
+func (t *Transmission) Status(id string) (*Item, error) {
+	resp := struct {
+		Torrents []transmissionTorrent `json:"torrents"`
+	}{}
+	err := t.call("torrent-get", map[string]interface{}{
+		"ids":    []string{id},
+		"fields": transmissionFields,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Torrents) == 0 {
+		return nil, fmt.Errorf("%s doesn't know about download %s", t.ClientName, id)
+	}
+	item := t.item(resp.Torrents[0])
+	return &item, nil
+}
+
+// Remove removes the torrent from Transmission.
+func (t *Transmission) Remove(id string, deleteData bool) error {
+	return t.call("torrent-remove", map[string]interface{}{
+		"ids":               []string{id},
+		"delete-local-data": deleteData,
+	}, nil)
+}
@@ -0,0 +1,125 @@
+package downloaders
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+const transmissionTorrentsJSON = `{"torrents": [
+  {"hashString": "aaaa", "name": "Show.S01E01.720p", "status": 4, "totalSize": 1000, "leftUntilDone": 400, "percentDone": 0.6, "downloadDir": "/downloads/tv2go", "eta": 30},
+  {"hashString": "bbbb", "name": "Show.S01E02.720p", "status": 6, "totalSize": 1000, "leftUntilDone": 0, "percentDone": 1, "downloadDir": "/downloads/tv2go"},
+  {"hashString": "cccc", "name": "Show.S01E03.720p", "status": 0, "totalSize": 1000, "leftUntilDone": 1000, "percentDone": 0, "downloadDir": "/downloads/tv2go", "error": 3, "errorString": "No space left on device"},
+  {"hashString": "dddd", "name": "Other.Thing", "status": 4, "totalSize": 1000, "leftUntilDone": 1000, "percentDone": 0, "downloadDir": "/downloads/other"}
+]}`
+
+type transmissionTestRequest struct {
+	Method    string                     `json:"method"`
+	Arguments map[string]json.RawMessage `json:"arguments"`
+}
+
+func testTransmissionServer(t *testing.T) (*httptest.Server, *[]transmissionTestRequest) {
+	reqs := []transmissionTestRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transmission/rpc" {
+			t.Errorf("Unexpected request path %s", r.URL.Path)
+		}
+		if r.Header.Get(transmissionSessionHeader) != "session1" {
+			w.Header().Set(transmissionSessionHeader, "session1")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		req := transmissionTestRequest{}
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil {
+			t.Errorf("Error decoding request: %s", err)
+			return
+		}
+		reqs = append(reqs, req)
+		switch req.Method {
+		case "torrent-add":
+			fmt.Fprint(w, `{"result": "success", "arguments": {"torrent-added": {"id": 1, "hashString": "c12fe1c06bba254a9dc9f519b335aa7c1367a88a", "name": "Show.S01E01.720p"}}}`)
+		case "torrent-get":
+			if _, ok := req.Arguments["ids"]; ok {
+				fmt.Fprint(w, `{"result": "success", "arguments": {"torrents": [{"hashString": "bbbb", "name": "Show.S01E02.720p", "status": 6, "percentDone": 1, "downloadDir": "/downloads/tv2go"}]}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"result": "success", "arguments": %s}`, transmissionTorrentsJSON)
+		case "torrent-remove":
+			fmt.Fprint(w, `{"result": "success", "arguments": {}}`)
+		default:
+			fmt.Fprint(w, `{"result": "method name not recognized", "arguments": {}}`)
+		}
+	}))
+	return server, &reqs
+}
+
+func TestTransmissionAdd(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testTransmissionServer(t)
+	defer server.Close()
+
+	tr := NewTransmission("transmission", server.URL, "", "", SetTransmissionDir("/downloads/tv2go"))
+	Expect(tr.URL).To(Equal(server.URL + "/transmission/rpc"))
+
+	id, err := tr.Add(Download{Name: "Show.S01E01.720p", Content: []byte(testMagnet)})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(id).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	Expect(tr.session()).To(Equal("session1"))
+	var filename, dir string
+	json.Unmarshal((*reqs)[0].Arguments["filename"], &filename)
+	json.Unmarshal((*reqs)[0].Arguments["download-dir"], &dir)
+	Expect(filename).To(Equal(testMagnet))
+	Expect(dir).To(Equal("/downloads/tv2go"))
+
+	_, err = tr.Add(Download{Name: "Show.S01E01.720p", Content: []byte(testTorrent)})
+	Expect(err).ToNot(HaveOccurred())
+	var metainfo string
+	json.Unmarshal((*reqs)[1].Arguments["metainfo"], &metainfo)
+	Expect(metainfo).To(Equal(base64.StdEncoding.EncodeToString([]byte(testTorrent))))
+}
+
+func TestTransmissionQueueAndHistory(t *testing.T) {
+	RegisterTestingT(t)
+	server, _ := testTransmissionServer(t)
+	defer server.Close()
+
+	tr := NewTransmission("transmission", server.URL+"/transmission/rpc", "", "", SetTransmissionDir("/downloads/tv2go/"))
+	queue, err := tr.Queue()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(queue).To(HaveLen(1))
+	Expect(queue[0].ID).To(Equal("aaaa"))
+	Expect(queue[0].Status).To(Equal(DOWNLOADING))
+	Expect(queue[0].Remaining).To(BeEquivalentTo(400))
+	Expect(queue[0].ETA).To(Equal(30 * time.Second))
+
+	history, err := tr.History()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(2))
+	Expect(history[0].Status).To(Equal(COMPLETED))
+	Expect(history[0].Path).To(Equal("/downloads/tv2go/Show.S01E02.720p"))
+	Expect(history[1].Status).To(Equal(FAILED))
+	Expect(history[1].Message).To(Equal("No space left on device"))
+
+	item, err := tr.Status("bbbb")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(item.Status).To(Equal(COMPLETED))
+}
+
+func TestTransmissionRemove(t *testing.T) {
+	RegisterTestingT(t)
+	server, reqs := testTransmissionServer(t)
+	defer server.Close()
+
+	tr := NewTransmission("transmission", server.URL, "", "")
+	Expect(tr.Remove("bbbb", true)).ToNot(HaveOccurred())
+	var deleteData bool
+	json.Unmarshal((*reqs)[0].Arguments["delete-local-data"], &deleteData)
+	Expect(deleteData).To(BeTrue())
+}
@@ -0,0 +1,169 @@
+package downloaders
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Just enough XML-RPC to talk to rTorrent.  Params can be strings, ints,
+// bools, []byte (sent as base64) and slices of those.  Results are decoded
+// into string, int64, bool, []byte, []interface{} and map[string]interface{}
+// values.
+
+type xmlrpcArray struct {
+	Values []xmlrpcValue `xml:"data>value"`
+}
+
+type xmlrpcStruct struct {
+	Members []xmlrpcMember `xml:"member"`
+}
+
+type xmlrpcMember struct {
+	Name  string      `xml:"name"`
+	Value xmlrpcValue `xml:"value"`
+}
+
+type xmlrpcValue struct {
+	String  *string       `xml:"string"`
+	Int     *string       `xml:"int"`
+	I4      *string       `xml:"i4"`
+	I8      *string       `xml:"i8"`
+	Boolean *string       `xml:"boolean"`
+	Double  *string       `xml:"double"`
+	Base64  *string       `xml:"base64"`
+	Array   *xmlrpcArray  `xml:"array"`
+	Struct  *xmlrpcStruct `xml:"struct"`
+	Text    string        `xml:",chardata"` // strings don't need a type
+}
+
+type xmlrpcResponse struct {
+	Params []xmlrpcValue `xml:"params>param>value"`
+	Fault  *xmlrpcValue  `xml:"fault>value"`
+}
+
+func (v xmlrpcValue) decode() (interface{}, error) {
+	switch {
+	case v.String != nil:
+		return *v.String, nil
+	case v.Int != nil:
+		return strconv.ParseInt(strings.TrimSpace(*v.Int), 10, 64)
+	case v.I4 != nil:
+		return strconv.ParseInt(strings.TrimSpace(*v.I4), 10, 64)
+	case v.I8 != nil:
+		return strconv.ParseInt(strings.TrimSpace(*v.I8), 10, 64)
+	case v.Boolean != nil:
+		return strings.TrimSpace(*v.Boolean) == "1", nil
+	case v.Double != nil:
+		return strconv.ParseFloat(strings.TrimSpace(*v.Double), 64)
+	case v.Base64 != nil:
+		return base64.StdEncoding.DecodeString(strings.TrimSpace(*v.Base64))
+	case v.Array != nil:
+		list := make([]interface{}, len(v.Array.Values))
+		for i, av := range v.Array.Values {
+			d, err := av.decode()
+			if err != nil {
+				return nil, err
+			}
+			list[i] = d
+		}
+		return list, nil
+	case v.Struct != nil:
+		m := make(map[string]interface{}, len(v.Struct.Members))
+		for _, member := range v.Struct.Members {
+			d, err := member.Value.decode()
+			if err != nil {
+				return nil, err
+			}
+			m[member.Name] = d
+		}
+		return m, nil
+	}
+	return v.Text, nil
+}
+
+func writeXMLRPCValue(buf *bytes.Buffer, v interface{}) error {
+	buf.WriteString("<value>")
+	switch t := v.(type) {
+	case string:
+		buf.WriteString("<string>")
+		xml.EscapeText(buf, []byte(t))
+		buf.WriteString("</string>")
+	case int:
+		fmt.Fprintf(buf, "<i4>%d</i4>", t)
+	case int64:
+		fmt.Fprintf(buf, "<i8>%d</i8>", t)
+	case bool:
+		if t {
+			buf.WriteString("<boolean>1</boolean>")
+		} else {
+			buf.WriteString("<boolean>0</boolean>")
+		}
+	case []byte:
+		fmt.Fprintf(buf, "<base64>%s</base64>", base64.StdEncoding.EncodeToString(t))
+	case []string:
+		buf.WriteString("<array><data>")
+		for _, s := range t {
+			writeXMLRPCValue(buf, s)
+		}
+		buf.WriteString("</data></array>")
+	case []interface{}:
+		buf.WriteString("<array><data>")
+		for _, av := range t {
+			err := writeXMLRPCValue(buf, av)
+			if err != nil {
+				return err
+			}
+		}
+		buf.WriteString("</data></array>")
+	default:
+		return fmt.Errorf("Can't encode %T as XML-RPC", v)
+	}
+	buf.WriteString("</value>")
+	return nil
+}
+
+// encodeXMLRPC creates a methodCall document.
+func encodeXMLRPC(method string, params ...interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	buf.WriteString("<methodCall><methodName>")
+	xml.EscapeText(buf, []byte(method))
+	buf.WriteString("</methodName><params>")
+	for _, p := range params {
+		buf.WriteString("<param>")
+		err := writeXMLRPCValue(buf, p)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("</param>")
+	}
+	buf.WriteString("</params></methodCall>")
+	return buf.Bytes(), nil
+}
+
+// decodeXMLRPC reads a methodResponse document, returning its value or the
+// fault as an error.
+func decodeXMLRPC(r io.Reader) (interface{}, error) {
+	resp := xmlrpcResponse{}
+	err := xml.NewDecoder(r).Decode(&resp)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding XML-RPC response: %s", err)
+	}
+	if resp.Fault != nil {
+		f, err := resp.Fault.decode()
+		if err != nil {
+			return nil, err
+		}
+		fault, _ := f.(map[string]interface{})
+		return nil, fmt.Errorf("XML-RPC fault %v: %v", fault["faultCode"], fault["faultString"])
+	}
+	if len(resp.Params) == 0 {
+		return nil, nil
+	}
+	return resp.Params[0].decode()
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/hobeone/go-pcre"
 	"github.com/hobeone/tv2go/quality"
@@ -41,6 +42,11 @@ var (
 
 	sampleRegex = regexp.MustCompile(`(?i)(^|[\W_])(sample\d*)[\W_]`)
 	extrasRegex = regexp.MustCompile(`(?i)extras?$`)
+
+	// Tags for a fixed release of an episode.  They're common words in
+	// titles too, so only count in capitals unless the whole release is in
+	// lower case.  REAL never counts in lower case.
+	properTags = map[string]bool{"PROPER": true, "REPACK": true, "RERIP": true}
 )
 
 // IsMediaExtension checks if the given string matches a known Media file
@@ -99,8 +105,11 @@ type ParseResult struct {
 	AbsoluteEpisodeNumbers []int64
 	Score                  int
 	Quality                quality.Quality
-	Version                string
+	Version                int64 // anime release version, eg 2 for v2, 0 if not given
+	Revision               int64 // 1 for the first release, more for each v2, PROPER, REPACK or REAL
 	RegexUsed              string
+
+	tags string // the part of the name after the series name, where release tags go
 }
 
 func (r *ParseResult) FirstEpisode() int64 {
@@ -113,6 +122,18 @@ func (r *ParseResult) FirstEpisode() int64 {
 	return 0
 }
 
+// HasEpisode returns true if the result identifies an episode, either by number
+// or by air date.
+func (r *ParseResult) HasEpisode() bool {
+	return len(r.EpisodeNumbers) > 0 || len(r.AbsoluteEpisodeNumbers) > 0 || !r.AirDate.IsZero()
+}
+
+// IsSeasonPack returns true if the result names a whole season rather than
+// individual episodes.
+func (r *ParseResult) IsSeasonPack() bool {
+	return r.RegexUsed == "season_only" && !r.HasEpisode()
+}
+
 type byScore []ParseResult
 
 func (a byScore) Len() int           { return len(a) }
@@ -140,6 +161,7 @@ var knownMatches = []string{
 	"release_group",
 	"air_date",
 	"series_num",
+	"version",
 }
 
 // Return named matches in a map
@@ -170,7 +192,11 @@ func (np *NameParser) parseString(name string) (*ParseResult, error) {
 				Score:        0 - i,
 			}
 
+			pr.tags = name
 			if m, ok := matches["series_name"]; ok {
+				if i := strings.Index(name, m); i >= 0 {
+					pr.tags = name[i+len(m):]
+				}
 				pr.SeriesName = m
 				pr.SeriesName = CleanSeriesName(pr.SeriesName)
 				pr.Score++
@@ -234,7 +260,12 @@ func (np *NameParser) parseString(name string) (*ParseResult, error) {
 				pr.Score++
 			}
 			if m, ok := matches["version"]; ok {
-				pr.Version = m
+				v, err := strconv.ParseInt(m, 10, 64)
+				if err != nil {
+					glog.Errorf("Error converting version '%s' to int from string: %s", m, pr.OriginalName)
+				} else {
+					pr.Version = v
+				}
 			}
 			matchResults = append(matchResults, pr)
 		}
@@ -249,7 +280,7 @@ func (np *NameParser) parseString(name string) (*ParseResult, error) {
 		return &matchResults[0], nil
 	}
 	glog.Warningf("Couldn't match %s with any regex", name)
-	return &ParseResult{}, fmt.Errorf("Couldn't parse string %s", name)
+	return &ParseResult{tags: name}, fmt.Errorf("Couldn't parse string %s", name)
 }
 
 func (np *NameParser) Parse(name string) ParseResult {
@@ -261,6 +292,7 @@ func (np *NameParser) Parse(name string) ParseResult {
 		glog.Infof("Found quality %s for '%s'", q.String(), name)
 	}
 	res.Quality = q
+	res.Revision = revision(res.tags, res.Version)
 	return *res
 }
 
@@ -291,9 +323,36 @@ func (np *NameParser) ParseFile(name string) ParseResult {
 	combineResults(finalRes, dirNameResult, fileNameResult, "ReleaseGroup")
 	combineResults(finalRes, dirNameResult, fileNameResult, "Version")
 	finalRes.Quality = q
+	// Tags are often in both the directory and file name so they can't be
+	// counted across the whole path.
+	finalRes.Revision = revision(fileNameResult.tags, finalRes.Version)
+	if dirRev := revision(dirNameResult.tags, finalRes.Version); dirRev > finalRes.Revision {
+		finalRes.Revision = dirRev
+	}
 	return *finalRes
 }
 
+// revision works out which release of an episode a name is from, given the
+// part of it after the series name so show names like Proper Manors don't
+// count.  The first release is revision 1, or the anime version if that's
+// higher, and each PROPER, REPACK or REAL tag adds one to that.
+func revision(tags string, version int64) int64 {
+	rev := int64(1)
+	if version > rev {
+		rev = version
+	}
+	lower := strings.ToLower(tags) == tags
+	words := strings.FieldsFunc(tags, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if properTags[w] || w == "REAL" || (lower && properTags[strings.ToUpper(w)]) {
+			rev++
+		}
+	}
+	return rev
+}
+
 // From src/pkg/encoding/json.
 func isEmptyValue(v reflect.Value) bool {
 	switch v.Kind() {
@@ -39,6 +39,56 @@ func TestNameParse(t *testing.T) {
 
 }
 
+func TestRevision(t *testing.T) {
+	RegisterTestingT(t)
+
+	tests := []struct {
+		name     string
+		version  int64
+		revision int64
+	}{
+		{"The.Flash.2014.S01E15.HDTV.x264-LOL", 0, 1},
+		{"The.Flash.2014.S01E15.PROPER.HDTV.x264-LOL", 0, 2},
+		{"the.flash.2014.s01e15.repack.hdtv.x264-lol", 0, 2},
+		{"The.Flash.2014.S01E15.Repack.HDTV.x264-LOL", 0, 1},
+		{"Show.S01E02.A.Proper.Goodbye.720p.HDTV.x264-LOL", 0, 1},
+		{"The.Flash.2014.S01E15.REAL.PROPER.HDTV.x264-LOL", 0, 3},
+		{"The.Real.Housewives.S01E15.HDTV.x264-LOL", 0, 1},
+		{"The.Flash.2014.S01E15.Improper.Conduct.HDTV.x264-LOL", 0, 1},
+		{"[HorribleSubs] Yowamushi Pedal - 20 [720p].mkv", 0, 1},
+		{"[HorribleSubs] Yowamushi Pedal - 20v2 [720p].mkv", 2, 2},
+		{"[HorribleSubs] Yowamushi Pedal - 20v2 REPACK [720p].mkv", 2, 3},
+	}
+	for _, test := range tests {
+		Expect(revision(test.name, test.version)).To(Equal(test.revision), "Expected revision %d for %s", test.revision, test.name)
+	}
+}
+
+func TestParseRevision(t *testing.T) {
+	RegisterTestingT(t)
+
+	np := NewNameParser(StandardRegexes)
+	Expect(np.Parse("The.Flash.2014.S01E15.PROPER.HDTV.x264-LOL").Revision).To(Equal(int64(2)))
+	// Words in the show name aren't release tags.
+	Expect(np.Parse("PROPER.Manors.S01E15.HDTV.x264-LOL").Revision).To(Equal(int64(1)))
+	Expect(np.Parse("proper.manors.s01e15.hdtv.x264-lol").Revision).To(Equal(int64(1)))
+	Expect(np.Parse("proper.manors.s01e15.repack.hdtv.x264-lol").Revision).To(Equal(int64(2)))
+	// Nor are ones in the episode title.
+	Expect(np.Parse("Show.S01E02.A.Proper.Goodbye.720p.HDTV.x264-LOL").Revision).To(Equal(int64(1)))
+
+	r := np.ParseFile("TV/Show/Show.S01E02.PROPER.720p.HDTV.x264-LOL/show.s01e02.720p.hdtv.x264-lol.mkv")
+	Expect(r.Revision).To(Equal(int64(2)))
+}
+
+func TestParseVersion(t *testing.T) {
+	RegisterTestingT(t)
+
+	np := NewNameParser(AnimeRegex)
+	r := np.Parse("[HorribleSubs] Yowamushi Pedal - 20v2 [720p].mkv")
+	Expect(r.Version).To(Equal(int64(2)))
+	Expect(r.Revision).To(Equal(int64(2)))
+}
+
 func TestNameParser(t *testing.T) {
 	RegisterTestingT(t)
 
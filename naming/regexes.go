@@ -585,6 +585,7 @@ var AnimeRegex = []NameRegex{
 					"release_group": "SGKK",
 					"ep_ab_num":     "312",
 					"extra_info":    "720p",
+					"version":       "1",
 				},
 			},
 		},
@@ -618,6 +619,7 @@ var AnimeRegex = []NameRegex{
 					"ep_ab_num":     "07",
 					"extra_info":    "720p",
 					"release_group": "Ayako",
+					"version":       "2",
 				},
 			},
 			{
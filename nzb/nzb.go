@@ -0,0 +1,82 @@
+// Package nzb reads NZB files, the XML files listing the usenet articles
+// which make up a download.
+package nzb
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Segment is a single usenet article.
+type Segment struct {
+	Bytes     int64  `xml:"bytes,attr"`
+	Number    int    `xml:"number,attr"`
+	MessageID string `xml:",chardata"`
+}
+
+// File is a file split across a number of articles.
+type File struct {
+	Poster   string    `xml:"poster,attr"`
+	Date     int64     `xml:"date,attr"`
+	Subject  string    `xml:"subject,attr"`
+	Groups   []string  `xml:"groups>group"`
+	Segments []Segment `xml:"segments>segment"`
+}
+
+// Size returns the total size of the file's articles.
+func (f *File) Size() int64 {
+	var size int64
+	for _, s := range f.Segments {
+		size += s.Bytes
+	}
+	return size
+}
+
+type meta struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// NZB is the contents of an NZB file.
+type NZB struct {
+	XMLName xml.Name `xml:"nzb"`
+	Metas   []meta   `xml:"head>meta"`
+	Files   []File   `xml:"file"`
+}
+
+// Meta returns the value of the named meta tag (title, password, tag etc).
+func (n *NZB) Meta(name string) string {
+	for _, m := range n.Metas {
+		if strings.EqualFold(m.Type, name) {
+			return strings.TrimSpace(m.Value)
+		}
+	}
+	return ""
+}
+
+// Size returns the total size of all of the files.
+func (n *NZB) Size() int64 {
+	var size int64
+	for i := range n.Files {
+		size += n.Files[i].Size()
+	}
+	return size
+}
+
+// Parse decodes an NZB file.
+func Parse(data []byte) (*NZB, error) {
+	n := &NZB{}
+	d := xml.NewDecoder(bytes.NewReader(data))
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderByName
+	d.Entity = xml.HTMLEntity
+	err := d.Decode(n)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding NZB: %s", err)
+	}
+	return n, nil
+}
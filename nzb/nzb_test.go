@@ -0,0 +1,35 @@
+package nzb
+
+import (
+	"io/ioutil"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParse(t *testing.T) {
+	RegisterTestingT(t)
+	data, err := ioutil.ReadFile("testdata/valid.nzb")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	n, err := Parse(data)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(n.Files).To(HaveLen(2))
+	Expect(n.Files[0].Groups).To(Equal([]string{"alt.binaries.teevee"}))
+	Expect(n.Files[0].Segments).To(HaveLen(2))
+	Expect(n.Files[0].Segments[1].MessageID).To(Equal("part2of2.abc@example.com"))
+	Expect(n.Files[0].Size()).To(BeEquivalentTo(600000))
+	Expect(n.Size()).To(BeEquivalentTo(1000000))
+	Expect(n.Meta("title")).To(Equal("New.Girl.S04E10.720p.WEB-DL.DD5.1.H.264-LFF"))
+	Expect(n.Meta("password")).To(BeEmpty())
+}
+
+func TestParseErrors(t *testing.T) {
+	RegisterTestingT(t)
+	_, err := Parse([]byte("<html><body>Request limit reached</body></html>"))
+	Expect(err).To(HaveOccurred())
+
+	_, err = Parse([]byte("Incorrect API key"))
+	Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,245 @@
+// Package postprocessor matches downloaded files to the episodes they're of
+// and moves them into their show's directory.
+package postprocessor
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/indexers"
+	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/quality"
+	"github.com/hobeone/tv2go/storage"
+	"github.com/hobeone/tv2go/types"
+)
+
+// Logger is called with progress messages while processing.
+type Logger func(format string, args ...interface{})
+
+// Postprocessor imports downloaded media files.
+type Postprocessor struct {
+	DBH      *db.Handle
+	Broker   *storage.Broker
+	Indexers indexers.IndexerRegistry
+}
+
+// NewPostprocessor creates a new Postprocessor.
+func NewPostprocessor(dbh *db.Handle, broker *storage.Broker, idxs indexers.IndexerRegistry) *Postprocessor {
+	return &Postprocessor{
+		DBH:      dbh,
+		Broker:   broker,
+		Indexers: idxs,
+	}
+}
+
+// FilesError is returned by ProcessPath when the files in the path can't be
+// listed.
+type FilesError struct {
+	Path string
+	Err  error
+}
+
+func (e *FilesError) Error() string {
+	return fmt.Sprintf("Couldn't get files from %s: %s", e.Path, e.Err)
+}
+
+func (p *Postprocessor) getShowFromName(name string, logf Logger) (*db.Show, int64, error) {
+	dbshow, season, err := p.DBH.GetShowByAllNames(name)
+	if err == nil {
+		logf("Found show with name %s in database.", dbshow.Name)
+		return dbshow, season, nil
+	}
+	logf("Couldn't find show with name in db: '%s'.", name)
+
+	sceneName := naming.FullSanitizeSceneName(name)
+	logf("Couldn't find show in exception list")
+
+	logf("Searching indexers for %s", sceneName)
+	for name, idxer := range p.Indexers {
+		logf("Search %s for %s", name, sceneName)
+		shows, err := idxer.Search(sceneName)
+		if err != nil {
+			logf("Error searching indexer for %s: %s", sceneName, err)
+			continue
+		}
+		logf("Got %d results from indexer %s", len(shows), name)
+		for _, show := range shows {
+			dbshow, err = p.DBH.GetShowByName(show.Name)
+			if err != nil {
+				logf("Error searching DB for show with name %s: %s", show.Name, err)
+				continue
+			}
+			logf("Found show in DB with name %s", dbshow.Name)
+			// Check that indexerid's match
+			//if dbshow.IndexerID != show.IndexerID {
+			//	logf("Indexer id for dbshow and indexer result differ (%d != %d), skipping.", dbshow.IndexerID, show.IndexerID)
+			//}
+			return dbshow, -1, nil
+		}
+	}
+	return nil, -1, fmt.Errorf("Couldn't match %s with any known show", name)
+}
+
+// ProcessPath tries to match the media files at path with a show and episode
+// and then moves them to where they should go.  If show isn't nil the files
+// are taken to be episodes of it rather than looking the show up by name.  It
+// returns the episodes that were imported.
+func (p *Postprocessor) ProcessPath(path string, show *db.Show, logf Logger) ([]db.Episode, error) {
+	if logf == nil {
+		logf = glog.Infof
+	}
+	if !p.Broker.Readable(path) {
+		return nil, fmt.Errorf("Can't open path '%s'", path)
+	}
+	mediaFiles, err := storage.MediaFilesInDir(path)
+	if err != nil {
+		return nil, &FilesError{Path: path, Err: err}
+	}
+	if len(mediaFiles) == 0 {
+		return nil, fmt.Errorf("Couldn't find any media files in '%s'", path)
+	}
+
+	goodresults := []naming.ParseResult{}
+
+	np := naming.NewNameParser(naming.AllRegexes)
+	for _, file := range mediaFiles {
+		logf("Trying to parse %s", file)
+		nameres := np.ParseFile(file)
+		if nameres.SeriesName == "" && show == nil {
+			logf("Couldn't parse series name from %s: skipping", file)
+			continue
+		}
+		if !nameres.HasEpisode() {
+			logf("Couldn't parse episode numbers from '%s'", path)
+			continue
+		}
+		logf("Parsed to %+v", nameres)
+		goodresults = append(goodresults, nameres)
+	}
+	if len(goodresults) == 0 {
+		return nil, fmt.Errorf("Couldn't parse any files in '%s'", path)
+	}
+
+	imported := []db.Episode{}
+	for _, res := range goodresults {
+		dbshow := show
+		if dbshow == nil {
+			var season int64
+			dbshow, season, err = p.getShowFromName(res.SeriesName, logf)
+			if err != nil {
+				logf("Couldn't find show with name: '%s'.", res.SeriesName)
+				continue
+			}
+			if season > -1 {
+				res.SeasonNumber = season
+			}
+		}
+
+		dbeps, err := p.DBH.GetEpisodesByParseResult(dbshow, &res)
+
+		if err != nil {
+			logf("Couldn't find an episode of %s for '%s'", dbshow.Name, res.OriginalName)
+			continue
+		}
+		dbep := dbeps[0]
+
+		ext := filepath.Ext(res.OriginalName)
+		//loc, err := dbep.GetLocation()
+		//Season 01/Show Name-S01E01-Ep Title.ext"
+		loc := "Season %02d/%s - S%02dE%02d - %s%s"
+		expandedLoc := fmt.Sprintf(loc, dbep.Season, dbshow.Name, dbep.Season, dbep.Episode, dbep.Name, ext)
+
+		expandedLoc = filepath.Join(dbshow.Location, expandedLoc)
+		existing := p.existingFiles(expandedLoc, dbeps)
+		if len(existing) > 0 && !isUpgrade(dbshow, dbeps, res.Quality, res.Revision) {
+			logf("File already exists at '%s'", existing[0])
+			continue
+		}
+
+		logf("Moving file %s to %s", res.OriginalName, expandedLoc)
+
+		err = p.Broker.MoveFile(res.OriginalName, expandedLoc)
+		if err != nil {
+			logf("Error moving file to location: %s", err)
+			continue
+		}
+
+		for _, old := range existing {
+			if old == expandedLoc {
+				continue
+			}
+			logf("Removing replaced file %s", old)
+			err = p.Broker.RemoveFile(old)
+			if err != nil {
+				logf("Error removing replaced file: %s", err)
+			}
+		}
+
+		// A multi-episode file is the location of every episode in it.
+		for _, dbep := range dbeps {
+			dbep.Location = expandedLoc
+			dbep.Status = types.DOWNLOADED
+			if res.Quality != quality.UNKNOWN {
+				dbep.Quality = res.Quality
+			}
+			dbep.Version = res.Revision
+		}
+		err = p.DBH.SaveEpisodes(dbeps)
+		if err != nil {
+			logf("Error saving new episode location: %s", err)
+			continue
+		}
+		for _, dbep := range dbeps {
+			imported = append(imported, *dbep)
+		}
+	}
+	return imported, nil
+}
+
+// existingFiles returns the files already on disk for the episodes: the
+// location a new file would be moved to and wherever the episodes' current
+// files are.
+func (p *Postprocessor) existingFiles(newLoc string, eps []*db.Episode) []string {
+	files := []string{}
+	seen := map[string]bool{}
+	for _, loc := range append([]string{newLoc}, episodeLocations(eps)...) {
+		if loc == "" || seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		if p.Broker.FileReadable(loc) == nil {
+			files = append(files, loc)
+		}
+	}
+	return files
+}
+
+func episodeLocations(eps []*db.Episode) []string {
+	locs := make([]string, len(eps))
+	for i, ep := range eps {
+		locs[i] = ep.Location
+	}
+	return locs
+}
+
+// isUpgrade returns true if a file of the given quality and revision should
+// replace the episodes' existing files: they were grabbed to be replaced, the
+// show's quality group prefers the new quality or it's a proper of the same
+// quality.
+func isUpgrade(show *db.Show, eps []*db.Episode, qual quality.Quality, rev int64) bool {
+	profile := show.QualityProfile()
+	for _, ep := range eps {
+		if ep.Status == types.SNATCHED_BEST || ep.Status == types.SNATCHED_PROPER {
+			return true
+		}
+		if ep.Quality != quality.UNKNOWN && profile.Rank(qual) > profile.Rank(ep.Quality) {
+			return true
+		}
+		if ep.Quality == qual && ep.Version > 0 && rev > ep.Version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// How long to keep using a provider's capabilities before asking again.
+const capsTTL = 24 * time.Hour
+
+// How long to wait before asking again when a provider's capabilities
+// couldn't be retrieved.
+const capsRetry = time.Hour
+
+// Capabilities describes the searches a provider supports.  For Newznab style
+// providers this comes from the t=caps API call.
+type Capabilities struct {
+	SearchAvailable   bool
+	TVSearchAvailable bool
+	TVSearchParams    []string
+	Categories        []Category
+	LimitsMax         int
+	LimitsDefault     int
+}
+
+// Category is a provider category and its subcategories.
+type Category struct {
+	ID      string
+	Name    string
+	Subcats []Category
+}
+
+// SupportsTVParam returns true if the given parameter (q, rid, tvdbid, season,
+// ep etc) can be used in a tv search.
+func (c *Capabilities) SupportsTVParam(param string) bool {
+	if !c.TVSearchAvailable {
+		return false
+	}
+	for _, p := range c.TVSearchParams {
+		if p == param {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultNewznabTVParams are assumed when an indexer doesn't list the
+// supportedParams of its tv-search.
+var defaultNewznabTVParams = []string{"q", "rid", "season", "ep"}
+
+// DefaultNewznabCapabilities are used when an indexer's capabilities can't be
+// retrieved.
+var DefaultNewznabCapabilities = Capabilities{
+	SearchAvailable:   true,
+	TVSearchAvailable: true,
+	TVSearchParams:    defaultNewznabTVParams,
+}
+
+type capsResponse struct {
+	XMLName string `xml:"caps"`
+	Limits  struct {
+		Max     string `xml:"max,attr"`
+		Default string `xml:"default,attr"`
+	} `xml:"limits"`
+	Search   capsSearch `xml:"searching>search"`
+	TVSearch capsSearch `xml:"searching>tv-search"`
+	Cats     []capsCat  `xml:"categories>category"`
+}
+
+type capsSearch struct {
+	Available       string `xml:"available,attr"`
+	SupportedParams string `xml:"supportedParams,attr"`
+}
+
+type capsCat struct {
+	ID      string    `xml:"id,attr"`
+	Name    string    `xml:"name,attr"`
+	Subcats []capsCat `xml:"subcat"`
+}
+
+func (c capsCat) toCategory() Category {
+	cat := Category{
+		ID:      c.ID,
+		Name:    c.Name,
+		Subcats: make([]Category, len(c.Subcats)),
+	}
+	for i, sub := range c.Subcats {
+		cat.Subcats[i] = sub.toCategory()
+	}
+	return cat
+}
+
+func splitParams(params string) []string {
+	res := []string{}
+	for _, p := range strings.Split(params, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			res = append(res, p)
+		}
+	}
+	return res
+}
+
+func (r *capsResponse) toCapabilities() *Capabilities {
+	caps := &Capabilities{
+		SearchAvailable:   r.Search.Available == "yes",
+		TVSearchAvailable: r.TVSearch.Available == "yes",
+		TVSearchParams:    splitParams(r.TVSearch.SupportedParams),
+		Categories:        make([]Category, len(r.Cats)),
+	}
+	if len(caps.TVSearchParams) == 0 {
+		caps.TVSearchParams = defaultNewznabTVParams
+	}
+	caps.LimitsMax, _ = strconv.Atoi(r.Limits.Max)
+	caps.LimitsDefault, _ = strconv.Atoi(r.Limits.Default)
+	for i, c := range r.Cats {
+		caps.Categories[i] = c.toCategory()
+	}
+	return caps
+}
+
+// getNewznabCaps asks a Newznab style API for its capabilities.
+func getNewznabCaps(b *BaseProvider, apiURL, apiKey string) (*Capabilities, error) {
+	u := url.Values{}
+	u.Add("t", "caps")
+	if apiKey != "" {
+		u.Add("apikey", apiKey)
+	}
+	queryURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid url for %s '%s': %s", b.Name(), apiURL, err)
+	}
+	queryURL.RawQuery = u.Encode()
+	glog.Infof("%s: Getting capabilities with %s", b.Name(), queryURL.String())
+	r := &capsResponse{}
+	_, err = b.get(queryURL.String(), func(body io.Reader) error {
+		d, err := newznabDecoder(body)
+		if err != nil {
+			return err
+		}
+		err = d.Decode(r)
+		if err != nil {
+			return fmt.Errorf("Error decoding %s capabilities: %s", b.Name(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.toCapabilities(), nil
+}
+
+// capsCache keeps a provider's Capabilities around so they are only fetched
+// once every capsTTL.  Failures are remembered for capsRetry so a provider
+// which can't return them isn't asked on every search, with the last
+// capabilities retrieved, if any, still used in the meantime.
+type capsCache struct {
+	mu      sync.Mutex
+	caps    *Capabilities
+	err     error
+	fetched time.Time
+}
+
+func (c *capsCache) get(fetch func() (*Capabilities, error)) (*Capabilities, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ttl := capsTTL
+	if c.err != nil {
+		ttl = capsRetry
+	}
+	if !c.fetched.IsZero() && time.Since(c.fetched) < ttl {
+		if c.caps != nil {
+			return c.caps, nil
+		}
+		return nil, c.err
+	}
+	caps, err := fetch()
+	c.fetched = time.Now()
+	c.err = err
+	if err != nil {
+		if c.caps != nil {
+			glog.Warningf("Error refreshing capabilities, using the old ones: %s", err)
+			return c.caps, nil
+		}
+		return nil, err
+	}
+	c.caps = caps
+	return caps, nil
+}
@@ -0,0 +1,165 @@
+package providers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func newCapsServer(t *testing.T) (*httptest.Server, *int, *[]string) {
+	caps, err := ioutil.ReadFile("testdata/newznab_caps.xml")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	feed, err := ioutil.ReadFile("testdata/nzbs_org_feed_single.rss")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	capsCalls := 0
+	queries := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(200)
+		if r.URL.Query().Get("t") == "caps" {
+			capsCalls++
+			w.Write(caps)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		w.Write(feed)
+	}))
+	return server, &capsCalls, &queries
+}
+
+func TestNewznabCapabilities(t *testing.T) {
+	RegisterTestingT(t)
+	server, capsCalls, _ := newCapsServer(t)
+	defer server.Close()
+
+	n := NewNewznab("test", server.URL, "API_KEY")
+	caps, err := n.Capabilities()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(caps.SearchAvailable).To(BeTrue())
+	Expect(caps.TVSearchAvailable).To(BeTrue())
+	Expect(caps.TVSearchParams).To(Equal([]string{"q", "rid", "tvdbid", "season", "ep"}))
+	Expect(caps.LimitsMax).To(Equal(100))
+	Expect(caps.LimitsDefault).To(Equal(50))
+	Expect(caps.Categories).To(HaveLen(1))
+	Expect(caps.Categories[0].ID).To(Equal("5000"))
+	Expect(caps.Categories[0].Subcats).To(HaveLen(7))
+	Expect(caps.SupportsTVParam("tvdbid")).To(BeTrue())
+	Expect(caps.SupportsTVParam("imdbid")).To(BeFalse())
+
+	// Second call is cached
+	_, err = n.Capabilities()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(*capsCalls).To(Equal(1))
+}
+
+func TestSearchQueryRestrict(t *testing.T) {
+	RegisterTestingT(t)
+	q := SearchQuery{ShowName: "The Office", TVDBID: 73244, TVRageID: 6061, Season: 1, Episode: 2}
+
+	r := q.restrict(&Capabilities{TVSearchAvailable: true, TVSearchParams: []string{"q", "rid", "tvdbid"}})
+	Expect(r).To(Equal(SearchQuery{TVDBID: 73244, Season: 1, Episode: 2}))
+
+	r = q.restrict(&Capabilities{TVSearchAvailable: true, TVSearchParams: []string{"q", "rid"}})
+	Expect(r).To(Equal(SearchQuery{TVRageID: 6061, Season: 1, Episode: 2}))
+
+	r = q.restrict(&Capabilities{TVSearchAvailable: true, TVSearchParams: []string{"q"}})
+	Expect(r).To(Equal(SearchQuery{ShowName: "The Office", Season: 1, Episode: 2}))
+
+	r = q.restrict(&Capabilities{})
+	Expect(r).To(Equal(SearchQuery{ShowName: "The Office", Season: 1, Episode: 2}))
+}
+
+func TestRegistrySearchUsesCapabilities(t *testing.T) {
+	RegisterTestingT(t)
+	server, _, queries := newCapsServer(t)
+	defer server.Close()
+
+	pr := ProviderRegistry{
+		"test": NewNewznab("test", server.URL, "API_KEY"),
+	}
+	res := pr.Search(SearchQuery{ShowName: "New Girl", TVDBID: 248682, TVRageID: 28304, Season: 4, Episode: 10})
+	Expect(res).To(HaveLen(1))
+	Expect(res[0].GUID).To(Equal("0d10aaaa66dc7ab5b442cc0265ebda83"))
+	Expect(*queries).To(HaveLen(1))
+	Expect((*queries)[0]).To(Equal("apikey=API_KEY&attrs=rageid%2Ctvdbid%2Cseason%2Cepisode&cat=5030%2C5040%2C5060%2C5070&ep=10&season=4&t=tvsearch&tvdbid=248682"))
+}
+
+func TestRegistrySearchWithoutCapabilities(t *testing.T) {
+	RegisterTestingT(t)
+	feed, err := ioutil.ReadFile("testdata/nzbs_org_feed_single.rss")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	capsCalls := 0
+	queries := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(200)
+		if r.URL.Query().Get("t") == "caps" {
+			capsCalls++
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><error code="203" description="Function not available"/>`))
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	pr := ProviderRegistry{
+		"test": NewNewznab("test", server.URL, "API_KEY"),
+	}
+	q := SearchQuery{ShowName: "New Girl", TVDBID: 248682, TVRageID: 28304, Season: 4, Episode: 10}
+	res := pr.Search(q)
+	Expect(res).To(HaveLen(1))
+	Expect(queries).To(HaveLen(1))
+	Expect(queries[0]).To(ContainSubstring("rid=28304"))
+	Expect(queries[0]).ToNot(ContainSubstring("q="))
+
+	// The failure is remembered rather than asked about on every search.
+	res = pr.Search(q)
+	Expect(res).To(HaveLen(1))
+	Expect(queries).To(HaveLen(2))
+	Expect(queries[1]).To(ContainSubstring("rid=28304"))
+	Expect(capsCalls).To(Equal(1))
+}
+
+func TestCapsCacheRetriesFailures(t *testing.T) {
+	RegisterTestingT(t)
+	c := capsCache{}
+	calls := 0
+	fail := func() (*Capabilities, error) {
+		calls++
+		return nil, fmt.Errorf("no caps")
+	}
+	_, err := c.get(fail)
+	Expect(err).To(HaveOccurred())
+	_, err = c.get(fail)
+	Expect(err).To(HaveOccurred())
+	Expect(calls).To(Equal(1))
+
+	// Asked again once capsRetry is up.
+	c.fetched = time.Now().Add(-capsRetry)
+	caps, err := c.get(func() (*Capabilities, error) {
+		calls++
+		return &Capabilities{SearchAvailable: true}, nil
+	})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(caps.SearchAvailable).To(BeTrue())
+	Expect(calls).To(Equal(2))
+
+	// A failed refresh keeps using the old capabilities.
+	c.fetched = time.Now().Add(-capsTTL)
+	caps, err = c.get(fail)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(caps.SearchAvailable).To(BeTrue())
+	Expect(calls).To(Equal(3))
+}
@@ -0,0 +1,271 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/rss"
+	"golang.org/x/net/html/charset"
+)
+
+// DefaultNewznabCategories are the standard Newznab TV categories (SD, HD,
+// Other and Sport) used when a provider doesn't configure its own.
+var DefaultNewznabCategories = []string{"5030", "5040", "5060", "5070"}
+
+var defaultNewznabAttrs = []string{"rageid", "tvdbid", "season", "episode"}
+
+// Newznab provides a client for any indexer implementing the Newznab API.
+type Newznab struct {
+	URL        string
+	APIKEY     string
+	Categories []string
+	Attrs      []string
+	caps       capsCache
+	NZBProvider
+}
+
+// NewNewznab creates a new Newznab client with the given name talking to the
+// API at apiURL.
+func NewNewznab(name, apiURL, key string, options ...func(*Newznab)) *Newznab {
+	n := &Newznab{
+		URL:        apiURL,
+		APIKEY:     key,
+		Categories: DefaultNewznabCategories,
+		Attrs:      defaultNewznabAttrs,
+		NZBProvider: NZBProvider{
+			BaseProvider: NewBaseProvider(name),
+		},
+	}
+	for _, option := range options {
+		option(n)
+	}
+	return n
+}
+
+// NewNzbsOrg creates a new Newznab client preset for nzbs.org
+func NewNzbsOrg(key string, options ...func(*Newznab)) *Newznab {
+	return NewNewznab("nzbsOrg", "https://nzbs.org/api", key, options...)
+}
+
+// SetClient is used in the NewNewznab constructor to set the http.Client to
+// use for all http/s calls.
+func SetClient(c *http.Client) func(*Newznab) {
+	return func(n *Newznab) {
+		n.Client = c
+	}
+}
+
+// SetCategories is used in the NewNewznab constructor to override the
+// categories searched on the indexer.
+func SetCategories(cats ...string) func(*Newznab) {
+	return func(n *Newznab) {
+		n.Categories = cats
+	}
+}
+
+// GetNewItems returns the latest items in the configured categories.
+func (n *Newznab) GetNewItems() ([]ProviderResult, error) {
+	u := url.Values{}
+	u.Add("apikey", n.APIKEY)
+	u.Add("t", "tvsearch")
+	u.Add("cat", strings.Join(n.Categories, ","))
+	u.Add("attrs", strings.Join(n.Attrs, ","))
+	return n.getRss(u)
+}
+
+// TvSearch searches for a given tv show with optional episode and season
+// constraints.
+//
+// API: t=tvsearch&q=beverly%20hillbillies&season=3&ep=1
+//  ?t=tvsearch&rid=5615&cat=5030,5070. Include &extended=1 to return extended information in the search results.
+func (n *Newznab) TvSearch(q SearchQuery) ([]ProviderResult, error) {
+	u := url.Values{}
+	u.Add("apikey", n.APIKEY)
+	u.Add("t", "tvsearch")
+	u.Add("cat", strings.Join(n.Categories, ","))
+	u.Add("attrs", strings.Join(n.Attrs, ","))
+	addNewznabSearchParams(u, q)
+
+	return n.getRss(u)
+}
+
+// Capabilities returns the indexer's capabilities from its t=caps call.  They
+// are cached for a day.
+func (n *Newznab) Capabilities() (*Capabilities, error) {
+	return n.caps.get(func() (*Capabilities, error) {
+		return getNewznabCaps(n.BaseProvider, n.URL, n.APIKEY)
+	})
+}
+
+// addNewznabSearchParams adds the show identifier, season and episode from
+// the query to a Newznab tvsearch request.  Air by date episodes are searched
+// for with the year as the season and month/day as the episode.  Anime is
+// searched for by name and absolute number.  Season searches leave out the
+// episode.
+func addNewznabSearchParams(u url.Values, q SearchQuery) {
+	if q.AbsoluteNumber != 0 {
+		u.Add("q", strings.Join([]string{q.ShowName, q.episodeTerm()}, " "))
+		return
+	}
+	switch {
+	case q.TVDBID != 0:
+		u.Add("tvdbid", strconv.FormatInt(q.TVDBID, 10))
+	case q.TVRageID != 0:
+		u.Add("rid", strconv.FormatInt(q.TVRageID, 10))
+	case q.IMDBID != "":
+		u.Add("imdbid", imdbNumber(q.IMDBID))
+	default:
+		u.Add("q", q.ShowName)
+	}
+	if !q.AirDate.IsZero() {
+		u.Add("season", q.AirDate.Format("2006"))
+		u.Add("ep", q.AirDate.Format("01/02"))
+		return
+	}
+	u.Add("season", strconv.FormatInt(q.Season, 10))
+	if !q.IsSeasonSearch() {
+		u.Add("ep", strconv.FormatInt(q.Episode, 10))
+	}
+}
+
+func (n *Newznab) getRss(u url.Values) ([]ProviderResult, error) {
+	r, err := getNewznabFeed(n.BaseProvider, n.URL, u)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ProviderResult, len(r.Items))
+	for i, story := range r.Items {
+		results[i] = newznabResult(story)
+		results[i].Type = n.Type()
+		results[i].ProviderName = n.Name()
+	}
+	n.stats.found(len(results))
+	return results, nil
+}
+
+// getNewznabFeed queries a Newznab style API (Newznab or Torznab) with the
+// given parameters and decodes the returned RSS feed.
+func getNewznabFeed(b *BaseProvider, apiURL string, u url.Values) (*rss.Rss, error) {
+	queryURL, err := url.Parse(apiURL)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid url for %s '%s': %s", b.Name(), apiURL, err)
+	}
+	queryURL.RawQuery = u.Encode()
+	glog.Infof("%s: Getting new items with %s", b.Name(), queryURL.String())
+	r := &rss.Rss{}
+	_, err = b.get(queryURL.String(), func(body io.Reader) error {
+		d, err := newznabDecoder(body)
+		if err != nil {
+			return err
+		}
+		d.DefaultSpace = "DefaultSpace"
+		err = d.Decode(r)
+		if err != nil {
+			return fmt.Errorf("Error decoding %s response: %s", b.Name(), err)
+		}
+		return nil
+	})
+	if err != nil {
+		glog.Errorf("%s: %s", b.Name(), err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// newznabError is the document Newznab style APIs send instead of results
+// when a request fails, often with a 200 status.
+type newznabError struct {
+	XMLName     xml.Name `xml:"error"`
+	Code        string   `xml:"code,attr"`
+	Description string   `xml:"description,attr"`
+}
+
+// newznabDecoder returns a decoder for a Newznab style API response, or the
+// API's error if it sent one.
+func newznabDecoder(body io.Reader) (*xml.Decoder, error) {
+	content, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	apiErr := newznabError{}
+	if xml.Unmarshal(content, &apiErr) == nil {
+		return nil, fmt.Errorf("API error %s: %s", apiErr.Code, apiErr.Description)
+	}
+	d := xml.NewDecoder(bytes.NewReader(content))
+	d.Strict = false
+	d.CharsetReader = charset.NewReaderByName
+	d.Entity = xml.HTMLEntity
+	return d, nil
+}
+
+// newznabResult converts a feed item to a ProviderResult using the
+// newznab:attr or torznab:attr elements where present.
+func newznabResult(story *rss.Item) ProviderResult {
+	parsedTime := &time.Time{}
+	pt, err := time.Parse(time.RFC1123Z, story.PubDate)
+	parsedTime = &pt
+	if err != nil {
+		glog.Warningf("Couldn't parse time '%s': %s", story.PubDate, err.Error())
+		parsedTime = nil
+	}
+	res := ProviderResult{
+		Age:  parsedTime,
+		Name: story.Title,
+		URL:  story.Link,
+	}
+	if story.Guid != nil {
+		res.GUID = story.Guid.Guid
+	}
+	if story.Enclosure != nil {
+		res.Size = parseIntOrZero(story.Enclosure.Length)
+		if res.URL == "" {
+			res.URL = story.Enclosure.Url
+		}
+	}
+	for _, attr := range story.NewzNabAttr {
+		switch attr.Name {
+		case "rageid":
+			res.TVRageID = parseIntOrZero(attr.Value)
+		case "tvdbid":
+			res.TVDBID = parseIntOrZero(attr.Value)
+		case "size":
+			if res.Size == 0 {
+				res.Size = parseIntOrZero(attr.Value)
+			}
+		case "seeders":
+			res.Seeders = parseIntOrZero(attr.Value)
+		case "peers":
+			res.Peers = parseIntOrZero(attr.Value)
+		case "infohash":
+			res.InfoHash = strings.ToLower(attr.Value)
+		case "magneturl":
+			res.MagnetURL = attr.Value
+		}
+	}
+	if res.URL == "" {
+		res.URL = res.MagnetURL
+	}
+	return res
+}
+
+func parseIntOrZero(str string) (i int64) {
+	if str == "" {
+		return
+	}
+	i, err := strconv.ParseInt(str, 10, 64)
+	if err != nil {
+		glog.Warningf("Couldn't parse to int: '%s': %s", str, err.Error())
+		i = 0
+	}
+	return
+}
@@ -2,6 +2,7 @@ package providers
 
 import (
 	"encoding/xml"
+	"io"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -57,15 +58,27 @@ func (n *NyaaTorrents) GetNewItems() ([]ProviderResult, error) {
 }
 
 // TvSearch searchs for a particular show
-func (n *NyaaTorrents) TvSearch(showName string, season, ep int64) ([]ProviderResult, error) {
+func (n *NyaaTorrents) TvSearch(q SearchQuery) ([]ProviderResult, error) {
 	u := url.Values{}
 	u.Add("page", "rss")
-	u.Add("term", strings.Join([]string{showName, strconv.FormatInt(ep, 10)}, " "))
+	u.Add("term", strings.Join([]string{q.ShowName, q.episodeTerm()}, " "))
 	u.Add("sort", "2")    // descending by seeders
 	u.Add("cats", "1_37") // eng translated anime
 	return n.getRss(u)
 }
 
+// nyaaCapabilities describes the plain text search NyaaTorrents supports.
+var nyaaCapabilities = &Capabilities{
+	SearchAvailable:   true,
+	TVSearchAvailable: true,
+	TVSearchParams:    []string{"q", "ep"},
+}
+
+// Capabilities returns the searches NyaaTorrents supports.
+func (n *NyaaTorrents) Capabilities() (*Capabilities, error) {
+	return nyaaCapabilities, nil
+}
+
 func (n *NyaaTorrents) getRss(u url.Values) ([]ProviderResult, error) {
 	urlStr := u.Encode()
 
@@ -73,23 +86,17 @@ func (n *NyaaTorrents) getRss(u url.Values) ([]ProviderResult, error) {
 	queryURL.RawQuery = urlStr
 
 	glog.Infof("Getting new items from nyaatorrents with %s", queryURL.String())
-	resp, err := n.Client.Get(queryURL.String())
-	if err != nil {
-		glog.Errorf("Error searching nyaaTorrents: %s", err)
-		return nil, err
-	}
-
 	r := rss.Rss{}
-	defer resp.Body.Close()
-	d := xml.NewDecoder(resp.Body)
-	d.Strict = false
-	//d.CharsetReader = charset.NewReader
-	d.DefaultSpace = "DefaultSpace"
-	d.Entity = xml.HTMLEntity
-
-	err = d.Decode(&r)
+	_, err := n.get(queryURL.String(), func(body io.Reader) error {
+		d := xml.NewDecoder(body)
+		d.Strict = false
+		//d.CharsetReader = charset.NewReader
+		d.DefaultSpace = "DefaultSpace"
+		d.Entity = xml.HTMLEntity
+		return d.Decode(&r)
+	})
 	if err != nil {
-		glog.Errorf("Error decoding nyaaTorrents response: %s", err)
+		glog.Errorf("Error searching nyaaTorrents: %s", err)
 		return nil, err
 	}
 
@@ -119,7 +126,11 @@ func (n *NyaaTorrents) getRss(u url.Values) ([]ProviderResult, error) {
 			ProviderName: n.Name(),
 			Anime:        true,
 		}
+		if item.Guid != nil {
+			results[i].GUID = item.Guid.Guid
+		}
 	}
+	n.stats.found(len(results))
 
 	return results, nil
 
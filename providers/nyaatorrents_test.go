@@ -35,7 +35,7 @@ func TestNyaa(t *testing.T) {
 
 	n := NewNyaaTorrents()
 	n.Client = httpClient
-	res, err := n.TvSearch("Yowamushi Pedal", 1, 1)
+	res, err := n.TvSearch(SearchQuery{ShowName: "Yowamushi Pedal", Season: 1, Episode: 1})
 	Expect(err).ToNot(HaveOccurred())
 	Expect(len(res)).To(Equal(100))
 }
@@ -46,7 +46,7 @@ func TestNyaaGetURL(t *testing.T) {
 		w.Header().Set("Content-Type", "application/xml")
 		w.Header().Set("Content-Disposition", "attachment; filename=test.torrent")
 		w.WriteHeader(200)
-		fmt.Fprintln(w, "testing123")
+		fmt.Fprint(w, testTorrent)
 	}))
 	defer server.Close()
 
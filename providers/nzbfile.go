@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"fmt"
+
+	"github.com/hobeone/tv2go/nzb"
+)
+
+// How far an NZB's total article size can be from the size the provider
+// listed.  This is looser than for torrents because of yEnc overhead and par2
+// files.
+const nzbSizeTolerance = 0.25
+
+// CheckNZB checks that downloaded content is an NZB for the result: it has to
+// parse, list at least one file, be about the size the provider said and not
+// be password protected.
+func CheckNZB(r ProviderResult, content []byte) error {
+	n, err := nzb.Parse(content)
+	if err != nil {
+		return err
+	}
+	if len(n.Files) == 0 {
+		return fmt.Errorf("NZB for %s has no files", r.Name)
+	}
+	if r.Size > 0 {
+		diff := float64(n.Size()-r.Size) / float64(r.Size)
+		if diff > nzbSizeTolerance || diff < -nzbSizeTolerance {
+			return fmt.Errorf("NZB size %d is too different from listed size %d for %s", n.Size(), r.Size, r.Name)
+		}
+	}
+	if n.Meta("password") != "" {
+		return fmt.Errorf("NZB for %s is password protected", r.Name)
+	}
+	return nil
+}
+
+// CheckDownload checks downloaded content is valid for the type of provider it
+// came from.
+func CheckDownload(t ProviderType, r ProviderResult, content []byte) error {
+	switch t {
+	case NZB:
+		return CheckNZB(r, content)
+	case TORRENT:
+		return CheckTorrent(r, content)
+	}
+	return nil
+}
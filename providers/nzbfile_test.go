@@ -0,0 +1,31 @@
+package providers
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCheckNZB(t *testing.T) {
+	RegisterTestingT(t)
+	content, err := ioutil.ReadFile("../nzb/testdata/valid.nzb")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	r := ProviderResult{Name: "New.Girl.S04E10.720p.WEB-DL.DD5.1.H.264-LFF", Size: 1000000}
+	Expect(CheckNZB(r, content)).ToNot(HaveOccurred())
+
+	r.Size = 5000000
+	Expect(CheckNZB(r, content)).To(MatchError("NZB size 1000000 is too different from listed size 5000000 for New.Girl.S04E10.720p.WEB-DL.DD5.1.H.264-LFF"))
+
+	r.Size = 0
+	passworded := strings.Replace(string(content), `<meta type="tag">HD</meta>`, `<meta type="password">secret</meta>`, 1)
+	Expect(CheckNZB(r, []byte(passworded))).To(MatchError("NZB for New.Girl.S04E10.720p.WEB-DL.DD5.1.H.264-LFF is password protected"))
+
+	empty := `<?xml version="1.0" encoding="utf-8" ?><nzb xmlns="http://www.newzbin.com/DTD/2003/nzb"><head></head></nzb>`
+	Expect(CheckNZB(r, []byte(empty))).To(MatchError("NZB for New.Girl.S04E10.720p.WEB-DL.DD5.1.H.264-LFF has no files"))
+
+	Expect(CheckNZB(r, []byte(`<error code="100" description="Incorrect user credentials"/>`))).To(HaveOccurred())
+}
@@ -7,30 +7,37 @@ import (
 	"github.com/hobeone/tv2go/db"
 )
 
+// DefaultSeenRetention is how long releases are remembered so they aren't sent
+// on again.
+const DefaultSeenRetention = 30 * 24 * time.Hour
+
 // ProviderPoller wraps the functionality to poll a Provider on a given
 // interval.
 type ProviderPoller struct {
 	Interval     time.Duration
-	ResponseChan chan (ProviderResult)
+	ResponseChan chan ([]ProviderResult)
 	Provider     Provider
 	LastPoll     time.Time
+	Retention    time.Duration // how long to remember seen releases
 	DBH          *db.Handle
 	after        func(time.Duration) <-chan time.Time // Allow for mocking out in test.
 }
 
 //NewProviderPoller returns a new configured ProviderPoller ready to use.
-func NewProviderPoller(p Provider, interval time.Duration, dbh *db.Handle, respChan chan (ProviderResult)) *ProviderPoller {
+func NewProviderPoller(p Provider, interval time.Duration, dbh *db.Handle, respChan chan ([]ProviderResult)) *ProviderPoller {
 	return &ProviderPoller{
 		Interval:     interval,
 		ResponseChan: respChan,
 		Provider:     p,
+		Retention:    DefaultSeenRetention,
 		DBH:          dbh,
 		after:        time.After,
 	}
 }
 
 // Poll is designed to be run in a goroutine and polls the Provider for new
-// items returning responses on p.ResponseChannel()
+// items returning each poll's new results together on p.ResponseChan so they
+// can be compared with each other.
 func (p *ProviderPoller) Poll() {
 	p.LastPoll = p.DBH.GetLastPollTime(p.Provider.Name())
 
@@ -55,11 +62,39 @@ func (p *ProviderPoller) Poll() {
 				glog.Errorf("error saving last poll time to db: %s", err)
 			}
 
+			err = p.DBH.PurgeSeenReleases(time.Now().Add(-p.Retention))
+			if err != nil {
+				glog.Errorf("error purging seen releases from db: %s", err)
+			}
+
 			glog.Infof("Got %d results from provider %s", len(resp), p.Provider.Name())
-			for _, r := range resp {
-				p.ResponseChan <- r
+			resp = p.newResults(resp)
+			glog.Infof("%d results from provider %s are new", len(resp), p.Provider.Name())
+			if len(resp) > 0 {
+				p.ResponseChan <- resp
 			}
 			glog.Infof("%s done processing, sleeping %s", p.Provider.Name(), toSleep.String())
 		}
 	}
 }
+
+// newResults records the results as seen and returns the ones which haven't
+// been seen before.  Results that can't be told apart from others are always
+// returned.
+func (p *ProviderPoller) newResults(resp []ProviderResult) []ProviderResult {
+	res := []ProviderResult{}
+	for _, r := range resp {
+		if r.ReleaseID() == "" {
+			res = append(res, r)
+			continue
+		}
+		isNew, err := p.DBH.AddSeenRelease(p.Provider.Name(), r.ReleaseID(), r.Name)
+		if err != nil {
+			glog.Errorf("error recording seen release %s: %s", r.Name, err)
+		}
+		if isNew || err != nil {
+			res = append(res, r)
+		}
+	}
+	return res
+}
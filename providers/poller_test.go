@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hobeone/tv2go/db"
+	. "github.com/onsi/gomega"
+)
+
+func TestPollerNewResults(t *testing.T) {
+	RegisterTestingT(t)
+	dbh := db.NewMemoryDBHandle(false, true)
+	p := NewProviderPoller(NewNzbsOrg("API_KEY"), DefaultSeenRetention, dbh, nil)
+
+	resp := []ProviderResult{
+		{Name: "show1.S01E01.720p-GRP", GUID: "abc"},
+		{Name: "show1.S01E02.720p-GRP", URL: "http://localhost/2.nzb"},
+	}
+	Expect(p.newResults(resp)).To(HaveLen(2))
+	Expect(p.newResults(resp)).To(BeEmpty())
+
+	resp = append(resp, ProviderResult{Name: "show1.S01E03.720p-GRP", GUID: "def"})
+	res := p.newResults(resp)
+	Expect(res).To(HaveLen(1))
+	Expect(res[0].GUID).To(Equal("def"))
+}
+
+func TestPollerNewResultsWithoutIDs(t *testing.T) {
+	RegisterTestingT(t)
+	dbh := db.NewMemoryDBHandle(false, true)
+	p := NewProviderPoller(NewNzbsOrg("API_KEY"), DefaultSeenRetention, dbh, nil)
+
+	published := time.Date(2015, 3, 8, 14, 43, 47, 0, time.UTC)
+	resp := []ProviderResult{
+		{Name: "show1.S01E01.720p-GRP", Age: &published},
+		{Name: "show1.S01E02.720p-GRP", Age: &published},
+		{Name: "show1.S01E03.720p-GRP"},
+		{},
+	}
+	Expect(resp[0].ReleaseID()).To(Equal("show1.S01E01.720p-GRP 2015-03-08T14:43:47Z"))
+	Expect(resp[2].ReleaseID()).To(Equal("show1.S01E03.720p-GRP"))
+	Expect(p.newResults(resp)).To(HaveLen(4))
+
+	// Only the result nothing is known about can't be recognised again.
+	Expect(p.newResults(resp)).To(HaveLen(1))
+}
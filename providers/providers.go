@@ -2,23 +2,149 @@ package providers
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/config"
+	"github.com/hobeone/tv2go/db"
 )
 
 // ProviderRegistry provides an easy way to map providers to string names
 type ProviderRegistry map[string]Provider
 
-func (pr ProviderRegistry) Search(showname string, season, epnum int64) []ProviderResult {
+// NewProviderRegistry creates a ProviderRegistry containing all of the enabled
+// providers in the given configuration.
+func NewProviderRegistry(cfgs []config.ProviderConfig) (ProviderRegistry, error) {
+	pr := ProviderRegistry{}
+	for _, cfg := range cfgs {
+		if !cfg.Enabled {
+			glog.Infof("Provider %s is disabled, skipping", cfg.Name)
+			continue
+		}
+		if _, ok := pr[cfg.Name]; ok {
+			return nil, fmt.Errorf("Duplicate provider name: %s", cfg.Name)
+		}
+		p, err := NewProvider(cfg)
+		if err != nil {
+			return nil, err
+		}
+		pr[cfg.Name] = p
+	}
+	return pr, nil
+}
+
+// NewProvider creates a Provider from the given configuration.
+func NewProvider(cfg config.ProviderConfig) (Provider, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("Provider name can not be empty")
+	}
+	switch strings.ToLower(cfg.Type) {
+	case "newznab":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("Newznab provider %s needs a URL", cfg.Name)
+		}
+		n := newNewznabFromConfig(cfg, NewNewznab(cfg.Name, cfg.URL, cfg.API))
+		configureBase(cfg, n.BaseProvider)
+		return n, nil
+	case "torznab":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("Torznab provider %s needs a URL", cfg.Name)
+		}
+		t := NewTorznab(cfg.Name, cfg.URL, cfg.API)
+		if len(cfg.Categories) > 0 {
+			t.Categories = cfg.Categories
+		}
+		configureBase(cfg, t.BaseProvider)
+		return t, nil
+	case "nzbsorg":
+		n := newNewznabFromConfig(cfg, NewNzbsOrg(cfg.API))
+		configureBase(cfg, n.BaseProvider)
+		return n, nil
+	case "nyaatorrents":
+		n := NewNyaaTorrents()
+		n.ProviderName = cfg.Name
+		if cfg.URL != "" {
+			n.URL = cfg.URL
+		}
+		configureBase(cfg, n.BaseProvider)
+		return n, nil
+	case "":
+		return nil, fmt.Errorf("Provider %s needs a Type: newznab, torznab, nzbsorg or nyaatorrents", cfg.Name)
+	default:
+		return nil, fmt.Errorf("Unknown type '%s' for provider %s", cfg.Type, cfg.Name)
+	}
+}
+
+// configureBase applies the config settings shared by all providers.
+func configureBase(cfg config.ProviderConfig, b *BaseProvider) {
+	b.SetLimits(RequestLimits{
+		PerMinute: cfg.RequestsPerMinute,
+		PerDay:    cfg.RequestsPerDay,
+	})
+	if cfg.Timeout > 0 {
+		b.Client.Timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+}
+
+// newNewznabFromConfig applies the optional config settings on top of a
+// Newznab preset.
+func newNewznabFromConfig(cfg config.ProviderConfig, n *Newznab) *Newznab {
+	n.ProviderName = cfg.Name
+	if cfg.URL != "" {
+		n.URL = cfg.URL
+	}
+	if len(cfg.Categories) > 0 {
+		n.Categories = cfg.Categories
+	}
+	return n
+}
+
+// LoadState restores every provider's request counts and backoff from the
+// database.
+func (pr ProviderRegistry) LoadState(dbh *db.Handle) {
+	for name, p := range pr {
+		err := p.LoadState(dbh)
+		if err != nil {
+			glog.Errorf("Error loading state for provider %s: %s", name, err)
+		}
+	}
+}
+
+// Search searches all providers for the given query.  Each provider is sent
+// the most precise show identifier it supports, falling back to searching by
+// name if that finds nothing.  Results with show IDs that don't match the
+// query are dropped.
+func (pr ProviderRegistry) Search(q SearchQuery) []ProviderResult {
 	res := []ProviderResult{}
 	for _, provider := range pr {
-		resultset, err := provider.TvSearch(showname, season, epnum)
-		if err == nil {
-			res = append(res, resultset...)
+		caps, err := provider.Capabilities()
+		if err != nil {
+			glog.Errorf("Error getting capabilities for %s, using the Newznab defaults: %s", provider.Name(), err)
+			defaults := DefaultNewznabCapabilities
+			caps = &defaults
+		}
+		pq := q.restrict(caps)
+		resultset, err := provider.TvSearch(pq)
+		if err != nil {
+			glog.Errorf("Error searching %s: %s", provider.Name(), err)
+		}
+		if len(resultset) == 0 && pq.ShowName == "" && q.ShowName != "" {
+			glog.Infof("No results from %s searching by id, trying name %s", provider.Name(), q.ShowName)
+			resultset, err = provider.TvSearch(pq.byName(q.ShowName))
+			if err != nil {
+				glog.Errorf("Error searching %s: %s", provider.Name(), err)
+			}
+		}
+		for _, r := range resultset {
+			if !q.Matches(r) {
+				glog.Infof("Dropping result %s from %s, show ids don't match", r.Name, provider.Name())
+				continue
+			}
+			res = append(res, r)
 		}
 	}
 	return res
@@ -33,7 +159,11 @@ type ProviderResult struct {
 	Quality      string       `json:"quality"`
 	ProviderName string       `json:"indexer"`
 	URL          string       `json:"url"`
+	GUID         string       `json:"guid,omitempty"`
 	Seeders      int64        `json:"seeders"`
+	Peers        int64        `json:"peers"`
+	InfoHash     string       `json:"info_hash,omitempty"`
+	MagnetURL    string       `json:"magnet_url,omitempty"`
 	TVRageID     int64        `json:"tvrage_id"`
 	TVDBID       int64        `json:"tvdb_id"`
 	Season       string       `json:"season"`
@@ -41,11 +171,33 @@ type ProviderResult struct {
 	Anime        bool         `json:"anime"`
 }
 
+// ReleaseID returns an identifier for the release which is unique for its
+// provider: the feed item's guid, or its download url or magnet link if it
+// doesn't have one, or failing those its name and publish date.  It's empty if
+// none of them are known.
+func (r ProviderResult) ReleaseID() string {
+	switch {
+	case r.GUID != "":
+		return r.GUID
+	case r.URL != "":
+		return r.URL
+	case r.MagnetURL != "":
+		return r.MagnetURL
+	case r.Name != "" && r.Age != nil:
+		return r.Name + " " + r.Age.UTC().Format(time.RFC3339)
+	default:
+		return r.Name
+	}
+}
+
 // Provider defines the interface a tv2go provider must implement
 type Provider interface {
 	Name() string
 
-	TvSearch(string, int64, int64) ([]ProviderResult, error)
+	TvSearch(SearchQuery) ([]ProviderResult, error)
+
+	// Return the searches this provider supports.
+	Capabilities() (*Capabilities, error)
 	//need better name
 	//Get file contents, leave it to something else to save it to disk
 	GetURL(URL string) (string, []byte, error)
@@ -55,6 +207,13 @@ type Provider interface {
 	// Get new items on the provider.  Will usually mean hitting a rss feed or
 	// something.
 	GetNewItems() ([]ProviderResult, error)
+
+	// Restore request counts and backoff from the database and keep them
+	// saved there.
+	LoadState(dbh *db.Handle) error
+
+	// Return request statistics and whether the provider is working.
+	Status() ProviderStatus
 }
 
 // BaseProvider is the struct used for shared functionality of all providers.
@@ -62,13 +221,18 @@ type BaseProvider struct {
 	ProviderName string
 	Client       *http.Client
 	PollInterval time.Duration
+	Limits       RequestLimits
+	throttle     *throttle
+	stats        *stats
 	after        func(time.Duration) <-chan (time.Time)
 }
 
 func NewBaseProvider(name string) *BaseProvider {
 	return &BaseProvider{
 		ProviderName: name,
-		Client:       &http.Client{},
+		Client:       &http.Client{Timeout: DefaultTimeout},
+		throttle:     newThrottle(),
+		stats:        &stats{},
 		after:        time.After,
 		PollInterval: time.Minute * 15, // reasonable default
 	}
@@ -81,13 +245,18 @@ func (b *BaseProvider) Name() string {
 //GetURL is designed to be used to download a file from a URL
 func (b *BaseProvider) GetURL(u string) (string, []byte, error) {
 	glog.Infof("Getting URL %s", u)
-	resp, err := b.Client.Get(u)
+	var content []byte
+	header, err := b.get(u, func(body io.Reader) error {
+		var err error
+		content, err = ioutil.ReadAll(body)
+		return err
+	})
 	if err != nil {
 		return "", nil, err
 	}
 
 	filename := ""
-	contHeader := resp.Header.Get("Content-Disposition")
+	contHeader := header.Get("Content-Disposition")
 	res := strings.Split(contHeader, "; ")
 	for _, res := range res {
 		if strings.HasPrefix(res, "filename=") {
@@ -96,11 +265,7 @@ func (b *BaseProvider) GetURL(u string) (string, []byte, error) {
 	}
 	filename = strings.Trim(filename, "\"")
 
-	defer resp.Body.Close()
-	content, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return filename, content, err
-	}
+	b.stats.grab()
 	return filename, content, nil
 }
 
@@ -1,10 +1,66 @@
 package providers
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/hobeone/tv2go/config"
+	. "github.com/onsi/gomega"
+)
 
 func TestAllProvidersImplementInterface(t *testing.T) {
 	_ = ProviderRegistry{
 		"nzbsorg":      NewNzbsOrg(""),
 		"nyaatorrents": NewNyaaTorrents(),
+		"torznab":      NewTorznab("torznab", "", ""),
+	}
+}
+
+func TestNewProviderRegistry(t *testing.T) {
+	RegisterTestingT(t)
+	cfgs := []config.ProviderConfig{
+		{Name: "nzbsOrg", Type: "nzbsorg", API: "123", Enabled: true},
+		{Name: "indexer1", Type: "newznab", URL: "http://localhost/api", API: "456", Categories: []string{"5040"}, Enabled: true},
+		{Name: "indexer2", Type: "newznab", URL: "http://localhost/api", Enabled: false},
+		{Name: "anime", Type: "nyaatorrents", Enabled: true},
 	}
+	pr, err := NewProviderRegistry(cfgs)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(pr).To(HaveLen(3))
+
+	n, ok := pr["indexer1"].(*Newznab)
+	Expect(ok).To(BeTrue())
+	Expect(n.Name()).To(Equal("indexer1"))
+	Expect(n.URL).To(Equal("http://localhost/api"))
+	Expect(n.APIKEY).To(Equal("456"))
+	Expect(n.Categories).To(Equal([]string{"5040"}))
+
+	nzbsorg := pr["nzbsOrg"].(*Newznab)
+	Expect(nzbsorg.URL).To(Equal("https://nzbs.org/api"))
+	Expect(nzbsorg.Categories).To(Equal(DefaultNewznabCategories))
+
+	Expect(pr["anime"].Name()).To(Equal("anime"))
+}
+
+func TestNewProviderRegistryErrors(t *testing.T) {
+	RegisterTestingT(t)
+	_, err := NewProviderRegistry([]config.ProviderConfig{
+		{Name: "bad", Type: "unknown", Enabled: true},
+	})
+	Expect(err).To(MatchError("Unknown type 'unknown' for provider bad"))
+
+	_, err = NewProviderRegistry([]config.ProviderConfig{
+		{Name: "notype", Enabled: true},
+	})
+	Expect(err).To(MatchError("Provider notype needs a Type: newznab, torznab, nzbsorg or nyaatorrents"))
+
+	_, err = NewProviderRegistry([]config.ProviderConfig{
+		{Name: "nourl", Type: "newznab", Enabled: true},
+	})
+	Expect(err).To(MatchError("Newznab provider nourl needs a URL"))
+
+	_, err = NewProviderRegistry([]config.ProviderConfig{
+		{Name: "dupe", Type: "nzbsorg", Enabled: true},
+		{Name: "dupe", Type: "nyaatorrents", Enabled: true},
+	})
+	Expect(err).To(MatchError("Duplicate provider name: dupe"))
 }
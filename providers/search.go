@@ -0,0 +1,135 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hobeone/tv2go/db"
+)
+
+// SearchQuery describes an episode to search providers for.  Providers use
+// whichever identifiers their site understands.
+type SearchQuery struct {
+	Indexer        string // indexer the show information came from
+	ShowName       string
+	TVDBID         int64
+	TVRageID       int64
+	IMDBID         string
+	Season         int64
+	Episode        int64
+	AirDate        time.Time // only set for shows searched by air date
+	AbsoluteNumber int64     // only set for anime
+}
+
+// NewSearchQuery creates a SearchQuery for the given show and episode.  The
+// episode may be nil to search for the show only.
+func NewSearchQuery(show *db.Show, ep *db.Episode) SearchQuery {
+	q := SearchQuery{
+		Indexer:  show.Indexer,
+		ShowName: show.Name,
+		IMDBID:   show.ImdbID,
+	}
+	switch show.Indexer {
+	case "tvdb":
+		q.TVDBID = show.IndexerID
+	case "tvrage":
+		q.TVRageID = show.IndexerID
+	}
+	if ep != nil {
+		q.Season = ep.Season
+		q.Episode = ep.Episode
+		if show.AirByDate {
+			q.AirDate = ep.AirDate
+		}
+		if show.Anime {
+			q.AbsoluteNumber = ep.SceneAbsoluteNumber
+			if q.AbsoluteNumber == 0 {
+				q.AbsoluteNumber = ep.AbsoluteNumber
+			}
+		}
+	}
+	return q
+}
+
+// NewSeasonSearchQuery creates a SearchQuery for releases containing a whole
+// season of the show.
+func NewSeasonSearchQuery(show *db.Show, season int64) SearchQuery {
+	q := NewSearchQuery(show, nil)
+	q.Season = season
+	return q
+}
+
+// IsSeasonSearch returns true if the query is for a whole season rather than
+// an episode.
+func (q SearchQuery) IsSeasonSearch() bool {
+	return q.Episode == 0 && q.AirDate.IsZero() && q.AbsoluteNumber == 0
+}
+
+// restrict returns a copy of the query with only the most precise show
+// identifier the given Capabilities support: tvdbid, then rid, imdbid and
+// finally the show name.  Absolute numbers only make sense in a release name
+// so anime is always searched for by name.
+func (q SearchQuery) restrict(caps *Capabilities) SearchQuery {
+	r := q
+	r.ShowName = ""
+	r.TVDBID = 0
+	r.TVRageID = 0
+	r.IMDBID = ""
+	switch {
+	case q.AbsoluteNumber != 0:
+		r.ShowName = q.ShowName
+	case q.TVDBID != 0 && caps.SupportsTVParam("tvdbid"):
+		r.TVDBID = q.TVDBID
+	case q.TVRageID != 0 && caps.SupportsTVParam("rid"):
+		r.TVRageID = q.TVRageID
+	case q.IMDBID != "" && caps.SupportsTVParam("imdbid"):
+		r.IMDBID = q.IMDBID
+	default:
+		r.ShowName = q.ShowName
+	}
+	return r
+}
+
+// byName returns a copy of the query searching by the given show name instead
+// of any show ids.
+func (q SearchQuery) byName(name string) SearchQuery {
+	q.ShowName = name
+	q.TVDBID = 0
+	q.TVRageID = 0
+	q.IMDBID = ""
+	return q
+}
+
+// Matches returns false if the result has a show id which contradicts an id
+// in the query.
+func (q SearchQuery) Matches(r ProviderResult) bool {
+	if q.TVDBID != 0 && r.TVDBID != 0 && q.TVDBID != r.TVDBID {
+		return false
+	}
+	if q.TVRageID != 0 && r.TVRageID != 0 && q.TVRageID != r.TVRageID {
+		return false
+	}
+	return true
+}
+
+// episodeTerm returns the part of a plain text search identifying the
+// episode: the air date for air by date shows, the absolute number for anime,
+// Sxx for season searches and SxxEyy for everything else.
+func (q SearchQuery) episodeTerm() string {
+	switch {
+	case q.IsSeasonSearch():
+		return fmt.Sprintf("S%02d", q.Season)
+	case !q.AirDate.IsZero():
+		return q.AirDate.Format("2006.01.02")
+	case q.AbsoluteNumber != 0:
+		return fmt.Sprintf("%02d", q.AbsoluteNumber)
+	default:
+		return fmt.Sprintf("S%02dE%02d", q.Season, q.Episode)
+	}
+}
+
+// imdbNumber strips the tt prefix from IMDB ids, which Newznab doesn't want.
+func imdbNumber(id string) string {
+	return strings.TrimPrefix(id, "tt")
+}
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hobeone/tv2go/db"
+	. "github.com/onsi/gomega"
+)
+
+func TestNewSearchQuery(t *testing.T) {
+	RegisterTestingT(t)
+	airDate := time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC)
+	show := &db.Show{Name: "New Girl", Indexer: "tvdb", IndexerID: 248682, ImdbID: "tt1826940", AirByDate: true}
+	ep := &db.Episode{Season: 4, Episode: 10, AirDate: airDate, AbsoluteNumber: 82}
+
+	q := NewSearchQuery(show, ep)
+	Expect(q).To(Equal(SearchQuery{
+		Indexer:  "tvdb",
+		ShowName: "New Girl",
+		TVDBID:   248682,
+		IMDBID:   "tt1826940",
+		Season:   4,
+		Episode:  10,
+		AirDate:  airDate,
+	}))
+
+	show.AirByDate = false
+	q = NewSearchQuery(show, ep)
+	Expect(q.AirDate.IsZero()).To(BeTrue())
+
+	show.Indexer = "tvrage"
+	q = NewSearchQuery(show, nil)
+	Expect(q.TVDBID).To(BeZero())
+	Expect(q.TVRageID).To(BeEquivalentTo(248682))
+	Expect(q.Season).To(BeZero())
+}
+
+func TestSearchQueryRestrictIMDB(t *testing.T) {
+	RegisterTestingT(t)
+	q := SearchQuery{ShowName: "New Girl", IMDBID: "tt1826940", Season: 4, Episode: 10}
+	r := q.restrict(&Capabilities{TVSearchAvailable: true, TVSearchParams: []string{"q", "imdbid"}})
+	Expect(r).To(Equal(SearchQuery{IMDBID: "tt1826940", Season: 4, Episode: 10}))
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	RegisterTestingT(t)
+	q := SearchQuery{ShowName: "New Girl", TVDBID: 248682}
+	Expect(q.Matches(ProviderResult{TVDBID: 248682})).To(BeTrue())
+	Expect(q.Matches(ProviderResult{})).To(BeTrue())
+	Expect(q.Matches(ProviderResult{TVRageID: 1})).To(BeTrue())
+	Expect(q.Matches(ProviderResult{TVDBID: 1})).To(BeFalse())
+
+	q = SearchQuery{ShowName: "New Girl", TVRageID: 28304}
+	Expect(q.Matches(ProviderResult{TVRageID: 28304})).To(BeTrue())
+	Expect(q.Matches(ProviderResult{TVRageID: 1})).To(BeFalse())
+}
+
+func TestRegistrySearchFallsBackToName(t *testing.T) {
+	RegisterTestingT(t)
+	caps, err := ioutil.ReadFile("testdata/newznab_caps.xml")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	feed, err := ioutil.ReadFile("testdata/nzbs_org_feed_single.rss")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	queries := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(200)
+		if r.URL.Query().Get("t") == "caps" {
+			w.Write(caps)
+			return
+		}
+		queries = append(queries, r.URL.RawQuery)
+		if r.URL.Query().Get("tvdbid") != "" {
+			w.Write([]byte(`<?xml version="1.0" encoding="utf-8" ?><rss version="2.0"><channel></channel></rss>`))
+			return
+		}
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	pr := ProviderRegistry{
+		"test": NewNewznab("test", server.URL, "API_KEY"),
+	}
+	res := pr.Search(SearchQuery{ShowName: "New Girl", TVDBID: 248682, Season: 4, Episode: 10})
+	Expect(res).To(HaveLen(1))
+	Expect(queries).To(HaveLen(2))
+	Expect(queries[1]).To(ContainSubstring("q=New+Girl"))
+
+	// The feed result is for tvdb show 248682 so it's dropped for another show.
+	queries = []string{}
+	res = pr.Search(SearchQuery{ShowName: "New Girl", TVDBID: 1, Season: 4, Episode: 10})
+	Expect(res).To(BeEmpty())
+}
+
+func TestNewznabSearchParamsAirDate(t *testing.T) {
+	RegisterTestingT(t)
+	u := url.Values{}
+	addNewznabSearchParams(u, SearchQuery{
+		ShowName: "The Daily Show",
+		AirDate:  time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC),
+	})
+	Expect(u.Encode()).To(Equal("ep=03%2F04&q=The+Daily+Show&season=2015"))
+
+	q := SearchQuery{ShowName: "The Daily Show", Season: 1, Episode: 3}
+	Expect(q.episodeTerm()).To(Equal("S01E03"))
+	q.AirDate = time.Date(2015, 3, 4, 0, 0, 0, 0, time.UTC)
+	Expect(q.episodeTerm()).To(Equal("2015.03.04"))
+}
+
+func TestSearchQueryAbsoluteNumber(t *testing.T) {
+	RegisterTestingT(t)
+	show := &db.Show{Name: "Yowamushi Pedal", Indexer: "tvdb", IndexerID: 272309, Anime: true}
+	ep := &db.Episode{Season: 2, Episode: 1, AbsoluteNumber: 39}
+
+	q := NewSearchQuery(show, ep)
+	Expect(q.AbsoluteNumber).To(BeEquivalentTo(39))
+	Expect(q.episodeTerm()).To(Equal("39"))
+
+	ep.SceneAbsoluteNumber = 40
+	q = NewSearchQuery(show, ep)
+	Expect(q.AbsoluteNumber).To(BeEquivalentTo(40))
+
+	r := q.restrict(&Capabilities{TVSearchAvailable: true, TVSearchParams: []string{"q", "tvdbid"}})
+	Expect(r.TVDBID).To(BeZero())
+	Expect(r.ShowName).To(Equal("Yowamushi Pedal"))
+
+	u := url.Values{}
+	addNewznabSearchParams(u, r)
+	Expect(u.Encode()).To(Equal("q=Yowamushi+Pedal+40"))
+
+	show.Anime = false
+	q = NewSearchQuery(show, ep)
+	Expect(q.AbsoluteNumber).To(BeZero())
+}
+
+func TestSeasonSearchQuery(t *testing.T) {
+	RegisterTestingT(t)
+	show := &db.Show{Name: "New Girl", Indexer: "tvdb", IndexerID: 248682}
+
+	q := NewSeasonSearchQuery(show, 4)
+	Expect(q.IsSeasonSearch()).To(BeTrue())
+	Expect(q.episodeTerm()).To(Equal("S04"))
+
+	u := url.Values{}
+	addNewznabSearchParams(u, q)
+	Expect(u.Encode()).To(Equal("season=4&tvdbid=248682"))
+
+	q = NewSearchQuery(show, &db.Episode{Season: 4, Episode: 10})
+	Expect(q.IsSeasonSearch()).To(BeFalse())
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProviderStatus describes how well a provider has been working.
+type ProviderStatus struct {
+	Name           string     `json:"name"`
+	Type           string     `json:"type"`
+	Healthy        bool       `json:"healthy"`
+	LastSuccess    *time.Time `json:"last_success,omitempty"`
+	LastFailure    *time.Time `json:"last_failure,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DisabledUntil  *time.Time `json:"disabled_until,omitempty"`
+	Requests       int64      `json:"requests"`
+	Failures       int64      `json:"failures"`
+	LastLatency    int64      `json:"last_latency_ms"`
+	AverageLatency int64      `json:"average_latency_ms"`
+	LastResults    int        `json:"last_results"`
+	Results        int64      `json:"results"`
+	Grabs          int64      `json:"grabs"`
+}
+
+// stats collects a provider's request outcomes for its ProviderStatus.
+type stats struct {
+	mu           sync.Mutex
+	lastSuccess  time.Time
+	lastFailure  time.Time
+	lastError    string
+	requests     int64
+	failures     int64
+	lastLatency  time.Duration
+	totalLatency time.Duration
+	lastResults  int
+	results      int64
+	grabs        int64
+}
+
+func (s *stats) request(latency time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	s.lastLatency = latency
+	s.totalLatency += latency
+	if err != nil {
+		s.failures++
+		s.lastFailure = time.Now()
+		s.lastError = err.Error()
+		return
+	}
+	s.lastSuccess = time.Now()
+}
+
+func (s *stats) found(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastResults = n
+	s.results += int64(n)
+}
+
+func (s *stats) grab() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.grabs++
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// Status returns the provider's request statistics and whether it's working.
+func (b *BaseProvider) Status() ProviderStatus {
+	b.stats.mu.Lock()
+	defer b.stats.mu.Unlock()
+	s := b.stats
+	st := ProviderStatus{
+		Name:        b.Name(),
+		LastSuccess: timeOrNil(s.lastSuccess),
+		LastFailure: timeOrNil(s.lastFailure),
+		LastError:   s.lastError,
+		Requests:    s.requests,
+		Failures:    s.failures,
+		LastLatency: int64(s.lastLatency / time.Millisecond),
+		LastResults: s.lastResults,
+		Results:     s.results,
+		Grabs:       s.grabs,
+	}
+	if s.requests > 0 {
+		st.AverageLatency = int64(s.totalLatency / time.Duration(s.requests) / time.Millisecond)
+	}
+
+	b.throttle.mu.Lock()
+	disabledUntil := b.throttle.state.DisabledUntil
+	b.throttle.mu.Unlock()
+	if time.Now().Before(disabledUntil) {
+		st.DisabledUntil = &disabledUntil
+	}
+	st.Healthy = st.DisabledUntil == nil && !s.lastFailure.After(s.lastSuccess)
+	return st
+}
+
+type byStatusName []ProviderStatus
+
+func (a byStatusName) Len() int           { return len(a) }
+func (a byStatusName) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a byStatusName) Less(i, j int) bool { return a[i].Name < a[j].Name }
+
+// Statuses returns the status of every provider sorted by name.
+func (pr ProviderRegistry) Statuses() []ProviderStatus {
+	res := make([]ProviderStatus, 0, len(pr))
+	for _, p := range pr {
+		res = append(res, providerStatus(p))
+	}
+	sort.Sort(byStatusName(res))
+	return res
+}
+
+func providerStatus(p Provider) ProviderStatus {
+	st := p.Status()
+	st.Type = p.Type().String()
+	return st
+}
+
+// Test checks that the named provider is working by getting its latest items.
+// It returns the provider's status afterwards and how many items it got.
+func (pr ProviderRegistry) Test(name string) (ProviderStatus, int, error) {
+	p, ok := pr[name]
+	if !ok {
+		return ProviderStatus{}, 0, fmt.Errorf("Unknown provider: %s", name)
+	}
+	res, err := p.GetNewItems()
+	return providerStatus(p), len(res), err
+}
@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestProviderStatus(t *testing.T) {
+	RegisterTestingT(t)
+	feed, err := ioutil.ReadFile("testdata/nzbs_org_feed_single.rss")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	fail := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail != 0 {
+			w.WriteHeader(fail)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(200)
+		w.Write(feed)
+	}))
+	defer server.Close()
+
+	pr := ProviderRegistry{
+		"b": NewNewznab("b", server.URL, "API_KEY"),
+		"a": NewTorznab("a", server.URL, "API_KEY"),
+	}
+
+	statuses := pr.Statuses()
+	Expect(statuses).To(HaveLen(2))
+	Expect(statuses[0].Name).To(Equal("a"))
+	Expect(statuses[0].Type).To(Equal("TORRENT"))
+	Expect(statuses[0].Healthy).To(BeTrue())
+	Expect(statuses[0].Requests).To(BeZero())
+
+	status, count, err := pr.Test("b")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(count).To(Equal(1))
+	Expect(status.Healthy).To(BeTrue())
+	Expect(status.Requests).To(BeEquivalentTo(1))
+	Expect(status.LastResults).To(Equal(1))
+	Expect(status.LastSuccess).ToNot(BeNil())
+
+	_, _, err = pr["b"].GetURL(server.URL)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(pr["b"].Status().Grabs).To(BeEquivalentTo(1))
+
+	// An expired NZB isn't the provider's fault.
+	fail = http.StatusNotFound
+	_, _, err = pr["b"].GetURL(server.URL)
+	Expect(err).To(HaveOccurred())
+	Expect(pr["b"].Status().Healthy).To(BeTrue())
+
+	fail = http.StatusForbidden
+	_, _, err = pr.Test("b")
+	Expect(err).To(HaveOccurred())
+	status = pr["b"].Status()
+	Expect(status.Healthy).To(BeFalse())
+	Expect(status.LastError).ToNot(BeEmpty())
+
+	fail = 0
+	feed = []byte(`<error code="100" description="Incorrect user credentials"/>`)
+	_, _, err = pr.Test("a")
+	Expect(err).To(MatchError("API error 100: Incorrect user credentials"))
+	status = pr["a"].Status()
+	Expect(status.Healthy).To(BeFalse())
+	Expect(status.LastError).To(Equal("API error 100: Incorrect user credentials"))
+
+	_, _, err = pr.Test("c")
+	Expect(err).To(MatchError("Unknown provider: c"))
+}
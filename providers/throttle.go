@@ -0,0 +1,237 @@
+package providers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/db"
+)
+
+const (
+	// DefaultTimeout is the http timeout for provider requests.
+	DefaultTimeout = 30 * time.Second
+	// How many times to retry a request which got a 429 or 5xx response.
+	maxRetries = 3
+	// How long to wait before the first retry, doubling each time.
+	initialBackoff = 5 * time.Second
+	// The longest Retry-After to wait for before retrying.  Providers asking
+	// for longer are disabled for that long instead, up to disableTime.
+	maxRetryAfter = time.Minute
+	// How many failed requests in a row before disabling a provider.
+	maxFailures = 5
+	// How long a provider is disabled for the first time, doubling every
+	// time it's disabled again without a success in between.
+	disableTime    = time.Hour
+	maxDisableTime = 24 * time.Hour
+)
+
+// RequestLimits caps how many requests are made to a provider.  Zero means
+// no limit.
+type RequestLimits struct {
+	PerMinute int
+	PerDay    int
+}
+
+// throttle tracks a provider's requests and failures to enforce its
+// RequestLimits and back off when it's failing.
+type throttle struct {
+	mu     sync.Mutex
+	recent []time.Time // requests in the last minute
+	state  db.ProviderState
+	dbh    *db.Handle
+	now    func() time.Time
+}
+
+func newThrottle() *throttle {
+	return &throttle{
+		now: time.Now,
+	}
+}
+
+// load restores the saved state for the named provider and saves all future
+// changes to the database.
+func (t *throttle) load(dbh *db.Handle, name string) error {
+	state, err := dbh.GetProviderState(name)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state = *state
+	t.dbh = dbh
+	return nil
+}
+
+func (t *throttle) save() {
+	if t.dbh == nil {
+		return
+	}
+	err := t.dbh.SaveProviderState(&t.state)
+	if err != nil {
+		glog.Errorf("Error saving state for provider %s: %s", t.state.Name, err)
+	}
+}
+
+// allow records a request if the limits allow one now.  If the per minute
+// limit has been reached it returns how long to wait before trying again.
+func (t *throttle) allow(name string, limits RequestLimits) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.now()
+	if now.Before(t.state.DisabledUntil) {
+		return 0, fmt.Errorf("Provider %s is disabled until %s", name, t.state.DisabledUntil.Format(time.RFC3339))
+	}
+	if now.Sub(t.state.DayStart) >= 24*time.Hour {
+		t.state.DayStart = now
+		t.state.DayRequests = 0
+	}
+	if limits.PerDay > 0 && t.state.DayRequests >= limits.PerDay {
+		return 0, fmt.Errorf("Provider %s has reached its limit of %d requests per day", name, limits.PerDay)
+	}
+	for len(t.recent) > 0 && now.Sub(t.recent[0]) >= time.Minute {
+		t.recent = t.recent[1:]
+	}
+	if limits.PerMinute > 0 && len(t.recent) >= limits.PerMinute {
+		return t.recent[0].Add(time.Minute).Sub(now), nil
+	}
+	t.recent = append(t.recent, now)
+	t.state.DayRequests++
+	t.state.Name = name
+	t.save()
+	return 0, nil
+}
+
+// success resets the failure count.
+func (t *throttle) success() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state.Failures == 0 && t.state.Disables == 0 {
+		return
+	}
+	t.state.Failures = 0
+	t.state.Disables = 0
+	t.save()
+}
+
+// failure records a failed request and disables the provider after too many
+// in a row.
+func (t *throttle) failure(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state.Failures++
+	if t.state.Failures >= maxFailures {
+		wait := disableTime << uint(t.state.Disables)
+		if wait > maxDisableTime || wait <= 0 {
+			wait = maxDisableTime
+		}
+		t.state.DisabledUntil = t.now().Add(wait)
+		t.state.Disables++
+		t.state.Failures = 0
+		glog.Errorf("Provider %s failed %d times in a row, disabling until %s", name, maxFailures, t.state.DisabledUntil)
+	}
+	t.save()
+}
+
+// disableFor disables the provider for the given time.
+func (t *throttle) disableFor(name string, wait time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.state.DisabledUntil = t.now().Add(wait)
+	glog.Errorf("Provider %s asked for no requests for %s, disabling until %s", name, wait, t.state.DisabledUntil)
+	t.save()
+}
+
+// SetLimits sets the number of requests the provider may make.
+func (b *BaseProvider) SetLimits(l RequestLimits) {
+	b.Limits = l
+}
+
+// LoadState restores the provider's request counts and backoff from the
+// database and saves them there from now on.
+func (b *BaseProvider) LoadState(dbh *db.Handle) error {
+	return b.throttle.load(dbh, b.Name())
+}
+
+// retryable returns true for responses that mean the provider is overloaded
+// or broken for now.
+func retryable(resp *http.Response) bool {
+	return resp.StatusCode == 429 || resp.StatusCode >= 500
+}
+
+// providerFault returns true for error responses that mean something is
+// wrong with the provider, rather than with the one request, eg a 404 for an
+// NZB that has expired.
+func providerFault(resp *http.Response) bool {
+	return resp.StatusCode == 401 || resp.StatusCode == 403 || retryable(resp)
+}
+
+// get makes a GET request to the provider, staying within its request limits
+// and retrying with exponential backoff when it's overloaded.  The body of a
+// successful response is passed to decode.  Error responses and bodies decode
+// rejects are returned as errors, and count against the provider's health if
+// they're its fault.
+func (b *BaseProvider) get(u string, decode func(io.Reader) error) (http.Header, error) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		wait, err := b.throttle.allow(b.Name(), b.Limits)
+		if err != nil {
+			return nil, err
+		}
+		if wait > 0 {
+			glog.Infof("%s: request limit reached, waiting %s", b.Name(), wait)
+			<-b.after(wait)
+			attempt--
+			continue
+		}
+
+		start := time.Now()
+		resp, err := b.Client.Get(u)
+		fault := err != nil
+		if err == nil {
+			if resp.StatusCode >= 400 {
+				err = fmt.Errorf("Error getting url '%s': %s", u, resp.Status)
+				fault = providerFault(resp)
+				if !retryable(resp) {
+					attempt = maxRetries
+				}
+				if after, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && after > 0 {
+					backoff = time.Duration(after) * time.Second
+					if backoff > maxRetryAfter {
+						if backoff > disableTime {
+							backoff = disableTime
+						}
+						b.throttle.disableFor(b.Name(), backoff)
+						attempt = maxRetries
+					}
+				}
+			} else if err = decode(resp.Body); err != nil {
+				fault = true
+				attempt = maxRetries
+			}
+			resp.Body.Close()
+		}
+		if fault {
+			b.stats.request(time.Since(start), err)
+		} else {
+			b.stats.request(time.Since(start), nil)
+			b.throttle.success()
+		}
+		if err == nil {
+			return resp.Header, nil
+		}
+		if attempt >= maxRetries {
+			if fault {
+				b.throttle.failure(b.Name())
+			}
+			return nil, err
+		}
+		glog.Warningf("%s: %s, retrying in %s", b.Name(), err, backoff)
+		<-b.after(backoff)
+		backoff *= 2
+	}
+}
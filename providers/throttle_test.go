@@ -0,0 +1,164 @@
+package providers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func noWait(time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	c <- time.Now()
+	return c
+}
+
+func ignoreBody(io.Reader) error {
+	return nil
+}
+
+func TestThrottleLimits(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Date(2015, time.March, 4, 12, 0, 0, 0, time.UTC)
+	th := newThrottle()
+	th.now = func() time.Time { return now }
+	limits := RequestLimits{PerMinute: 2, PerDay: 3}
+
+	for i := 0; i < 2; i++ {
+		wait, err := th.allow("test", limits)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(wait).To(BeZero())
+	}
+	wait, err := th.allow("test", limits)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(wait).To(Equal(time.Minute))
+
+	now = now.Add(time.Minute)
+	wait, err = th.allow("test", limits)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(wait).To(BeZero())
+
+	now = now.Add(time.Minute)
+	_, err = th.allow("test", limits)
+	Expect(err).To(MatchError("Provider test has reached its limit of 3 requests per day"))
+
+	now = now.Add(24 * time.Hour)
+	_, err = th.allow("test", limits)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+func TestThrottleDisablesAfterFailures(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Date(2015, time.March, 4, 12, 0, 0, 0, time.UTC)
+	th := newThrottle()
+	th.now = func() time.Time { return now }
+
+	for i := 0; i < maxFailures; i++ {
+		_, err := th.allow("test", RequestLimits{})
+		Expect(err).ToNot(HaveOccurred())
+		th.failure("test")
+	}
+	Expect(th.state.DisabledUntil).To(Equal(now.Add(time.Hour)))
+	_, err := th.allow("test", RequestLimits{})
+	Expect(err).To(MatchError("Provider test is disabled until 2015-03-04T13:00:00Z"))
+
+	// Disabled for twice as long the next time.
+	now = now.Add(time.Hour)
+	for i := 0; i < maxFailures; i++ {
+		th.failure("test")
+	}
+	Expect(th.state.DisabledUntil).To(Equal(now.Add(2 * time.Hour)))
+
+	th.success()
+	Expect(th.state.Disables).To(BeZero())
+	Expect(th.state.Failures).To(BeZero())
+}
+
+func TestGetRetriesWithBackoff(t *testing.T) {
+	RegisterTestingT(t)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(200)
+	}))
+	defer server.Close()
+
+	waits := []time.Duration{}
+	b := NewBaseProvider("test")
+	b.after = func(d time.Duration) <-chan time.Time {
+		waits = append(waits, d)
+		return noWait(d)
+	}
+	_, err := b.get(server.URL, ignoreBody)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(calls).To(Equal(3))
+	Expect(waits).To(Equal([]time.Duration{initialBackoff, 2 * initialBackoff}))
+
+	calls = -10
+	_, err = b.get(server.URL, ignoreBody)
+	Expect(err).To(HaveOccurred())
+	Expect(calls).To(Equal(-10 + maxRetries + 1))
+	Expect(b.throttle.state.Failures).To(Equal(1))
+}
+
+func TestGetCountsProviderFaults(t *testing.T) {
+	RegisterTestingT(t)
+	status := http.StatusNotFound
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	b := NewBaseProvider("test")
+	_, err := b.get(server.URL, ignoreBody)
+	Expect(err).To(HaveOccurred())
+	Expect(b.throttle.state.Failures).To(BeZero())
+	Expect(b.Status().Healthy).To(BeTrue())
+
+	status = http.StatusUnauthorized
+	_, err = b.get(server.URL, ignoreBody)
+	Expect(err).To(HaveOccurred())
+	Expect(b.throttle.state.Failures).To(Equal(1))
+	Expect(b.Status().Healthy).To(BeFalse())
+
+	status = http.StatusOK
+	_, err = b.get(server.URL, func(io.Reader) error { return errors.New("bad body") })
+	Expect(err).To(MatchError("bad body"))
+	Expect(b.throttle.state.Failures).To(Equal(2))
+	Expect(b.Status().Healthy).To(BeFalse())
+}
+
+func TestGetDisablesOnLongRetryAfter(t *testing.T) {
+	RegisterTestingT(t)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	now := time.Date(2015, 3, 4, 12, 0, 0, 0, time.UTC)
+	b := NewBaseProvider("test")
+	b.throttle.now = func() time.Time { return now }
+	b.after = func(d time.Duration) <-chan time.Time {
+		t.Fatalf("Shouldn't wait %s for a long Retry-After", d)
+		return nil
+	}
+	_, err := b.get(server.URL, ignoreBody)
+	Expect(err).To(HaveOccurred())
+	Expect(calls).To(Equal(1))
+	Expect(b.throttle.state.DisabledUntil).To(Equal(now.Add(disableTime)))
+
+	_, err = b.get(server.URL, ignoreBody)
+	Expect(err).To(MatchError("Provider test is disabled until 2015-03-04T13:00:00Z"))
+	Expect(calls).To(Equal(1))
+}
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/torrent"
+)
+
+// How far a torrent's total size can be from the size the provider listed.
+const sizeTolerance = 0.1
+
+// GetURL downloads a .torrent file, making sure it really is one.  Magnet
+// links are returned as the contents of a .magnet file, which many torrent
+// clients can load from a watch directory.
+func (t *TorrentProvider) GetURL(u string) (string, []byte, error) {
+	if torrent.IsMagnet(u) {
+		m, err := torrent.ParseMagnet(u)
+		if err != nil {
+			return "", nil, err
+		}
+		name := m.Name
+		if name == "" {
+			name = m.InfoHash
+		}
+		return naming.CleanSeriesName(name) + ".magnet", []byte(u), nil
+	}
+
+	filename, content, err := t.BaseProvider.GetURL(u)
+	if err != nil {
+		return filename, content, err
+	}
+	m, err := torrent.Parse(content)
+	if err != nil {
+		return filename, content, fmt.Errorf("Downloaded file from %s isn't a valid torrent: %s", u, err)
+	}
+	if filename == "" {
+		filename = naming.CleanSeriesName(m.Name) + ".torrent"
+	}
+	return filename, content, nil
+}
+
+// CheckTorrent checks that downloaded torrent or magnet content matches what
+// the provider said the result was: the same info hash, about the same size
+// and containing something to import.
+func CheckTorrent(r ProviderResult, content []byte) error {
+	if torrent.IsMagnet(string(content)) {
+		m, err := torrent.ParseMagnet(string(content))
+		if err != nil {
+			return err
+		}
+		if r.InfoHash != "" && m.InfoHash != strings.ToLower(r.InfoHash) {
+			return fmt.Errorf("Magnet link info hash %s doesn't match %s for %s", m.InfoHash, r.InfoHash, r.Name)
+		}
+		return nil
+	}
+
+	m, err := torrent.Parse(content)
+	if err != nil {
+		return err
+	}
+	if r.InfoHash != "" && m.InfoHash != strings.ToLower(r.InfoHash) {
+		return fmt.Errorf("Torrent info hash %s doesn't match %s for %s", m.InfoHash, r.InfoHash, r.Name)
+	}
+	if r.Size > 0 {
+		diff := float64(m.Length-r.Size) / float64(r.Size)
+		if diff > sizeTolerance || diff < -sizeTolerance {
+			return fmt.Errorf("Torrent size %d is too different from listed size %d for %s", m.Length, r.Size, r.Name)
+		}
+	}
+	for _, f := range m.Files {
+		if naming.IsMediaFile(f.Path) || strings.ToLower(filepath.Ext(f.Path)) == ".rar" {
+			return nil
+		}
+	}
+	glog.Infof("Torrent files for %s: %+v", r.Name, m.Files)
+	return fmt.Errorf("Torrent for %s doesn't contain any media files", r.Name)
+}
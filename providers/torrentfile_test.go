@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// A single file torrent containing a 1000 byte mkv.
+const testTorrent = "d8:announce23:http://tracker/announce4:infod6:lengthi1000e4:name20:Show.S01E01.720p.mkv12:piece lengthi16384e6:pieces0:ee"
+
+const testMagnet = "magnet:?xt=urn:btih:c12fe1c06bba254a9dc9f519b335aa7c1367a88a&dn=Show.S01E01.720p"
+
+func TestTorrentGetURLMagnet(t *testing.T) {
+	RegisterTestingT(t)
+	n := NewNyaaTorrents()
+	fname, cont, err := n.GetURL(testMagnet)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(fname).To(Equal("Show.S01E01.720p.magnet"))
+	Expect(string(cont)).To(Equal(testMagnet))
+}
+
+func TestTorrentGetURLRejectsHTML(t *testing.T) {
+	RegisterTestingT(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(200)
+		w.Write([]byte("<html><body>Too many downloads</body></html>"))
+	}))
+	defer server.Close()
+
+	n := NewNyaaTorrents()
+	_, _, err := n.GetURL(server.URL)
+	Expect(err).To(HaveOccurred())
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+		w.Write([]byte(testTorrent))
+	}))
+	defer server.Close()
+	fname, _, err := n.GetURL(server.URL)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(fname).To(Equal("Show.S01E01.720p.mkv.torrent"))
+}
+
+func TestCheckTorrent(t *testing.T) {
+	RegisterTestingT(t)
+	r := ProviderResult{Name: "Show.S01E01.720p", Size: 1000}
+	Expect(CheckTorrent(r, []byte(testTorrent))).ToNot(HaveOccurred())
+
+	r.Size = 2000
+	Expect(CheckTorrent(r, []byte(testTorrent))).To(HaveOccurred())
+
+	r.Size = 0
+	r.InfoHash = "c12fe1c06bba254a9dc9f519b335aa7c1367a88a"
+	Expect(CheckTorrent(r, []byte(testTorrent))).To(HaveOccurred())
+	Expect(CheckTorrent(r, []byte(testMagnet))).ToNot(HaveOccurred())
+
+	r.InfoHash = "0000000000000000000000000000000000000000"
+	Expect(CheckTorrent(r, []byte(testMagnet))).To(HaveOccurred())
+
+	r.InfoHash = ""
+	noMedia := "d4:infod6:lengthi1000e4:name8:info.nfo12:piece lengthi16384e6:pieces0:ee"
+	Expect(CheckTorrent(r, []byte(noMedia))).To(MatchError("Torrent for Show.S01E01.720p doesn't contain any media files"))
+}
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"net/url"
+	"strings"
+)
+
+// DefaultTorznabCategories are the standard TV categories used when a Torznab
+// provider doesn't configure its own.
+var DefaultTorznabCategories = []string{"5000", "5030", "5040"}
+
+// Torznab provides a client for torrent indexers (or indexer proxies)
+// implementing the Torznab API, a Newznab API variant for torrents.
+type Torznab struct {
+	URL        string
+	APIKEY     string
+	Categories []string
+	caps       capsCache
+	TorrentProvider
+}
+
+// NewTorznab creates a new Torznab client with the given name talking to the
+// API at apiURL.
+func NewTorznab(name, apiURL, key string, options ...func(*Torznab)) *Torznab {
+	t := &Torznab{
+		URL:        apiURL,
+		APIKEY:     key,
+		Categories: DefaultTorznabCategories,
+		TorrentProvider: TorrentProvider{
+			BaseProvider: NewBaseProvider(name),
+		},
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
+
+// GetNewItems returns the latest items in the configured categories.
+func (t *Torznab) GetNewItems() ([]ProviderResult, error) {
+	u := url.Values{}
+	u.Add("apikey", t.APIKEY)
+	u.Add("t", "tvsearch")
+	u.Add("cat", strings.Join(t.Categories, ","))
+	return t.getRss(u)
+}
+
+// TvSearch searches for a given tv show with optional episode and season
+// constraints.
+func (t *Torznab) TvSearch(q SearchQuery) ([]ProviderResult, error) {
+	u := url.Values{}
+	u.Add("apikey", t.APIKEY)
+	u.Add("t", "tvsearch")
+	u.Add("cat", strings.Join(t.Categories, ","))
+	addNewznabSearchParams(u, q)
+	return t.getRss(u)
+}
+
+// Capabilities returns the indexer's capabilities from its t=caps call.  They
+// are cached for a day.
+func (t *Torznab) Capabilities() (*Capabilities, error) {
+	return t.caps.get(func() (*Capabilities, error) {
+		return getNewznabCaps(t.BaseProvider, t.URL, t.APIKEY)
+	})
+}
+
+func (t *Torznab) getRss(u url.Values) ([]ProviderResult, error) {
+	r, err := getNewznabFeed(t.BaseProvider, t.URL, u)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ProviderResult, len(r.Items))
+	for i, item := range r.Items {
+		results[i] = newznabResult(item)
+		results[i].Type = t.Type()
+		results[i].ProviderName = t.Name()
+	}
+	t.stats.found(len(results))
+	return results, nil
+}
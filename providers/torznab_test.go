@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTorznabTvSearch(t *testing.T) {
+	RegisterTestingT(t)
+	body, err := ioutil.ReadFile("testdata/torznab_tvsearch.xml")
+	if err != nil {
+		t.Fatalf("Error reading test file %s", err)
+	}
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.WriteHeader(200)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	n := NewTorznab("torznab", server.URL+"/api", "API_KEY")
+	res, err := n.TvSearch(SearchQuery{ShowName: "New Girl", Season: 4, Episode: 10})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(query).To(Equal("apikey=API_KEY&cat=5000%2C5030%2C5040&ep=10&q=New+Girl&season=4&t=tvsearch"))
+	Expect(res).To(HaveLen(2))
+
+	Expect(res[0].Type).To(Equal(TORRENT))
+	Expect(res[0].ProviderName).To(Equal("torznab"))
+	Expect(res[0].Size).To(Equal(int64(835433768)))
+	Expect(res[0].Seeders).To(Equal(int64(112)))
+	Expect(res[0].Peers).To(Equal(int64(130)))
+	Expect(res[0].TVRageID).To(Equal(int64(28304)))
+	Expect(res[0].InfoHash).To(Equal("9d7a4ca3d1a4a9e1c4b1c2f1af4c2bc7d8e9f0a1"))
+	Expect(res[0].URL).To(HavePrefix("http://127.0.0.1:9117/dl/tracker/"))
+	Expect(res[0].MagnetURL).To(HavePrefix("magnet:?xt=urn:btih:9d7a4ca3"))
+
+	// Magnet only results use the magnet link to download
+	Expect(res[1].Size).To(Equal(int64(243269632)))
+	Expect(res[1].Seeders).To(Equal(int64(57)))
+	Expect(res[1].URL).To(Equal(res[1].MagnetURL))
+}
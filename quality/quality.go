@@ -52,6 +52,17 @@ func (q Quality) MarshalJSON() ([]byte, error) {
 	return json.Marshal(q.String())
 }
 
+// UnmarshalJSON reads a Quality from its name.
+func (q *Quality) UnmarshalJSON(b []byte) error {
+	var name string
+	err := json.Unmarshal(b, &name)
+	if err != nil {
+		return err
+	}
+	*q, err = QualityFromString(name)
+	return err
+}
+
 // Scan implements the sql.Scanner interface
 func (q *Quality) Scan(src interface{}) error {
 	switch s := src.(type) {
@@ -8,11 +8,15 @@ import (
 )
 
 // QualityGroup represents a group of acceptable qualities for a Show.
+// Qualities are listed from least to most preferred.  Episodes downloaded
+// below the Cutoff quality are upgraded when a better release comes along; a
+// group without a Cutoff never upgrades.
 type QualityGroup struct {
 	ID            int64     `json:"-"`
 	Name          string    `json:"name"`
 	Qualities     []Quality `sql:"-" json:"qualities"`
 	QualityString string    `json:"-"` // CSV of ints
+	Cutoff        Quality   `json:"cutoff"`
 	Default       bool      `json:"default"`
 }
 
@@ -26,6 +30,32 @@ func (qg QualityGroup) Includes(qual Quality) bool {
 	return false
 }
 
+// Rank returns the position of the given Quality in the group's order of
+// preference, higher being better, or -1 if the group doesn't include it.
+func (qg QualityGroup) Rank(qual Quality) int {
+	for i, q := range qg.Qualities {
+		if q == qual {
+			return i
+		}
+	}
+	return -1
+}
+
+// CutoffMet returns true if an episode downloaded at the given Quality
+// doesn't need upgrading.  Episodes of unknown quality are left alone.
+func (qg QualityGroup) CutoffMet(qual Quality) bool {
+	if qg.Cutoff == UNKNOWN || qual == UNKNOWN || !qg.Includes(qg.Cutoff) {
+		return true
+	}
+	return qg.Rank(qual) >= qg.Rank(qg.Cutoff)
+}
+
+// IsUpgrade returns true if an episode downloaded at the current Quality
+// should be replaced with one at the given Quality.
+func (qg QualityGroup) IsUpgrade(current, qual Quality) bool {
+	return !qg.CutoffMet(current) && qg.Rank(qual) > qg.Rank(current)
+}
+
 // Pretty hacky, but seems to work.  Qualities are constants and not DB
 // objects, so we need glue to keep them together.  Groups of Qualities can be
 // user defined and so fit that role.
@@ -1,6 +1,7 @@
 package quality
 
 import (
+	"encoding/json"
 	"testing"
 
 	. "github.com/onsi/gomega"
@@ -17,3 +18,38 @@ func TestQualityFromName(t *testing.T) {
 	Expect(QualityFromName("12 Monkeys - S01E05 - The Night Room - HD TV", false)).To(Equal(HDTV))
 	Expect(QualityFromName("The.Return.of.Superman.E68.150308.HDTV.H264.720p-WITH", false)).To(Equal(HDTV))
 }
+
+func TestQualityGroupCutoff(t *testing.T) {
+	RegisterTestingT(t)
+	qg := QualityGroup{
+		Qualities: []Quality{HDTV, HDWEBDL, FULLHDWEBDL, HDBLURAY},
+		Cutoff:    FULLHDWEBDL,
+	}
+	Expect(qg.Rank(HDTV)).To(Equal(0))
+	Expect(qg.Rank(HDBLURAY)).To(Equal(3))
+	Expect(qg.Rank(SDTV)).To(Equal(-1))
+
+	Expect(qg.CutoffMet(HDTV)).To(BeFalse())
+	Expect(qg.CutoffMet(SDTV)).To(BeFalse())
+	Expect(qg.CutoffMet(FULLHDWEBDL)).To(BeTrue())
+	Expect(qg.CutoffMet(HDBLURAY)).To(BeTrue())
+	Expect(qg.CutoffMet(UNKNOWN)).To(BeTrue())
+
+	Expect(qg.IsUpgrade(HDTV, HDWEBDL)).To(BeTrue())
+	Expect(qg.IsUpgrade(HDTV, HDBLURAY)).To(BeTrue())
+	Expect(qg.IsUpgrade(HDWEBDL, HDTV)).To(BeFalse())
+	Expect(qg.IsUpgrade(HDWEBDL, SDTV)).To(BeFalse())
+	Expect(qg.IsUpgrade(FULLHDWEBDL, HDBLURAY)).To(BeFalse())
+
+	qg.Cutoff = UNKNOWN
+	Expect(qg.CutoffMet(HDTV)).To(BeTrue())
+	Expect(qg.IsUpgrade(HDTV, HDBLURAY)).To(BeFalse())
+}
+
+func TestQualityJSON(t *testing.T) {
+	RegisterTestingT(t)
+	var q Quality
+	Expect(json.Unmarshal([]byte(`"720p WEB-DL"`), &q)).To(BeNil())
+	Expect(q).To(Equal(HDWEBDL))
+	Expect(json.Unmarshal([]byte(`"720p Betamax"`), &q)).ToNot(BeNil())
+}
@@ -51,6 +51,18 @@ func dirInDirs(dir string, dirs []string) bool {
 	return false
 }
 
+// inRootDirs returns true if path is inside, and not the same as, one of the
+// Broker's RootDirs.
+func (b *Broker) inRootDirs(path string) bool {
+	for _, d := range b.RootDirs {
+		rel, err := filepath.Rel(d, path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
 //CreateDir creates the given directory if it is under one of the Broker's RootDirs.
 func (b *Broker) CreateDir(showdir string) (string, error) {
 	showdir = filepath.Clean(showdir)
@@ -191,6 +203,22 @@ func (b *Broker) MoveFile(src, dst string) error {
 	return nil
 }
 
+// RemoveFile deletes the file at path, which must be an absolute path under
+// one of the Broker's RootDirs.  It's not an error if it doesn't exist.
+func (b *Broker) RemoveFile(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("Non absolute path given: %s", path)
+	}
+	if !b.inRootDirs(path) {
+		return fmt.Errorf("Refusing to remove '%s', it's not under any known root directory: %v", path, b.RootDirs)
+	}
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
 // SaveToFile saves the given content to the filename in the directory.  If
 // fname is empty it will save to a temporary file.
 func (b *Broker) SaveToFile(dirname, fname string, content []byte) (string, error) {
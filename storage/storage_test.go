@@ -176,3 +176,41 @@ func TestMoveAcrossFilesystem(t *testing.T) {
 	err = b.FileReadable(testfilepathdest)
 	Expect(err).ToNot(HaveOccurred())
 }
+
+func TestRemoveFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	testdir, err := ioutil.TempDir("testdata", "testing")
+	if err != nil {
+		t.Fatalf("Couldn't create a tempdir for testing: %s", err)
+	}
+	testdir, err = filepath.Abs(testdir)
+	if err != nil {
+		t.Fatalf("Couldn't make an absoulte path of testdir: %s", err)
+	}
+	defer os.RemoveAll(testdir)
+
+	b, err := NewBroker(filepath.Join(testdir, "root"))
+	Expect(err).ToNot(HaveOccurred())
+	_, err = b.CreateDir(b.RootDirs[0])
+	Expect(err).ToNot(HaveOccurred())
+
+	inside, err := b.SaveToFile(b.RootDirs[0], "inside", []byte("test\n"))
+	Expect(err).ToNot(HaveOccurred())
+	outside, err := b.SaveToFile(testdir, "outside", []byte("test\n"))
+	Expect(err).ToNot(HaveOccurred())
+	sibling, err := b.SaveToFile(testdir, "rootsibling", []byte("test\n"))
+	Expect(err).ToNot(HaveOccurred())
+
+	Expect(b.RemoveFile("inside")).To(HaveOccurred())
+	Expect(b.RemoveFile(outside)).To(HaveOccurred())
+	// Not fooled by a directory whose name starts with the root's.
+	Expect(b.RemoveFile(sibling)).To(HaveOccurred())
+	Expect(b.RemoveFile(filepath.Join(b.RootDirs[0], "..", "outside"))).To(HaveOccurred())
+	Expect(b.FileReadable(outside)).ToNot(HaveOccurred())
+
+	Expect(b.RemoveFile(inside)).ToNot(HaveOccurred())
+	Expect(b.FileReadable(inside)).To(HaveOccurred())
+	// Already gone is fine.
+	Expect(b.RemoveFile(inside)).ToNot(HaveOccurred())
+}
@@ -0,0 +1,60 @@
+package test_helpers
+
+import (
+	"fmt"
+
+	"github.com/hobeone/tv2go/downloaders"
+)
+
+// FakeDownloadClient is a download client with a fixed queue and history
+// which records the downloads added to and removed from it.
+type FakeDownloadClient struct {
+	ClientName   string // "fake" if not set
+	QueueItems   []downloaders.Item
+	HistoryItems []downloaders.Item
+	Err          error // returned by Queue and History if set
+	Added        []downloaders.Download
+	Removed      []string
+}
+
+// Name returns the client's name.
+func (c *FakeDownloadClient) Name() string {
+	if c.ClientName == "" {
+		return "fake"
+	}
+	return c.ClientName
+}
+
+// Add records the download and returns an id for it.
+func (c *FakeDownloadClient) Add(d downloaders.Download) (string, error) {
+	c.Added = append(c.Added, d)
+	return fmt.Sprintf("fake_%d", len(c.Added)), nil
+}
+
+// Status returns the item with the given id from the queue or history.
+func (c *FakeDownloadClient) Status(id string) (*downloaders.Item, error) {
+	for _, items := range [][]downloaders.Item{c.QueueItems, c.HistoryItems} {
+		for i := range items {
+			if items[i].ID == id {
+				return &items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s doesn't know about download %s", c.Name(), id)
+}
+
+// Queue returns the queue items.
+func (c *FakeDownloadClient) Queue() ([]downloaders.Item, error) {
+	return c.QueueItems, c.Err
+}
+
+// History returns the history items.
+func (c *FakeDownloadClient) History() ([]downloaders.Item, error) {
+	return c.HistoryItems, c.Err
+}
+
+// Remove records that the download was removed.
+func (c *FakeDownloadClient) Remove(id string, deleteData bool) error {
+	c.Removed = append(c.Removed, id)
+	return nil
+}
@@ -0,0 +1,128 @@
+package torrent
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// maxDepth is how deeply lists and dictionaries can be nested, so hostile
+// data can't overflow the stack.
+const maxDepth = 64
+
+// decoder decodes bencoded data into int64, string, []interface{} and
+// map[string]interface{} values.
+type decoder struct {
+	data []byte
+	pos  int
+	// byte offsets of the value of the top level "info" key, used to
+	// calculate the info hash.
+	infoStart, infoEnd int
+}
+
+// Decode decodes a single bencoded value.
+func Decode(data []byte) (interface{}, error) {
+	d := &decoder{data: data}
+	return d.decodeAll()
+}
+
+func (d *decoder) decodeAll() (interface{}, error) {
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("Trailing data at offset %d", d.pos)
+	}
+	return v, nil
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if d.pos >= len(d.data) {
+		return nil, fmt.Errorf("Unexpected end of data")
+	}
+	if depth > maxDepth {
+		return nil, fmt.Errorf("Nested more than %d deep at offset %d", maxDepth, d.pos)
+	}
+	switch c := d.data[d.pos]; {
+	case c == 'i':
+		d.pos++
+		return d.readInt('e')
+	case c >= '0' && c <= '9':
+		return d.readString()
+	case c == 'l':
+		d.pos++
+		list := []interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, fmt.Errorf("Unterminated list")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return list, nil
+			}
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+	case c == 'd':
+		d.pos++
+		dict := map[string]interface{}{}
+		for {
+			if d.pos >= len(d.data) {
+				return nil, fmt.Errorf("Unterminated dictionary")
+			}
+			if d.data[d.pos] == 'e' {
+				d.pos++
+				return dict, nil
+			}
+			key, err := d.readString()
+			if err != nil {
+				return nil, fmt.Errorf("Invalid dictionary key: %s", err)
+			}
+			start := d.pos
+			v, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if depth == 0 && key == "info" {
+				d.infoStart, d.infoEnd = start, d.pos
+			}
+			dict[key] = v
+		}
+	default:
+		return nil, fmt.Errorf("Invalid bencode type '%c' at offset %d", c, d.pos)
+	}
+}
+
+// readInt reads an integer up to the given terminator.
+func (d *decoder) readInt(term byte) (int64, error) {
+	start := d.pos
+	for d.pos < len(d.data) && d.data[d.pos] != term {
+		d.pos++
+	}
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("Unterminated integer at offset %d", start)
+	}
+	i, err := strconv.ParseInt(string(d.data[start:d.pos]), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid integer at offset %d: %s", start, err)
+	}
+	d.pos++
+	return i, nil
+}
+
+func (d *decoder) readString() (string, error) {
+	start := d.pos
+	l, err := d.readInt(':')
+	if err != nil {
+		return "", err
+	}
+	if l < 0 || int64(len(d.data)-d.pos) < l {
+		return "", fmt.Errorf("Invalid string length %d at offset %d", l, start)
+	}
+	s := string(d.data[d.pos : d.pos+int(l)])
+	d.pos += int(l)
+	return s, nil
+}
@@ -0,0 +1,131 @@
+// Package torrent reads the metadata in .torrent files and magnet links.
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// File is a single file in a torrent.
+type File struct {
+	Path   string
+	Length int64
+}
+
+// MetaInfo is the metadata from a .torrent file.
+type MetaInfo struct {
+	Name     string
+	InfoHash string // lowercase hex
+	Announce string
+	Length   int64 // total size of all the files
+	Files    []File
+}
+
+// Parse decodes the contents of a .torrent file.
+func Parse(data []byte) (*MetaInfo, error) {
+	if len(data) == 0 || data[0] != 'd' {
+		return nil, fmt.Errorf("Not a torrent file")
+	}
+	d := &decoder{data: data}
+	v, err := d.decodeAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding torrent: %s", err)
+	}
+	dict := v.(map[string]interface{})
+	info, ok := dict["info"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Torrent has no info dictionary")
+	}
+
+	sum := sha1.Sum(data[d.infoStart:d.infoEnd])
+	m := &MetaInfo{
+		InfoHash: hex.EncodeToString(sum[:]),
+	}
+	m.Announce, _ = dict["announce"].(string)
+	m.Name, _ = info["name"].(string)
+
+	if length, ok := info["length"].(int64); ok {
+		m.Files = []File{{Path: m.Name, Length: length}}
+		m.Length = length
+		return m, nil
+	}
+	files, ok := info["files"].([]interface{})
+	if !ok || len(files) == 0 {
+		return nil, fmt.Errorf("Torrent has no files")
+	}
+	for _, f := range files {
+		fd, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Invalid file entry in torrent")
+		}
+		length, _ := fd["length"].(int64)
+		parts := []string{m.Name}
+		pathList, _ := fd["path"].([]interface{})
+		for _, p := range pathList {
+			if ps, ok := p.(string); ok {
+				parts = append(parts, ps)
+			}
+		}
+		m.Files = append(m.Files, File{Path: path.Join(parts...), Length: length})
+		m.Length += length
+	}
+	return m, nil
+}
+
+// Magnet is the information in a magnet link.
+type Magnet struct {
+	InfoHash string // lowercase hex
+	Name     string
+	Trackers []string
+}
+
+// IsMagnet returns true if the url is a magnet link.
+func IsMagnet(u string) bool {
+	return strings.HasPrefix(strings.ToLower(u), "magnet:")
+}
+
+// ParseMagnet extracts the info hash, name and trackers from a magnet link.
+func ParseMagnet(uri string) (*Magnet, error) {
+	if !IsMagnet(uri) {
+		return nil, fmt.Errorf("Not a magnet link: %s", uri)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid magnet link %s: %s", uri, err)
+	}
+	q := u.Query()
+	m := &Magnet{
+		Name:     q.Get("dn"),
+		Trackers: q["tr"],
+	}
+	for _, xt := range q["xt"] {
+		if !strings.HasPrefix(strings.ToLower(xt), "urn:btih:") {
+			continue
+		}
+		hash := xt[len("urn:btih:"):]
+		switch len(hash) {
+		case 40:
+			if _, err := hex.DecodeString(hash); err != nil {
+				return nil, fmt.Errorf("Invalid info hash %s: %s", hash, err)
+			}
+			m.InfoHash = strings.ToLower(hash)
+		case 32:
+			b, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+			if err != nil {
+				return nil, fmt.Errorf("Invalid info hash %s: %s", hash, err)
+			}
+			m.InfoHash = hex.EncodeToString(b)
+		default:
+			return nil, fmt.Errorf("Invalid info hash %s", hash)
+		}
+	}
+	if m.InfoHash == "" {
+		return nil, fmt.Errorf("Magnet link has no info hash: %s", uri)
+	}
+	return m, nil
+}
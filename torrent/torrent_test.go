@@ -0,0 +1,95 @@
+package torrent
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const singleInfo = "d6:lengthi1000e4:name20:Show.S01E01.720p.mkv12:piece lengthi16384e6:pieces0:e"
+const singleFile = "d8:announce23:http://tracker/announce4:info" + singleInfo + "e"
+
+const multiFile = "d4:infod5:filesl" +
+	"d6:lengthi900e4:pathl15:Show.S01E01.mkvee" +
+	"d6:lengthi100e4:pathl7:Samples10:sample.mkveee" +
+	"4:name17:Show.S01.720p-GRP12:piece lengthi16384e6:pieces0:ee"
+
+func TestDecode(t *testing.T) {
+	RegisterTestingT(t)
+	v, err := Decode([]byte("d3:fooi42e3:barl4:spami-3eee"))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(v).To(Equal(map[string]interface{}{
+		"foo": int64(42),
+		"bar": []interface{}{"spam", int64(-3)},
+	}))
+
+	for _, bad := range []string{"", "i42", "4:spa", "d3:foo", "x", "i4e5"} {
+		_, err = Decode([]byte(bad))
+		Expect(err).To(HaveOccurred(), "Expected error decoding %q", bad)
+	}
+}
+
+func TestDecodeTooDeep(t *testing.T) {
+	RegisterTestingT(t)
+	_, err := Decode([]byte(strings.Repeat("l", maxDepth) + strings.Repeat("e", maxDepth)))
+	Expect(err).ToNot(HaveOccurred())
+
+	_, err = Decode([]byte(strings.Repeat("l", 1000000)))
+	Expect(err).To(HaveOccurred())
+	Expect(err.Error()).To(ContainSubstring("Nested more than"))
+}
+
+func TestParseSingleFile(t *testing.T) {
+	RegisterTestingT(t)
+	m, err := Parse([]byte(singleFile))
+	Expect(err).ToNot(HaveOccurred())
+
+	sum := sha1.Sum([]byte(singleInfo))
+	Expect(m.InfoHash).To(Equal(hex.EncodeToString(sum[:])))
+	Expect(m.Name).To(Equal("Show.S01E01.720p.mkv"))
+	Expect(m.Announce).To(Equal("http://tracker/announce"))
+	Expect(m.Length).To(BeEquivalentTo(1000))
+	Expect(m.Files).To(Equal([]File{{Path: "Show.S01E01.720p.mkv", Length: 1000}}))
+}
+
+func TestParseMultiFile(t *testing.T) {
+	RegisterTestingT(t)
+	m, err := Parse([]byte(multiFile))
+	Expect(err).ToNot(HaveOccurred())
+	Expect(m.Length).To(BeEquivalentTo(1000))
+	Expect(m.Files).To(Equal([]File{
+		{Path: "Show.S01.720p-GRP/Show.S01E01.mkv", Length: 900},
+		{Path: "Show.S01.720p-GRP/Samples/sample.mkv", Length: 100},
+	}))
+}
+
+func TestParseRejectsHTML(t *testing.T) {
+	RegisterTestingT(t)
+	_, err := Parse([]byte("<html><body>API limit reached</body></html>"))
+	Expect(err).To(MatchError("Not a torrent file"))
+
+	_, err = Parse([]byte("d8:announce3:fooe"))
+	Expect(err).To(MatchError("Torrent has no info dictionary"))
+}
+
+func TestParseMagnet(t *testing.T) {
+	RegisterTestingT(t)
+	m, err := ParseMagnet("magnet:?xt=urn:btih:C12FE1C06BBA254A9DC9F519B335AA7C1367A88A&dn=Show.S01E01.720p&tr=udp%3A%2F%2Ftracker%3A80")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(m.InfoHash).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+	Expect(m.Name).To(Equal("Show.S01E01.720p"))
+	Expect(m.Trackers).To(Equal([]string{"udp://tracker:80"}))
+
+	m, err = ParseMagnet("magnet:?xt=urn:btih:YEX6DQDLXISUVHOJ6UM3GNNKPQJWPKEK")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(m.InfoHash).To(Equal("c12fe1c06bba254a9dc9f519b335aa7c1367a88a"))
+
+	_, err = ParseMagnet("magnet:?dn=foo")
+	Expect(err).To(HaveOccurred())
+	_, err = ParseMagnet("http://example.com/foo.torrent")
+	Expect(err).To(HaveOccurred())
+	Expect(IsMagnet("MAGNET:?xt=urn:btih:foo")).To(BeTrue())
+}
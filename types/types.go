@@ -55,3 +55,29 @@ var EpisodeDefaults = []EpisodeStatus{
 	SKIPPED,
 	IGNORED,
 }
+
+// IsSnatched returns true if the episode has been sent to a download client
+// and is waiting to be imported.
+func (status EpisodeStatus) IsSnatched() bool {
+	return status == SNATCHED || status == SNATCHED_PROPER || status == SNATCHED_BEST
+}
+
+// Snatched returns the status an episode with this status gets when a release
+// is grabbed for it: SNATCHED_BEST if it's replacing a downloaded file,
+// otherwise SNATCHED.
+func (status EpisodeStatus) Snatched() EpisodeStatus {
+	if status == DOWNLOADED {
+		return SNATCHED_BEST
+	}
+	return SNATCHED
+}
+
+// Unsnatched returns the status a snatched episode goes back to when its
+// download is abandoned: DOWNLOADED if it was replacing a file, otherwise
+// WANTED.
+func (status EpisodeStatus) Unsnatched() EpisodeStatus {
+	if status == SNATCHED_PROPER || status == SNATCHED_BEST {
+		return DOWNLOADED
+	}
+	return WANTED
+}
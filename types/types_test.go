@@ -11,3 +11,13 @@ func TestStatusEnum(t *testing.T) {
 	d := EpisodeStatus(1)
 	Expect(d).To(Equal(UNAIRED))
 }
+
+func TestSnatchedStatuses(t *testing.T) {
+	RegisterTestingT(t)
+	Expect(WANTED.Snatched()).To(Equal(SNATCHED))
+	Expect(DOWNLOADED.Snatched()).To(Equal(SNATCHED_BEST))
+	Expect(SNATCHED_BEST.IsSnatched()).To(BeTrue())
+	Expect(DOWNLOADED.IsSnatched()).To(BeFalse())
+	Expect(SNATCHED.Unsnatched()).To(Equal(WANTED))
+	Expect(SNATCHED_BEST.Unsnatched()).To(Equal(DOWNLOADED))
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/db"
+)
+
+// ShowBacklog starts a backlog search for the show's wanted episodes.
+func (server *Server) ShowBacklog(c *gin.Context) {
+	showid, err := strconv.ParseInt(c.Params.ByName("showid"), 10, 64)
+	if err != nil {
+		genError(c, http.StatusNotFound, fmt.Sprintf("Invalid showid: %v", c.Params.ByName("showid")))
+		return
+	}
+	dbshow, err := server.dbHandle.GetShowByID(showid)
+	if err != nil {
+		genError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	server.searchBacklog(c, dbshow, -1)
+}
+
+// SeasonBacklog starts a backlog search for the wanted episodes of one of the
+// show's seasons.
+func (server *Server) SeasonBacklog(c *gin.Context) {
+	dbshow, season, ok := server.getShowAndSeason(c)
+	if !ok {
+		return
+	}
+	server.searchBacklog(c, dbshow, season)
+}
+
+// searchBacklog searches for the wanted episodes of the show's season, or all
+// seasons if season is -1, in the background.  They're searched for even if
+// the show is paused or they were searched for recently.
+func (server *Server) searchBacklog(c *gin.Context, dbshow *db.Show, season int64) {
+	if server.backlog == nil {
+		genError(c, http.StatusServiceUnavailable, "Backlog searching isn't set up")
+		return
+	}
+	eps, err := server.backlog.WantedEpisodes(dbshow, season)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting episodes: %s", err))
+		return
+	}
+	desc := dbshow.Name
+	if season > -1 {
+		desc = fmt.Sprintf("%s season %d", dbshow.Name, season)
+	}
+	if len(eps) == 0 {
+		c.JSON(200, genericResult{
+			Message: fmt.Sprintf("No wanted episodes of %s", desc),
+			Result:  "success",
+		})
+		return
+	}
+	go server.backlog.Search(eps)
+	c.JSON(200, genericResult{
+		Message: fmt.Sprintf("Searching for %d wanted episodes of %s", len(eps), desc),
+		Result:  "success",
+	})
+}
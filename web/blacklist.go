@@ -0,0 +1,81 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/db"
+)
+
+type blacklistResponse struct {
+	ID        int64     `json:"id"`
+	ShowID    int64     `json:"showid"`
+	EpisodeID int64     `json:"episodeid"`
+	Name      string    `json:"name"`
+	Provider  string    `json:"provider"`
+	URL       string    `json:"url"`
+	Reason    string    `json:"reason"`
+	Added     time.Time `json:"added"`
+}
+
+func blacklistToResponse(b db.Blacklist) blacklistResponse {
+	return blacklistResponse{
+		ID:        b.ID,
+		ShowID:    b.ShowID,
+		EpisodeID: b.EpisodeID,
+		Name:      b.Name,
+		Provider:  b.Provider,
+		URL:       b.URL,
+		Reason:    b.Reason,
+		Added:     b.Added,
+	}
+}
+
+// Blacklist serves the releases that won't be grabbed again because they
+// failed to download.
+func (server *Server) Blacklist(c *gin.Context) {
+	blacklist, err := server.dbHandle.GetBlacklist()
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting blacklist: %s", err))
+		return
+	}
+	resp := make([]blacklistResponse, len(blacklist))
+	for i, b := range blacklist {
+		resp[i] = blacklistToResponse(b)
+	}
+	c.JSON(200, resp)
+}
+
+// DeleteBlacklist removes one release from the blacklist.
+func (server *Server) DeleteBlacklist(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Params.ByName("id"), 10, 64)
+	if err != nil {
+		genError(c, http.StatusBadRequest, "Invalid blacklist id")
+		return
+	}
+	err = server.dbHandle.DeleteBlacklist(id)
+	if err != nil {
+		genError(c, http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(200, genericResult{
+		Message: fmt.Sprintf("Removed blacklist entry %d", id),
+		Result:  "success",
+	})
+}
+
+// ClearBlacklist removes every release from the blacklist.
+func (server *Server) ClearBlacklist(c *gin.Context) {
+	err := server.dbHandle.ClearBlacklist()
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error clearing blacklist: %s", err))
+		return
+	}
+	c.JSON(200, genericResult{
+		Message: "Cleared blacklist",
+		Result:  "success",
+	})
+}
@@ -6,13 +6,32 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/downloaders"
 	"github.com/hobeone/tv2go/providers"
-	"github.com/hobeone/tv2go/types"
+	"github.com/hobeone/tv2go/quality"
 )
 
+// downloadReq is a search result to download.  The size and info hash, if
+// given, are checked against the file like they are for the daemon's grabs.
 type downloadReq struct {
 	Provider string `form:"provider" binding:"required"`
 	URL      string `form:"url" binding:"required"`
+	Name     string `form:"name"` // release name, the file name if not given
+	Size     int64  `form:"size"`
+	InfoHash string `form:"info_hash" json:"info_hash"`
+}
+
+// result returns the provider result the request is for.
+func (r downloadReq) result() providers.ProviderResult {
+	return providers.ProviderResult{
+		ProviderName: r.Provider,
+		Name:         r.Name,
+		URL:          r.URL,
+		Size:         r.Size,
+		InfoHash:     r.InfoHash,
+	}
 }
 
 // DownloadEpisode takes a request to download a episode from a provider
@@ -36,38 +55,93 @@ func (server *Server) DownloadEpisode(c *gin.Context) {
 		genError(c, http.StatusNotFound, err.Error())
 		return
 	}
-	ep.Status = types.SNATCHED
 
-	prov, ok := server.Providers[reqJSON.Provider]
-	if !ok {
-		genError(c, http.StatusBadRequest, fmt.Sprintf("Unknown provider: %s", reqJSON.Provider))
+	id, status, err := server.download(reqJSON.result(), []*db.Episode{ep})
+	if err != nil {
+		genError(c, status, err.Error())
 		return
 	}
+	server.dbHandle.SaveEpisode(ep)
+	c.JSON(200, fmt.Sprintf("Downloaded %s from %s as %s", reqJSON.URL, reqJSON.Provider, id))
+}
 
-	filename, filebytes, err := prov.GetURL(reqJSON.URL)
+// download gets the result's file from its provider, checks it's valid and
+// sends it to the download client for that provider's type, recording it
+// against the episodes and setting their release name.  The episodes are
+// marked as snatched the same way the daemon does but not saved.  It returns
+// the client's id for the download or an error and the http status to report
+// it with.
+func (server *Server) download(r providers.ProviderResult, eps []*db.Episode) (string, int, error) {
+	provider, url := r.ProviderName, r.URL
+	prov, ok := server.Providers[provider]
+	if !ok {
+		return "", http.StatusBadRequest, fmt.Errorf("Unknown provider: %s", provider)
+	}
+
+	filename, filebytes, err := prov.GetURL(url)
 
 	if err != nil {
-		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting file: %s", err.Error()))
-		return
+		return "", http.StatusInternalServerError, fmt.Errorf("Error getting file: %s", err.Error())
 	}
-
-	dltype := prov.Type()
-	destdir := ""
-	switch dltype {
-	case providers.NZB:
-		destdir = server.config.Storage.NZBBlackhole
-	case providers.TORRENT:
-		destdir = server.config.Storage.TorrentBlackhole
-	case providers.UNKNOWN:
-		genError(c, http.StatusInternalServerError, fmt.Sprintf("Unknown provider type: %d", prov.Type()))
-		return
+	if r.Name == "" {
+		r.Name = filename
+	}
+	name := r.Name
+	err = providers.CheckDownload(prov.Type(), r, filebytes)
+	if err != nil {
+		return "", http.StatusBadRequest, fmt.Errorf("Rejecting download: %s", err)
 	}
 
-	dstfile, err := server.Broker.SaveToFile(destdir, filename, filebytes)
+	client, err := server.downloadClients.For(prov.Type())
 	if err != nil {
-		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving file: %s", err))
-		return
+		return "", http.StatusInternalServerError, err
 	}
-	server.dbHandle.SaveEpisode(ep)
-	c.JSON(200, fmt.Sprintf("Downloaded %s from %s to %s", reqJSON.URL, reqJSON.Provider, dstfile))
+	id, err := client.Add(downloaders.Download{
+		Name:     name,
+		URL:      url,
+		Filename: filename,
+		Content:  filebytes,
+	})
+	if err != nil {
+		return "", http.StatusInternalServerError, fmt.Errorf("Error sending file to %s: %s", client.Name(), err)
+	}
+	err = server.dbHandle.AddDownload(db.Download{
+		Client:     client.Name(),
+		DownloadID: id,
+		Name:       name,
+		Provider:   provider,
+		URL:        url,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording download of %s: %s", url, err)
+	}
+	c, err := server.decisions.Match(r)
+	if err != nil {
+		glog.Warningf("Couldn't match %s to its episodes, using its name's quality: %s", name, err)
+	}
+	for _, ep := range eps {
+		ep.Status = c.SnatchedStatus(ep)
+		ep.ReleaseName = name
+	}
+	err = server.dbHandle.AddHistory(db.History{
+		Event:      db.HistoryGrabbed,
+		Name:       name,
+		Provider:   provider,
+		Quality:    quality.QualityFromName(name, server.isAnime(eps)),
+		Client:     client.Name(),
+		DownloadID: id,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", url, err)
+	}
+	return id, http.StatusOK, nil
+}
+
+// isAnime returns true if the episodes are of an anime show.
+func (server *Server) isAnime(eps []*db.Episode) bool {
+	if len(eps) == 0 {
+		return false
+	}
+	show, err := server.dbHandle.GetShowByID(eps[0].ShowId)
+	return err == nil && show.Anime
 }
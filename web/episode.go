@@ -7,6 +7,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/naming"
+	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
 	"github.com/hobeone/tv2go/types"
 )
 
@@ -146,7 +150,34 @@ type episodeSearchResp struct {
 	Quality     string `json:"quality"`
 }
 
-// EpisodeSearch searches configured Providers for episode files.
+// searchResult is a provider result along with what the decision engine made
+// of it.
+type searchResult struct {
+	providers.ProviderResult
+	Decision   string             `json:"decision"` // accepted or rejected
+	Rejections []string           `json:"rejections"`
+	Parse      naming.ParseResult `json:"parse"`
+	Quality    quality.Quality    `json:"parsed_quality"`
+}
+
+func decisionToResponse(d decision.Decision) searchResult {
+	res := searchResult{
+		ProviderResult: d.Result,
+		Decision:       "accepted",
+		Rejections:     d.Rejections,
+		Parse:          d.Parse,
+		Quality:        d.Quality,
+	}
+	if !d.Accepted() {
+		res.Decision = "rejected"
+	}
+	return res
+}
+
+// EpisodeSearch searches configured Providers for episode files.  The results
+// are ranked by the decision engine, best first, with the ones it rejects
+// last.  Each result says whether it was accepted and why not, along with how
+// its name and quality were parsed.
 func (server *Server) EpisodeSearch(c *gin.Context) {
 	h := server.dbHandle
 	episodeid, err := strconv.ParseInt(c.Params.ByName("episodeid"), 10, 64)
@@ -162,11 +193,15 @@ func (server *Server) EpisodeSearch(c *gin.Context) {
 		return
 	}
 
-	res := server.Providers.Search(ep.Show.Name, ep.Season, ep.Episode)
-	//res, err := server.Providers["nzbsOrg"].TvSearch(ep.Show.Name, ep.Season, ep.Episode)
+	res := server.Providers.Search(providers.NewSearchQuery(&ep.Show, ep))
 	if len(res) == 0 {
 		genError(c, http.StatusNotFound, fmt.Sprintf("No results found for show: %s", ep.Show.Name))
 		return
 	}
-	c.JSON(200, res)
+	decisions := server.decisions.Evaluate(&ep.Show, []*db.Episode{ep}, res)
+	resp := make([]searchResult, len(decisions))
+	for i, d := range decisions {
+		resp[i] = decisionToResponse(d)
+	}
+	c.JSON(200, resp)
 }
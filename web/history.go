@@ -0,0 +1,103 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/quality"
+)
+
+const (
+	defaultHistoryPageSize = 50
+	maxHistoryPageSize     = 500
+)
+
+type historyReq struct {
+	ShowID    int64 `form:"showid"`
+	EpisodeID int64 `form:"episodeid"`
+	Page      int   `form:"page"`
+	PageSize  int   `form:"page_size"`
+}
+
+type historyItem struct {
+	ID         int64           `json:"id"`
+	ShowID     int64           `json:"showid"`
+	EpisodeID  int64           `json:"episodeid"`
+	Event      string          `json:"event"`
+	Name       string          `json:"name"`
+	Provider   string          `json:"provider"`
+	Quality    quality.Quality `json:"quality"`
+	Size       int64           `json:"size"`
+	Client     string          `json:"client"`
+	DownloadID string          `json:"download_id"`
+	Message    string          `json:"message"`
+	Date       time.Time       `json:"date"`
+}
+
+type historyResponse struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	Total    int           `json:"total"`
+	History  []historyItem `json:"history"`
+}
+
+func historyToResponse(h db.History) historyItem {
+	return historyItem{
+		ID:         h.ID,
+		ShowID:     h.ShowID,
+		EpisodeID:  h.EpisodeID,
+		Event:      h.Event,
+		Name:       h.Name,
+		Provider:   h.Provider,
+		Quality:    h.Quality,
+		Size:       h.Size,
+		Client:     h.Client,
+		DownloadID: h.DownloadID,
+		Message:    h.Message,
+		Date:       h.Date,
+	}
+}
+
+// History serves a page of the grab, import and failure history, newest
+// first.  It can be limited to one show or episode with the showid and
+// episodeid parameters.
+func (server *Server) History(c *gin.Context) {
+	var req historyReq
+	if !c.Bind(&req) {
+		genError(c, http.StatusBadRequest, c.Errors.String())
+		return
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PageSize < 1 {
+		req.PageSize = defaultHistoryPageSize
+	}
+	if req.PageSize > maxHistoryPageSize {
+		req.PageSize = maxHistoryPageSize
+	}
+
+	history, total, err := server.dbHandle.GetHistory(db.HistoryFilter{
+		ShowID:    req.ShowID,
+		EpisodeID: req.EpisodeID,
+		Offset:    (req.Page - 1) * req.PageSize,
+		Limit:     req.PageSize,
+	})
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting history: %s", err))
+		return
+	}
+	resp := historyResponse{
+		Page:     req.Page,
+		PageSize: req.PageSize,
+		Total:    total,
+		History:  make([]historyItem, len(history)),
+	}
+	for i, h := range history {
+		resp.History[i] = historyToResponse(h)
+	}
+	c.JSON(200, resp)
+}
@@ -0,0 +1,37 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/providers"
+)
+
+type providerTestResp struct {
+	Status  providers.ProviderStatus `json:"status"`
+	Results int                      `json:"results"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// ProviderList serves the status of all of the configured providers.
+func (server *Server) ProviderList(c *gin.Context) {
+	c.JSON(200, server.Providers.Statuses())
+}
+
+// ProviderTest gets the latest items from a provider to check it's working.
+func (server *Server) ProviderTest(c *gin.Context) {
+	name := c.Params.ByName("name")
+	if _, ok := server.Providers[name]; !ok {
+		genError(c, http.StatusNotFound, "Unknown provider: "+name)
+		return
+	}
+	status, count, err := server.Providers.Test(name)
+	resp := providerTestResp{
+		Status:  status,
+		Results: count,
+	}
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	c.JSON(200, resp)
+}
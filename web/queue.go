@@ -0,0 +1,221 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/downloaders"
+	"github.com/hobeone/tv2go/quality"
+)
+
+type queueEpisode struct {
+	ID       int64  `json:"id"`
+	ShowID   int64  `json:"showid"`
+	ShowName string `json:"show_name"`
+	Season   int64  `json:"season"`
+	Episode  int64  `json:"episode"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+}
+
+type queueItem struct {
+	Client    string             `json:"client"`
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	Provider  string             `json:"provider"`
+	Status    downloaders.Status `json:"status"`
+	Size      int64              `json:"size"`
+	Remaining int64              `json:"remaining"`
+	Progress  float64            `json:"progress"` // percent done
+	ETA       int64              `json:"eta"`      // seconds, 0 if unknown
+	Message   string             `json:"message,omitempty"`
+	Added     time.Time          `json:"added"`
+	Episodes  []queueEpisode     `json:"episodes"`
+}
+
+// clientItems returns what each download client is doing, by client name and
+// then download id.  Clients that can't be reached are returned in errs.
+func (server *Server) clientItems() (map[string]map[string]downloaders.Item, map[string]error) {
+	items := map[string]map[string]downloaders.Item{}
+	errs := map[string]error{}
+	for _, client := range server.downloadClients {
+		queue, err := client.Queue()
+		if err == nil {
+			var history []downloaders.Item
+			history, err = client.History()
+			queue = append(queue, history...)
+		}
+		if err != nil {
+			glog.Errorf("Error getting downloads from %s: %s", client.Name(), err)
+			errs[client.Name()] = err
+			continue
+		}
+		byID := make(map[string]downloaders.Item, len(queue))
+		for _, item := range queue {
+			byID[item.ID] = item
+		}
+		items[client.Name()] = byID
+	}
+	return items, errs
+}
+
+// Queue serves the downloads tv2go has sent to download clients and is
+// waiting on, oldest first, along with the episodes they're for.
+func (server *Server) Queue(c *gin.Context) {
+	downloads, err := server.dbHandle.GetAllDownloads()
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting downloads: %s", err))
+		return
+	}
+	clientItems, clientErrs := server.clientItems()
+
+	resp := []*queueItem{}
+	byKey := map[string]*queueItem{}
+	for _, dl := range downloads {
+		key := dl.Client + "/" + dl.DownloadID
+		qi, ok := byKey[key]
+		if !ok {
+			qi = &queueItem{
+				Client:   dl.Client,
+				ID:       dl.DownloadID,
+				Name:     dl.Name,
+				Provider: dl.Provider,
+				Added:    dl.Added,
+				Episodes: []queueEpisode{},
+			}
+			item, found := clientItems[dl.Client][dl.DownloadID]
+			switch {
+			case found:
+				qi.Status = item.Status
+				qi.Size = item.Size
+				qi.Remaining = item.Remaining
+				qi.ETA = int64(item.ETA / time.Second)
+				qi.Message = item.Message
+				if item.Size > 0 {
+					qi.Progress = float64(item.Size-item.Remaining) / float64(item.Size) * 100
+				}
+			case clientErrs[dl.Client] != nil:
+				qi.Message = clientErrs[dl.Client].Error()
+			default:
+				qi.Message = fmt.Sprintf("%s doesn't know about this download", dl.Client)
+			}
+			byKey[key] = qi
+			resp = append(resp, qi)
+		}
+		ep, err := server.dbHandle.GetEpisodeByID(dl.EpisodeID)
+		if err != nil {
+			glog.Errorf("Couldn't find episode %d for %s: %s", dl.EpisodeID, dl.Name, err)
+			continue
+		}
+		qi.Episodes = append(qi.Episodes, queueEpisode{
+			ID:       ep.ID,
+			ShowID:   ep.ShowId,
+			ShowName: ep.Show.Name,
+			Season:   ep.Season,
+			Episode:  ep.Episode,
+			Name:     ep.Name,
+			Status:   ep.Status.String(),
+		})
+	}
+	c.JSON(200, resp)
+}
+
+// RemoveQueueItem removes a download from its client, deleting its data, and
+// sets its episodes back to WANTED, or DOWNLOADED if they were being
+// upgraded.  If the blacklist parameter is true the release is also
+// blacklisted so it isn't grabbed again.
+func (server *Server) RemoveQueueItem(c *gin.Context) {
+	clientName := c.Params.ByName("client")
+	id := c.Params.ByName("id")
+	blacklist := false
+	if b := c.Request.URL.Query().Get("blacklist"); b != "" {
+		var err error
+		blacklist, err = strconv.ParseBool(b)
+		if err != nil {
+			genError(c, http.StatusBadRequest, fmt.Sprintf("Invalid blacklist value: %s", b))
+			return
+		}
+	}
+
+	downloads, err := server.dbHandle.GetDownloads(clientName, id)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting downloads: %s", err))
+		return
+	}
+	if len(downloads) == 0 {
+		genError(c, http.StatusNotFound, fmt.Sprintf("No download %s in %s", id, clientName))
+		return
+	}
+	dl := downloads[0]
+
+	client, err := server.downloadClients.Named(clientName)
+	if err == nil {
+		err = client.Remove(id, true)
+	}
+	if err != nil {
+		glog.Errorf("Error removing %s from %s: %s", dl.Name, clientName, err)
+	}
+
+	message := "Removed from queue"
+	if blacklist {
+		message = "Removed from queue and blacklisted"
+		err = server.dbHandle.AddBlacklist(&db.Blacklist{
+			ShowID:    dl.ShowID,
+			EpisodeID: dl.EpisodeID,
+			Name:      dl.Name,
+			Provider:  dl.Provider,
+			URL:       dl.URL,
+			Reason:    message,
+		})
+		if err != nil {
+			genError(c, http.StatusInternalServerError, fmt.Sprintf("Error blacklisting %s: %s", dl.Name, err))
+			return
+		}
+	}
+	err = server.dbHandle.DeleteDownloads(clientName, id)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error removing download: %s", err))
+		return
+	}
+
+	eps := []*db.Episode{}
+	unsnatched := []*db.Episode{}
+	for _, dl := range downloads {
+		ep, err := server.dbHandle.GetEpisodeByID(dl.EpisodeID)
+		if err != nil {
+			glog.Errorf("Couldn't find episode %d for %s: %s", dl.EpisodeID, dl.Name, err)
+			continue
+		}
+		eps = append(eps, ep)
+		if ep.Status.IsSnatched() {
+			ep.Status = ep.Status.Unsnatched()
+			unsnatched = append(unsnatched, ep)
+		}
+	}
+	err = server.dbHandle.SaveEpisodes(unsnatched)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving episodes: %s", err))
+		return
+	}
+	err = server.dbHandle.AddHistory(db.History{
+		Event:      db.HistoryDeleted,
+		Name:       dl.Name,
+		Provider:   dl.Provider,
+		Quality:    quality.QualityFromName(dl.Name, server.isAnime(eps)),
+		Client:     clientName,
+		DownloadID: id,
+		Message:    message,
+	}, eps)
+	if err != nil {
+		glog.Errorf("Error recording history for %s: %s", dl.Name, err)
+	}
+	c.JSON(200, genericResult{
+		Message: fmt.Sprintf("%s: %s", message, dl.Name),
+		Result:  "success",
+	})
+}
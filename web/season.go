@@ -0,0 +1,113 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/providers"
+)
+
+// getShowAndSeason returns the show and season number from the request path.
+// On error it has already sent the response.
+func (server *Server) getShowAndSeason(c *gin.Context) (*db.Show, int64, bool) {
+	showid, err := strconv.ParseInt(c.Params.ByName("showid"), 10, 64)
+	if err != nil {
+		genError(c, http.StatusNotFound, fmt.Sprintf("Invalid showid: %v", c.Params.ByName("showid")))
+		return nil, 0, false
+	}
+	season, err := strconv.ParseInt(c.Params.ByName("season"), 10, 64)
+	if err != nil {
+		genError(c, http.StatusNotFound, fmt.Sprintf("Invalid season: %v", c.Params.ByName("season")))
+		return nil, 0, false
+	}
+	dbshow, err := server.dbHandle.GetShowByID(showid)
+	if err != nil {
+		genError(c, http.StatusNotFound, err.Error())
+		return nil, 0, false
+	}
+	return dbshow, season, true
+}
+
+// seasonWanted returns the episodes of the season the decision engine wants
+// releases for, including ones that could be upgraded.
+func (server *Server) seasonWanted(dbshow *db.Show, season int64) ([]*db.Episode, error) {
+	eps, err := server.dbHandle.GetSeasonEpisodes(dbshow.ID, season)
+	if err != nil {
+		return nil, err
+	}
+	all := make([]*db.Episode, len(eps))
+	for i := range eps {
+		all[i] = &eps[i]
+	}
+	return server.decisions.Wanted(dbshow, all), nil
+}
+
+// SeasonSearch searches configured Providers for releases of a whole season.
+// Like EpisodeSearch the results are ranked by the decision engine, best
+// first, and say why they were rejected.
+func (server *Server) SeasonSearch(c *gin.Context) {
+	dbshow, season, ok := server.getShowAndSeason(c)
+	if !ok {
+		return
+	}
+	wanted, err := server.seasonWanted(dbshow, season)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting episodes: %s", err))
+		return
+	}
+
+	res := server.Providers.Search(providers.NewSeasonSearchQuery(dbshow, season))
+	if len(res) == 0 {
+		genError(c, http.StatusNotFound, fmt.Sprintf("No results found for show: %s season %d", dbshow.Name, season))
+		return
+	}
+	decisions := server.decisions.Evaluate(dbshow, wanted, res)
+	resp := make([]searchResult, len(decisions))
+	for i, d := range decisions {
+		resp[i] = decisionToResponse(d)
+	}
+	c.JSON(200, resp)
+}
+
+// DownloadSeason downloads a season pack from a provider and marks the
+// season's wanted episodes as snatched.  Like the daemon it only does so if
+// enough of the season is wanted.
+func (server *Server) DownloadSeason(c *gin.Context) {
+	var reqJSON downloadReq
+
+	if !c.Bind(&reqJSON) {
+		genError(c, http.StatusBadRequest, c.Errors.String())
+		return
+	}
+
+	dbshow, season, ok := server.getShowAndSeason(c)
+	if !ok {
+		return
+	}
+
+	wanted, err := server.seasonWanted(dbshow, season)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error getting episodes: %s", err))
+		return
+	}
+	if len(wanted) < decision.MinSeasonPackWanted {
+		genError(c, http.StatusBadRequest, fmt.Sprintf("%s season %d has %d wanted episodes, need %d for a season pack", dbshow.Name, season, len(wanted), decision.MinSeasonPackWanted))
+		return
+	}
+
+	id, status, err := server.download(reqJSON.result(), wanted)
+	if err != nil {
+		genError(c, status, err.Error())
+		return
+	}
+	err = server.dbHandle.SaveEpisodes(wanted)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving episodes: %s", err))
+		return
+	}
+	c.JSON(200, fmt.Sprintf("Downloaded %s from %s as %s", reqJSON.URL, reqJSON.Provider, id))
+}
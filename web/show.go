@@ -154,13 +154,13 @@ func (server *Server) ShowUpdateFromDisk(c *gin.Context) {
 
 	dbeps := []*db.Episode{}
 	for _, pr := range parseRes {
-		if len(pr.EpisodeNumbers) == 0 {
+		if !pr.HasEpisode() {
 			glog.Errorf("Didn't get episode number from '%s'", pr.OriginalName)
 			continue
 		}
-		dbep, err := server.dbHandle.GetEpisodeByShowSeasonAndNumber(showid, pr.SeasonNumber, pr.EpisodeNumbers[0])
+		dbep, err := server.dbHandle.GetEpisodeByParseResult(dbshow, &pr)
 		if err != nil {
-			glog.Errorf("Couldn't find episode by showid %d, season %d, number %d", showid, pr.SeasonNumber, pr.EpisodeNumbers[0])
+			glog.Errorf("Couldn't find episode of show %d for '%s'", showid, pr.OriginalName)
 			continue
 		}
 		dbep.Quality = pr.Quality
@@ -10,11 +10,15 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang/glog"
+	"github.com/hobeone/tv2go/backlog"
 	"github.com/hobeone/tv2go/config"
 	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/downloaders"
 	"github.com/hobeone/tv2go/indexers"
 	"github.com/hobeone/tv2go/naming"
 	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
 	"github.com/hobeone/tv2go/storage"
 	"github.com/hobeone/tv2go/types"
 )
@@ -95,12 +99,15 @@ func (server *Server) StartServing() {
 
 // Server contains all the information for the tv2go web server
 type Server struct {
-	Handler   http.Handler
-	config    *config.Config
-	Broker    *storage.Broker
-	Providers providers.ProviderRegistry
-	indexers  indexers.IndexerRegistry
-	dbHandle  *db.Handle
+	Handler         http.Handler
+	config          *config.Config
+	Broker          *storage.Broker
+	Providers       providers.ProviderRegistry
+	indexers        indexers.IndexerRegistry
+	downloadClients downloaders.ClientRegistry
+	decisions       *decision.Engine
+	backlog         *backlog.Searcher
+	dbHandle        *db.Handle
 }
 
 func configGinEngine(s *Server) {
@@ -118,6 +125,7 @@ func configGinEngine(s *Server) {
 		api.PUT("shows/:showid", s.UpdateShow)
 		api.GET("shows/:showid/update", s.ShowUpdateFromIndexer)
 		api.GET("shows/:showid/rescan", s.ShowUpdateFromDisk)
+		api.POST("shows/:showid/backlog", s.ShowBacklog)
 		api.POST("shows", s.AddShow)
 
 		api.GET("shows/:showid/episodes", s.ShowEpisodes)
@@ -126,12 +134,27 @@ func configGinEngine(s *Server) {
 		api.POST("shows/:showid/episodes/:episodeid/download", s.DownloadEpisode)
 		api.PUT("shows/:showid/episodes", s.UpdateEpisode)
 
+		api.GET("shows/:showid/seasons/:season/search", s.SeasonSearch)
+		api.POST("shows/:showid/seasons/:season/download", s.DownloadSeason)
+		api.POST("shows/:showid/seasons/:season/backlog", s.SeasonBacklog)
+
 		api.GET("indexers/search", s.ShowSearch)
 		api.GET("indexers", s.IndexerList)
+		api.GET("providers", s.ProviderList)
+		api.POST("providers/:name/test", s.ProviderTest)
 		api.GET("statuses", s.StatusList)
 		api.GET("quality_groups", s.QualityGroupList)
+		api.PUT("quality_groups/:name", s.UpdateQualityGroup)
 
 		api.POST("postprocess", s.Postprocess)
+
+		api.GET("history", s.History)
+		api.GET("queue", s.Queue)
+		api.DELETE("queue/:client/:id", s.RemoveQueueItem)
+
+		api.GET("blacklist", s.Blacklist)
+		api.DELETE("blacklist", s.ClearBlacklist)
+		api.DELETE("blacklist/:id", s.DeleteBlacklist)
 	}
 
 	r.GET("/statusz", s.Statusz)
@@ -149,6 +172,55 @@ func (server *Server) QualityGroupList(c *gin.Context) {
 	c.JSON(200, qualityGroups)
 }
 
+type qualityGroupReq struct {
+	Qualities []string `json:"qualities" binding:"required"` // least to most preferred
+	Cutoff    string   `json:"cutoff"`
+	Default   bool     `json:"default"`
+}
+
+// UpdateQualityGroup sets the ordered qualities and cutoff of the named
+// QualityGroup, creating it if it doesn't exist.  Making it the default group
+// unsets the previous one.
+func (server *Server) UpdateQualityGroup(c *gin.Context) {
+	var req qualityGroupReq
+	if !c.Bind(&req) {
+		genError(c, http.StatusBadRequest, c.Errors.String())
+		return
+	}
+	name := c.Params.ByName("name")
+	qg, err := server.dbHandle.GetQualityGroupByName(name)
+	if err != nil {
+		qg = &quality.QualityGroup{Name: name}
+	}
+	qg.Qualities = make([]quality.Quality, len(req.Qualities))
+	for i, s := range req.Qualities {
+		qg.Qualities[i], err = quality.QualityFromString(s)
+		if err != nil {
+			genError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	qg.Cutoff = quality.UNKNOWN
+	if req.Cutoff != "" {
+		qg.Cutoff, err = quality.QualityFromString(req.Cutoff)
+		if err != nil {
+			genError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !qg.Includes(qg.Cutoff) {
+			genError(c, http.StatusBadRequest, fmt.Sprintf("Cutoff %s isn't one of the group's qualities", req.Cutoff))
+			return
+		}
+	}
+	qg.Default = req.Default
+	err = server.dbHandle.SaveQualityGroup(qg)
+	if err != nil {
+		genError(c, http.StatusInternalServerError, fmt.Sprintf("Error saving QualityGroup: %s", err))
+		return
+	}
+	c.JSON(200, qg)
+}
+
 //StatusList returns all of the known Episode Statuses
 func (server *Server) StatusList(c *gin.Context) {
 	typeStrs := make([]string, len(types.EpisodeDefaults))
@@ -186,6 +258,28 @@ func SetIndexers(idxs indexers.IndexerRegistry) func(*Server) {
 	}
 }
 
+// SetDownloadClients sets the download clients grabs are sent to
+func SetDownloadClients(clients downloaders.ClientRegistry) func(*Server) {
+	return func(s *Server) {
+		s.downloadClients = clients
+	}
+}
+
+// SetDecisionEngine sets the engine used to decide which search results are
+// acceptable
+func SetDecisionEngine(e *decision.Engine) func(*Server) {
+	return func(s *Server) {
+		s.decisions = e
+	}
+}
+
+// SetBacklog sets the searcher used to search for wanted episodes on request
+func SetBacklog(b *backlog.Searcher) func(*Server) {
+	return func(s *Server) {
+		s.backlog = b
+	}
+}
+
 // NewServer creates a new server
 func NewServer(cfg *config.Config, dbh *db.Handle, broker *storage.Broker, provReg providers.ProviderRegistry, options ...func(*Server)) *Server {
 	t := &Server{
@@ -199,5 +293,15 @@ func NewServer(cfg *config.Config, dbh *db.Handle, broker *storage.Broker, provR
 	for _, option := range options {
 		option(t)
 	}
+	if t.downloadClients == nil {
+		clients, err := downloaders.NewClientRegistry(cfg, broker)
+		if err != nil {
+			glog.Errorf("Error configuring download clients: %s", err)
+		}
+		t.downloadClients = clients
+	}
+	if t.decisions == nil {
+		t.decisions = decision.NewEngine(cfg, dbh, provReg)
+	}
 	return t
 }
@@ -1,23 +1,32 @@
 package web
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	. "github.com/onsi/gomega"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hobeone/tv2go/backlog"
 	"github.com/hobeone/tv2go/config"
 	"github.com/hobeone/tv2go/db"
+	"github.com/hobeone/tv2go/decision"
+	"github.com/hobeone/tv2go/downloaders"
 	"github.com/hobeone/tv2go/indexers"
 	"github.com/hobeone/tv2go/indexers/tvdb"
+	"github.com/hobeone/tv2go/naming"
 	"github.com/hobeone/tv2go/providers"
+	"github.com/hobeone/tv2go/quality"
 	"github.com/hobeone/tv2go/storage"
+	"github.com/hobeone/tv2go/test_helpers"
+	"github.com/hobeone/tv2go/types"
 )
 
 var basedir, _ = filepath.Abs("")
@@ -313,3 +322,475 @@ func TestQualityGroupList(t *testing.T) {
 		t.Fatalf("Expected 200 response code, got %d", response.Code)
 	}
 }
+
+func TestUpdateQualityGroup(t *testing.T) {
+	dbh, eng := setupTest(t)
+	db.LoadFixtures(t, dbh)
+	RegisterTestingT(t)
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("PUT", "/api/1/quality_groups/HD720p", strings.NewReader(`{"qualities":["HD TV","720p WEB-DL","720p BluRay"],"cutoff":"720p WEB-DL"}`))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	qg, err := dbh.GetQualityGroupByName("HD720p")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(qg.Qualities).To(Equal([]quality.Quality{quality.HDTV, quality.HDWEBDL, quality.HDBLURAY}))
+	Expect(qg.Cutoff).To(Equal(quality.HDWEBDL))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("PUT", "/api/1/quality_groups/HD720p", strings.NewReader(`{"qualities":["HD TV"],"cutoff":"720p WEB-DL"}`))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(400))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("PUT", "/api/1/quality_groups/New", strings.NewReader(`{"qualities":["SD TV","HD TV"]}`))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	qg, err = dbh.GetQualityGroupByName("New")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(qg.Cutoff).To(Equal(quality.UNKNOWN))
+}
+
+func TestBacklog(t *testing.T) {
+	dbh, eng := setupTest(t)
+	db.LoadFixtures(t, dbh)
+	RegisterTestingT(t)
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("POST", "/api/1/shows/1/backlog", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(http.StatusServiceUnavailable))
+
+	grab := func(decisions []decision.Decision) (*decision.Decision, error) {
+		return nil, nil
+	}
+	eng.backlog = backlog.NewSearcher(config.NewTestConfig(), dbh, eng.Providers, eng.decisions, grab)
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/1/backlog", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring("Searching for 2 wanted episodes of show1"))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/2/seasons/2/backlog", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring("Searching for 1 wanted episodes of show2 season 2"))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/1/seasons/5/backlog", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring("No wanted episodes of show1 season 5"))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/99/backlog", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(404))
+}
+
+func TestBlacklist(t *testing.T) {
+	dbh, eng := setupTest(t)
+	db.LoadFixtures(t, dbh)
+	RegisterTestingT(t)
+
+	b := &db.Blacklist{ShowID: 1, EpisodeID: 1, Name: "show1.S01E01.720p-GRP", Reason: "Repair failed"}
+	Expect(dbh.AddBlacklist(b)).ToNot(HaveOccurred())
+	Expect(dbh.AddBlacklist(&db.Blacklist{Name: "show1.S01E02.720p-GRP"})).ToNot(HaveOccurred())
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/blacklist", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring(`"reason":"Repair failed"`))
+	Expect(response.Body.String()).To(ContainSubstring("show1.S01E02.720p-GRP"))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", fmt.Sprintf("/api/1/blacklist/%d", b.ID), nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	listed, err := dbh.IsBlacklisted(b.Name)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeFalse())
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", fmt.Sprintf("/api/1/blacklist/%d", b.ID), nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(404))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/api/1/blacklist/abc", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(400))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/api/1/blacklist", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	all, err := dbh.GetBlacklist()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(all).To(BeEmpty())
+}
+
+func TestHistory(t *testing.T) {
+	dbh, eng := setupTest(t)
+	db.LoadFixtures(t, dbh)
+	RegisterTestingT(t)
+
+	ep1, err := dbh.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep2, err := dbh.GetEpisodeByID(2)
+	Expect(err).ToNot(HaveOccurred())
+	err = dbh.AddHistory(db.History{Event: db.HistoryGrabbed, Name: "show1.S01E01E02.720p-GRP"}, []*db.Episode{ep1, ep2})
+	Expect(err).ToNot(HaveOccurred())
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/history", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring(`"total":2`))
+	Expect(response.Body.String()).To(ContainSubstring(`"page_size":50`))
+	Expect(response.Body.String()).To(ContainSubstring(`"event":"grabbed"`))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", fmt.Sprintf("/api/1/history?episodeid=%d&page=1&page_size=10", ep2.ID), nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(response.Body.String()).To(ContainSubstring(`"total":1`))
+	Expect(response.Body.String()).To(ContainSubstring(fmt.Sprintf(`"episodeid":%d`, ep2.ID)))
+}
+
+const searchFeed = `<?xml version="1.0" encoding="utf-8" ?>
+<rss version="2.0" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+<channel>
+<item>
+	<title>show1.S01E01.HDTV.x264-SD</title>
+	<guid isPermaLink="false">sd</guid>
+	<link>http://example.com/getnzb/sd.nzb</link>
+</item>
+<item>
+	<title>show1.S01E01.720p.HDTV.x264-GOOD</title>
+	<guid isPermaLink="false">good</guid>
+	<link>http://example.com/getnzb/good.nzb</link>
+</item>
+</channel>
+</rss>`
+
+func TestEpisodeSearch(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("t") == "caps" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(searchFeed))
+	}))
+	defer server.Close()
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	provReg, err := providers.NewProviderRegistry(cfg.Providers)
+	Expect(err).ToNot(HaveOccurred())
+	dbh := db.NewMemoryDBHandle(false, true)
+	db.LoadFixtures(t, dbh)
+	broker, err := storage.NewBroker("testdata")
+	Expect(err).ToNot(HaveOccurred())
+	eng := NewServer(cfg, dbh, broker, provReg)
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/shows/1/episodes/1/search", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+
+	results := []struct {
+		Name       string             `json:"name"`
+		Decision   string             `json:"decision"`
+		Rejections []string           `json:"rejections"`
+		Parse      naming.ParseResult `json:"parse"`
+		Quality    string             `json:"parsed_quality"`
+	}{}
+	err = json.Unmarshal(response.Body.Bytes(), &results)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(results).To(HaveLen(2))
+	Expect(results[0].Name).To(Equal("show1.S01E01.720p.HDTV.x264-GOOD"))
+	Expect(results[0].Decision).To(Equal("accepted"))
+	Expect(results[0].Rejections).To(BeEmpty())
+	Expect(results[0].Parse.SeriesName).To(Equal("show1"))
+	Expect(results[0].Parse.ReleaseGroup).To(Equal("GOOD"))
+	Expect(results[1].Name).To(Equal("show1.S01E01.HDTV.x264-SD"))
+	Expect(results[1].Decision).To(Equal("rejected"))
+	Expect(results[1].Quality).To(Equal(quality.SDTV.String()))
+	Expect(results[1].Rejections[0]).To(ContainSubstring("isn't allowed"))
+}
+
+const seasonFeed = `<?xml version="1.0" encoding="utf-8" ?>
+<rss version="2.0" xmlns:newznab="http://www.newznab.com/DTD/2010/feeds/attributes/">
+<channel>
+<item>
+	<title>show1.S01.720p.HDTV.x264-GRP</title>
+	<guid isPermaLink="false">pack</guid>
+	<link>%s/getnzb/pack.nzb</link>
+</item>
+</channel>
+</rss>`
+
+const seasonNZB = `<?xml version="1.0" encoding="utf-8" ?>
+<nzb xmlns="http://www.newzbin.com/DTD/2003/nzb">
+ <file poster="poster@example.com" date="1425825827" subject="show1.S01.720p.HDTV.x264-GRP.rar yEnc (1/1)">
+   <groups><group>alt.binaries.teevee</group></groups>
+   <segments><segment bytes="400000" number="1">part1of1@example.com</segment></segments>
+ </file>
+</nzb>`
+
+func TestSeasonSearchAndDownload(t *testing.T) {
+	RegisterTestingT(t)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/getnzb/pack.nzb":
+			w.Header().Set("Content-Disposition", "attachment; filename=pack.nzb")
+			w.Write([]byte(seasonNZB))
+		case r.URL.Query().Get("t") == "caps":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			fmt.Fprintf(w, seasonFeed, server.URL)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "test", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+	)
+	provReg, err := providers.NewProviderRegistry(cfg.Providers)
+	Expect(err).ToNot(HaveOccurred())
+	dbh := db.NewMemoryDBHandle(false, true)
+	db.LoadFixtures(t, dbh)
+	broker, err := storage.NewBroker("testdata")
+	Expect(err).ToNot(HaveOccurred())
+	eng := NewServer(cfg, dbh, broker, provReg)
+	client := &test_helpers.FakeDownloadClient{}
+	eng.downloadClients = downloaders.ClientRegistry{providers.NZB: client}
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/shows/1/seasons/1/search", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	results := []struct {
+		Name       string   `json:"name"`
+		Decision   string   `json:"decision"`
+		Rejections []string `json:"rejections"`
+	}{}
+	err = json.Unmarshal(response.Body.Bytes(), &results)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(results).To(HaveLen(1))
+	Expect(results[0].Name).To(Equal("show1.S01.720p.HDTV.x264-GRP"))
+	Expect(results[0].Decision).To(Equal("accepted"))
+	Expect(results[0].Rejections).To(BeEmpty())
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("GET", "/api/1/shows/99/seasons/1/search", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(404))
+
+	// show2 only has one wanted episode in season 1, not enough for a pack.
+	body := fmt.Sprintf(`{"provider":"test","url":"%s/getnzb/pack.nzb","name":"show2.S01.720p.HDTV.x264-GRP"}`, server.URL)
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/2/seasons/1/download", strings.NewReader(body))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(http.StatusBadRequest))
+	Expect(response.Body.String()).To(ContainSubstring("has 1 wanted episodes, need 2"))
+	Expect(client.Added).To(BeEmpty())
+
+	// The size listed in the search result is checked against the NZB's.
+	body = fmt.Sprintf(`{"provider":"test","url":"%s/getnzb/pack.nzb","name":"show1.S01.720p.HDTV.x264-GRP","size":4000000000}`, server.URL)
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/1/seasons/1/download", strings.NewReader(body))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(http.StatusBadRequest))
+	Expect(response.Body.String()).To(ContainSubstring("too different from listed size"))
+	Expect(client.Added).To(BeEmpty())
+
+	// A downloaded episode that could still get a proper counts as wanted,
+	// as it does for the daemon.
+	ep2, err := dbh.GetEpisodeByID(2)
+	Expect(err).ToNot(HaveOccurred())
+	ep2.Status = types.DOWNLOADED
+	ep2.Quality = quality.HDTV
+	ep2.AirDate = time.Now().Add(-24 * time.Hour)
+	Expect(dbh.SaveEpisode(ep2)).ToNot(HaveOccurred())
+
+	body = fmt.Sprintf(`{"provider":"test","url":"%s/getnzb/pack.nzb","name":"show1.S01.720p.HDTV.x264-GRP"}`, server.URL)
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/shows/1/seasons/1/download", strings.NewReader(body))
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	req.Header.Add("content-type", "application/json;charset=UTF-8")
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(client.Added).To(HaveLen(1))
+	Expect(client.Added[0].Name).To(Equal("show1.S01.720p.HDTV.x264-GRP"))
+	for id, status := range map[int64]types.EpisodeStatus{1: types.SNATCHED, 2: types.SNATCHED_BEST} {
+		ep, err := dbh.GetEpisodeByID(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ep.Status).To(Equal(status))
+		Expect(ep.ReleaseName).To(Equal("show1.S01.720p.HDTV.x264-GRP"))
+	}
+}
+
+func TestProviders(t *testing.T) {
+	RegisterTestingT(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/bad" {
+			w.Write([]byte(`<error code="100" description="Incorrect user credentials"/>`))
+			return
+		}
+		w.Write([]byte(searchFeed))
+	}))
+	defer server.Close()
+
+	cfg := config.NewTestConfig()
+	cfg.Providers = append(cfg.Providers,
+		config.ProviderConfig{Name: "good", Type: "newznab", URL: server.URL + "/api", API: "123", Enabled: true},
+		config.ProviderConfig{Name: "bad", Type: "newznab", URL: server.URL + "/bad", API: "456", Enabled: true},
+	)
+	provReg, err := providers.NewProviderRegistry(cfg.Providers)
+	Expect(err).ToNot(HaveOccurred())
+	dbh := db.NewMemoryDBHandle(false, true)
+	broker, err := storage.NewBroker("testdata")
+	Expect(err).ToNot(HaveOccurred())
+	eng := NewServer(cfg, dbh, broker, provReg)
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/providers", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	statuses := []providers.ProviderStatus{}
+	err = json.Unmarshal(response.Body.Bytes(), &statuses)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(statuses).To(HaveLen(2))
+	Expect(statuses[0].Name).To(Equal("bad"))
+	Expect(statuses[1].Name).To(Equal("good"))
+	Expect(statuses[1].Type).To(Equal("NZB"))
+	Expect(statuses[1].Healthy).To(BeTrue())
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/providers/good/test", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	resp := providerTestResp{}
+	err = json.Unmarshal(response.Body.Bytes(), &resp)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(resp.Error).To(BeEmpty())
+	Expect(resp.Results).To(Equal(2))
+	Expect(resp.Status.Healthy).To(BeTrue())
+	Expect(resp.Status.Requests).To(BeEquivalentTo(1))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/providers/bad/test", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	resp = providerTestResp{}
+	err = json.Unmarshal(response.Body.Bytes(), &resp)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(resp.Error).To(Equal("API error 100: Incorrect user credentials"))
+	Expect(resp.Results).To(BeZero())
+	Expect(resp.Status.Healthy).To(BeFalse())
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("POST", "/api/1/providers/unknown/test", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(404))
+}
+
+func TestQueue(t *testing.T) {
+	dbh, eng := setupTest(t)
+	db.LoadFixtures(t, dbh)
+	RegisterTestingT(t)
+
+	client := &test_helpers.FakeDownloadClient{
+		QueueItems: []downloaders.Item{
+			{ID: "nzo_1", Name: "show1.S01E01.720p-GRP", Status: downloaders.DOWNLOADING, Size: 1000, Remaining: 250, ETA: time.Minute},
+		},
+	}
+	eng.downloadClients = downloaders.ClientRegistry{providers.NZB: client}
+
+	ep, err := dbh.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	ep.Status = types.SNATCHED
+	Expect(dbh.SaveEpisode(ep)).ToNot(HaveOccurred())
+	Expect(dbh.AddDownload(db.Download{Client: "fake", DownloadID: "nzo_1", Name: "show1.S01E01.720p-GRP"}, []*db.Episode{ep})).ToNot(HaveOccurred())
+	Expect(dbh.AddDownload(db.Download{Client: "fake", DownloadID: "gone", Name: "show1.S01E02.720p-GRP"}, []*db.Episode{ep})).ToNot(HaveOccurred())
+
+	response := httptest.NewRecorder()
+	req, err := http.NewRequest("GET", "/api/1/queue", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	body := response.Body.String()
+	Expect(body).To(ContainSubstring(`"status":"DOWNLOADING"`))
+	Expect(body).To(ContainSubstring(`"progress":75`))
+	Expect(body).To(ContainSubstring(`"eta":60`))
+	Expect(body).To(ContainSubstring(`"show_name":"show1"`))
+	Expect(body).To(ContainSubstring("fake doesn't know about this download"))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/api/1/queue/fake/nzo_1?blacklist=true", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(200))
+	Expect(client.Removed).To(Equal([]string{"nzo_1"}))
+	listed, err := dbh.IsBlacklisted("show1.S01E01.720p-GRP")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(listed).To(BeTrue())
+	downloads, err := dbh.GetDownloads("fake", "nzo_1")
+	Expect(err).ToNot(HaveOccurred())
+	Expect(downloads).To(BeEmpty())
+	ep, err = dbh.GetEpisodeByID(1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(ep.Status).To(Equal(types.WANTED))
+	history, _, err := dbh.GetHistory(db.HistoryFilter{EpisodeID: 1})
+	Expect(err).ToNot(HaveOccurred())
+	Expect(history).To(HaveLen(1))
+	Expect(history[0].Event).To(Equal(db.HistoryDeleted))
+
+	response = httptest.NewRecorder()
+	req, err = http.NewRequest("DELETE", "/api/1/queue/fake/nzo_1", nil)
+	Expect(err).ToNot(HaveOccurred(), "Error creating request: %s", err)
+	eng.Handler.ServeHTTP(response, req)
+	Expect(response.Code).To(Equal(404))
+}